@@ -11,13 +11,20 @@ const (
 	username  = "boss"
 	password  = "0162"
 	authToken = "my-secret-token-123"
+	userKey   = "auth_user"
 )
 
+// User возвращает имя пользователя, прошедшего авторизацию
+func User(c *gin.Context) string {
+	return c.GetString(userKey)
+}
+
 func Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Проверяем куки
 		token, err := c.Cookie("auth_token")
 		if err == nil && token == authToken {
+			c.Set(userKey, username)
 			c.Next()
 			return
 		}
@@ -27,6 +34,7 @@ func Middleware() gin.HandlerFunc {
 		if hasAuth && user == username && pass == password {
 			// Успешная авторизация, устанавливаем куки
 			c.SetCookie("auth_token", authToken, 3600*24*30, "/", "", false, true) // Куки на 30 дней
+			c.Set(userKey, username)
 			c.Next()
 			return
 		}
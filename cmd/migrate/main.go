@@ -0,0 +1,90 @@
+// cmd/migrate/main.go
+//
+// Однократный перенос данных из JSON-файлов в базу SQLite:
+//
+//	go run ./cmd/migrate -finance finance_data.json -worklog worklog_data.json -db finance.db
+//
+// После переноса сервер запускается с флагами -storage sqlite -db finance.db.
+package main
+
+import (
+	"finance-tracker/models"
+	"finance-tracker/storage"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	financePath := flag.String("finance", "finance_data.json", "файл финансовых данных")
+	workLogPath := flag.String("worklog", "worklog_data.json", "файл табеля")
+	dbPath := flag.String("db", "finance.db", "путь к базе SQLite")
+	force := flag.Bool("force", false, "перезаписать данные, если база уже заполнена")
+	flag.Parse()
+
+	if err := run(*financePath, *workLogPath, *dbPath, *force); err != nil {
+		fmt.Println("Ошибка миграции:", err)
+		os.Exit(1)
+	}
+}
+
+func run(financePath, workLogPath, dbPath string, force bool) error {
+	// Данные читаются через обычные хранилища, чтобы применились те же
+	// значения по умолчанию, что и при запуске сервера
+	financeStore := storage.NewFinanceStorage(financePath)
+	if err := financeStore.Load(); err != nil {
+		return fmt.Errorf("финансовые данные: %v", err)
+	}
+	financeStore.RecalculateBalances()
+	workLogStore := storage.NewWorkLogStorage(workLogPath)
+	if err := workLogStore.Load(); err != nil {
+		return fmt.Errorf("табель: %v", err)
+	}
+
+	db, err := storage.OpenSQLite(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Загрузка существующих данных нужна и при -force: бэкенд удалит записи,
+	// которых нет в JSON-файлах
+	var existingFinance models.FinanceData
+	var existingWorkLog models.WorkLogData
+	hasFinance, err := db.LoadFinance(&existingFinance)
+	if err != nil {
+		return err
+	}
+	hasWorkLog, err := db.LoadWorkLog(&existingWorkLog)
+	if err != nil {
+		return err
+	}
+	if (hasFinance || hasWorkLog) && !force {
+		return fmt.Errorf("база %s уже содержит данные, используйте -force для перезаписи", dbPath)
+	}
+
+	financeData := financeStore.Snapshot()
+	workLogData := workLogStore.Snapshot()
+	if err := db.SaveFinance(financeData); err != nil {
+		return err
+	}
+	if err := db.SaveWorkLog(workLogData); err != nil {
+		return err
+	}
+
+	// Проверяем перенос подсчётом записей в базе: операции с любой датой,
+	// в том числе нулевой, должны попасть в базу
+	transactions, entries, err := db.Counts()
+	if err != nil {
+		return err
+	}
+	if transactions != len(financeData.Transactions) || entries != len(workLogData.Entries) {
+		return fmt.Errorf("перенесено операций %d из %d, записей табеля %d из %d",
+			transactions, len(financeData.Transactions), entries, len(workLogData.Entries))
+	}
+
+	fmt.Printf("Перенесено операций: %d, записей табеля: %d, счетов: %d, категорий: %d\n",
+		transactions, entries, len(financeData.Accounts), len(financeData.Categories))
+	fmt.Printf("Запустите сервер с флагами -storage sqlite -db %s\n", dbPath)
+	return nil
+}
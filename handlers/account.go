@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"finance-tracker/models"
+	"finance-tracker/storage"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	financeStore *storage.FinanceStorage
+}
+
+func NewAccountHandler(financeStore *storage.FinanceStorage) *AccountHandler {
+	return &AccountHandler{financeStore: financeStore}
+}
+
+var accountTypeNames = map[string]string{
+	storage.AccountCash:    "Наличные",
+	storage.AccountCard:    "Карта",
+	storage.AccountDeposit: "Вклад",
+	storage.AccountOther:   "Другое",
+}
+
+// accountMap строит индекс счетов по ID
+func accountMap(accounts []models.Account) map[int]models.Account {
+	result := make(map[int]models.Account, len(accounts))
+	for _, a := range accounts {
+		result[a.ID] = a
+	}
+	return result
+}
+
+// resolveAccount определяет счёт операции по значению из формы. Если счёт не
+// выбран, используется счёт по умолчанию для валюты. Возвращает ID счёта и
+// его валюту, которая имеет приоритет над валютой из формы. Вызывается
+// внутри транзакции хранилища, так как может создать счёт по умолчанию.
+func resolveAccount(data *models.FinanceData, value, currency string) (int, string, string) {
+	if value == "" || value == "0" {
+		if currency == "" {
+			return 0, "", "Ошибка: Выберите валюту"
+		}
+		return storage.DefaultAccountID(data, currency), currency, ""
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, "", "Ошибка: Неверный счёт"
+	}
+	account, ok := accountMap(data.Accounts)[id]
+	if !ok {
+		return 0, "", "Ошибка: Счёт не найден"
+	}
+	if account.Archived {
+		return 0, "", "Ошибка: Счёт в архиве"
+	}
+	return account.ID, account.Currency, ""
+}
+
+// activeAccounts возвращает неархивные счета, отсортированные по валюте и имени
+func activeAccounts(accounts []models.Account) []models.Account {
+	result := []models.Account{}
+	for _, a := range accounts {
+		if !a.Archived {
+			result = append(result, a)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Currency != result[j].Currency {
+			return result[i].Currency < result[j].Currency
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (h *AccountHandler) Accounts(c *gin.Context) {
+	data := h.financeStore.Snapshot()
+
+	items := []gin.H{}
+	for _, a := range data.Accounts {
+		items = append(items, gin.H{
+			"ID":             a.ID,
+			"Name":           a.Name,
+			"Currency":       a.Currency,
+			"Type":           a.Type,
+			"TypeName":       accountTypeNames[a.Type],
+			"OpeningBalance": fmt.Sprintf("%.2f", a.OpeningBalance),
+			"Balance":        fmt.Sprintf("%.2f", data.AccountBalances[a.ID]),
+			"Archived":       a.Archived,
+			"BankAccount":    a.BankAccount,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i]["Archived"].(bool) != items[j]["Archived"].(bool) {
+			return !items[i]["Archived"].(bool)
+		}
+		return items[i]["Name"].(string) < items[j]["Name"].(string)
+	})
+
+	c.HTML(http.StatusOK, "accounts.html", gin.H{
+		"Accounts":       items,
+		"ActiveAccounts": activeAccounts(data.Accounts),
+		"AccountTypes":   accountTypeNames,
+	})
+}
+
+func (h *AccountHandler) AddAccount(c *gin.Context) {
+	name := strings.TrimSpace(c.PostForm("name"))
+	currency := c.PostForm("currency")
+	accountType := c.PostForm("type")
+
+	if name == "" {
+		c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Название не может быть пустым")
+		return
+	}
+	if currency == "" {
+		c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Выберите валюту")
+		return
+	}
+	if !storage.ValidAccountType(accountType) {
+		c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Неверный тип счёта")
+		return
+	}
+
+	openingBalance := 0.0
+	if value := c.PostForm("opening_balance"); value != "" {
+		var err error
+		openingBalance, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Неверный начальный остаток")
+			return
+		}
+	}
+
+	err := h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		newID := 1
+		for _, a := range data.Accounts {
+			if a.ID >= newID {
+				newID = a.ID + 1
+			}
+		}
+		data.Accounts = append(data.Accounts, models.Account{
+			ID:             newID,
+			Name:           name,
+			Currency:       currency,
+			Type:           accountType,
+			OpeningBalance: openingBalance,
+		})
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/accounts?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/accounts?message=Счёт добавлен")
+}
+
+func (h *AccountHandler) EditAccount(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Неверный ID счёта")
+		return
+	}
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	accountType := c.PostForm("type")
+	archived := c.PostForm("archived") == "on"
+	bankAccount := strings.TrimSpace(c.PostForm("bank_account"))
+
+	if name == "" {
+		c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Название не может быть пустым")
+		return
+	}
+	if !storage.ValidAccountType(accountType) {
+		c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Неверный тип счёта")
+		return
+	}
+	openingBalance, err := strconv.ParseFloat(c.PostForm("opening_balance"), 64)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Неверный начальный остаток")
+		return
+	}
+
+	// Валюта счёта не меняется: на ней основаны уже созданные операции
+	err = h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		for i, a := range data.Accounts {
+			if a.ID == id {
+				data.Accounts[i].Name = name
+				data.Accounts[i].Type = accountType
+				data.Accounts[i].OpeningBalance = openingBalance
+				data.Accounts[i].Archived = archived
+				data.Accounts[i].BankAccount = bankAccount
+				return nil
+			}
+		}
+		return formError("Ошибка: Счёт не найден")
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/accounts?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/accounts?message=Счёт обновлён")
+}
+
+// Transfer перемещает деньги между двумя счетами одной валюты. Перевод
+// хранится как пара связанных операций (расход со счёта-источника и доход на
+// счёт-получатель) и не учитывается в доходах и расходах. Отмена последнего
+// действия удаляет обе операции.
+func (h *AccountHandler) Transfer(c *gin.Context) {
+	amount, err := strconv.ParseFloat(c.PostForm("amount"), 64)
+	if err != nil || amount <= 0 {
+		c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Неверная сумма")
+		return
+	}
+	fromID, errFrom := strconv.Atoi(c.PostForm("from_account"))
+	toID, errTo := strconv.Atoi(c.PostForm("to_account"))
+	if errFrom != nil || errTo != nil {
+		c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Выберите счета")
+		return
+	}
+	if fromID == toID {
+		c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Счета перевода должны различаться")
+		return
+	}
+
+	dateTime, errMsg := parseTransactionDateTime(c.PostForm("datetime"), c.PostForm("tz_offset"), time.Now())
+	if errMsg != "" {
+		c.Redirect(http.StatusFound, "/accounts?message="+errMsg)
+		return
+	}
+	description := strings.TrimSpace(c.PostForm("description"))
+	notes := c.PostForm("notes")
+
+	err = h.financeStore.Change(requestActor(c), storage.UndoAdd, func(data *models.FinanceData) error {
+		accounts := accountMap(data.Accounts)
+		from, okFrom := accounts[fromID]
+		to, okTo := accounts[toID]
+		if !okFrom || !okTo {
+			return formError("Ошибка: Счёт не найден")
+		}
+		if from.Archived || to.Archived {
+			return formError("Ошибка: Счёт в архиве")
+		}
+		if from.Currency != to.Currency {
+			return formError("Ошибка: Валюты счетов различаются")
+		}
+
+		if description == "" {
+			description = fmt.Sprintf("Перевод: %s → %s", from.Name, to.Name)
+		}
+
+		outID := storage.NextTransactionID(data)
+		inID := outID + 1
+		data.Transactions = append(data.Transactions,
+			models.Transaction{
+				ID:          outID,
+				Amount:      amount,
+				Description: description,
+				DateTime:    dateTime,
+				IsPositive:  false,
+				Currency:    from.Currency,
+				Notes:       notes,
+				AccountID:   from.ID,
+				Kind:        models.KindTransfer,
+				LinkedID:    inID,
+			},
+			models.Transaction{
+				ID:          inID,
+				Amount:      amount,
+				Description: description,
+				DateTime:    dateTime,
+				IsPositive:  true,
+				Currency:    to.Currency,
+				Notes:       notes,
+				AccountID:   to.ID,
+				Kind:        models.KindTransfer,
+				LinkedID:    outID,
+			},
+		)
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/accounts?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/accounts?message=Перевод выполнен")
+}
+
+// formatExchangeRate показывает курс обмена в удобной записи «1 USD = 3.2500 BYN»,
+// где слева стоит более дорогая валюта
+func formatExchangeRate(sourceAmount float64, sourceCurrency string, targetAmount float64, targetCurrency string) string {
+	if sourceAmount <= 0 || targetAmount <= 0 {
+		return ""
+	}
+	if targetAmount >= sourceAmount {
+		return fmt.Sprintf("1 %s = %.4f %s", sourceCurrency, targetAmount/sourceAmount, targetCurrency)
+	}
+	return fmt.Sprintf("1 %s = %.4f %s", targetCurrency, sourceAmount/targetAmount, sourceCurrency)
+}
+
+// Exchange обменивает валюту между кошельками с разными валютами. Как и
+// перевод, обмен хранится парой связанных операций: расход исходной суммы со
+// счёта-источника и доход полученной суммы на счёт-получатель. Обе половины
+// хранят фактический курс обмена и не учитываются в доходах и расходах. Как и
+// перевод, обмен отменяется целиком.
+func (h *AccountHandler) Exchange(c *gin.Context) {
+	sourceAmount, err := strconv.ParseFloat(c.PostForm("source_amount"), 64)
+	if err != nil || sourceAmount <= 0 {
+		c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Неверная отдаваемая сумма")
+		return
+	}
+	targetAmount, err := strconv.ParseFloat(c.PostForm("target_amount"), 64)
+	if err != nil || targetAmount <= 0 {
+		c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Неверная получаемая сумма")
+		return
+	}
+	fromID, errFrom := strconv.Atoi(c.PostForm("from_account"))
+	toID, errTo := strconv.Atoi(c.PostForm("to_account"))
+	if errFrom != nil || errTo != nil {
+		c.Redirect(http.StatusFound, "/accounts?message=Ошибка: Выберите счета")
+		return
+	}
+
+	dateTime, errMsg := parseTransactionDateTime(c.PostForm("datetime"), c.PostForm("tz_offset"), time.Now())
+	if errMsg != "" {
+		c.Redirect(http.StatusFound, "/accounts?message="+errMsg)
+		return
+	}
+	description := strings.TrimSpace(c.PostForm("description"))
+	notes := c.PostForm("notes")
+	rate := targetAmount / sourceAmount
+
+	var from, to models.Account
+	err = h.financeStore.Change(requestActor(c), storage.UndoAdd, func(data *models.FinanceData) error {
+		accounts := accountMap(data.Accounts)
+		var okFrom, okTo bool
+		from, okFrom = accounts[fromID]
+		to, okTo = accounts[toID]
+		if !okFrom || !okTo {
+			return formError("Ошибка: Счёт не найден")
+		}
+		if from.Archived || to.Archived {
+			return formError("Ошибка: Счёт в архиве")
+		}
+		if from.Currency == to.Currency {
+			return formError("Ошибка: Для счетов одной валюты используйте перевод")
+		}
+
+		if description == "" {
+			description = fmt.Sprintf("Обмен: %.2f %s → %.2f %s", sourceAmount, from.Currency, targetAmount, to.Currency)
+		}
+
+		outID := storage.NextTransactionID(data)
+		inID := outID + 1
+		data.Transactions = append(data.Transactions,
+			models.Transaction{
+				ID:          outID,
+				Amount:      sourceAmount,
+				Description: description,
+				DateTime:    dateTime,
+				IsPositive:  false,
+				Currency:    from.Currency,
+				Notes:       notes,
+				AccountID:   from.ID,
+				Kind:        models.KindExchange,
+				LinkedID:    inID,
+				Rate:        rate,
+			},
+			models.Transaction{
+				ID:          inID,
+				Amount:      targetAmount,
+				Description: description,
+				DateTime:    dateTime,
+				IsPositive:  true,
+				Currency:    to.Currency,
+				Notes:       notes,
+				AccountID:   to.ID,
+				Kind:        models.KindExchange,
+				LinkedID:    outID,
+				Rate:        rate,
+			},
+		)
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/accounts?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/accounts?message=Обмен выполнен: "+formatExchangeRate(sourceAmount, from.Currency, targetAmount, to.Currency))
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"encoding/json"
+	"finance-tracker/storage"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const auditPageSize = 50
+
+type AuditHandler struct {
+	audit *storage.AuditLog
+}
+
+func NewAuditHandler(audit *storage.AuditLog) *AuditHandler {
+	return &AuditHandler{audit: audit}
+}
+
+// auditActions — подписи действий в журнале
+var auditActions = map[string]string{
+	storage.AuditCreate:  "Создание",
+	storage.AuditUpdate:  "Изменение",
+	storage.AuditDelete:  "Удаление в корзину",
+	storage.AuditRestore: "Восстановление",
+	storage.AuditPurge:   "Окончательное удаление",
+}
+
+// auditSources — подписи источников изменений
+var auditSources = map[string]string{
+	storage.SourceWeb:       "Веб",
+	storage.SourceAPI:       "API",
+	storage.SourceImport:    "Импорт",
+	storage.SourceScheduler: "Планировщик",
+}
+
+// auditFields — подписи полей операций, записей табеля, справочников и настроек. Поля, которых нет в
+// списке, показываются под своими именами.
+var auditFields = map[string]string{
+	"Amount":      "Сумма",
+	"Description": "Описание",
+	"DateTime":    "Дата",
+	"IsPositive":  "Доход",
+	"Currency":    "Валюта",
+	"Notes":       "Заметки",
+	"CategoryID":  "Категория",
+	"RecurringID": "Регулярная операция",
+	"AccountID":   "Счёт",
+	"Kind":        "Вид",
+	"LinkedID":    "Парная операция",
+	"Rate":        "Курс",
+	"Date":        "Дата",
+	"Place":       "Место",
+	"StartTime":   "Начало",
+	"EndTime":     "Окончание",
+	"IsDayOff":    "Выходной",
+	"Sessions":    "Отрезки",
+	"Breaks":      "Перерывы",
+	// Справочники и настройки
+	"Name":               "Название",
+	"Icon":               "Значок",
+	"IsIncome":           "Категория доходов",
+	"Type":               "Тип",
+	"OpeningBalance":     "Начальный остаток",
+	"Archived":           "В архиве",
+	"BankAccount":        "Счёт в банке",
+	"Limit":              "Лимит",
+	"Frequency":          "Периодичность",
+	"StartDate":          "Первое повторение",
+	"EndDate":            "Дата окончания",
+	"Count":              "Число повторений",
+	"Generated":          "Создано повторений",
+	"Paused":             "Приостановлена",
+	"BaseCurrency":       "Базовая валюта",
+	"LunchMinutes":       "Обед, мин",
+	"LunchAfterHours":    "Обед, если больше, ч",
+	"DailyNormHours":     "Норма в день, ч",
+	"WeeklyNormHours":    "Норма в неделю, ч",
+	"OvertimeMultiplier": "Коэффициент переработки",
+	"NightStart":         "Начало ночных часов",
+	"NightEnd":           "Окончание ночных часов",
+	"NightMultiplier":    "Коэффициент ночных часов",
+	"WeekendMultiplier":  "Коэффициент выходных",
+}
+
+// auditKinds — подписи видов записей журнала для фильтра
+var auditKinds = map[string]string{
+	"transaction":              "Операции",
+	"work":                     "Табель",
+	"account":                  "Счета",
+	"category":                 "Категории",
+	"budget":                   "Бюджеты",
+	"recurring":                "Регулярные операции",
+	"rate":                     "Курсы валют",
+	storage.BaseCurrencyRecord: "Базовая валюта",
+	storage.WorkRulesRecord:    "Правила учёта времени",
+}
+
+// auditFieldChanges сравнивает записи до и после изменения по полям. Для
+// созданной или окончательно удалённой записи перечисляются все её поля.
+func auditFieldChanges(oldJSON, newJSON json.RawMessage) []gin.H {
+	var oldValues, newValues map[string]interface{}
+	json.Unmarshal(oldJSON, &oldValues)
+	json.Unmarshal(newJSON, &newValues)
+
+	keys := []string{}
+	for key := range oldValues {
+		keys = append(keys, key)
+	}
+	for key := range newValues {
+		if _, ok := oldValues[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := []gin.H{}
+	for _, key := range keys {
+		oldValue, hadOld := oldValues[key]
+		newValue, hasNew := newValues[key]
+		if hadOld && hasNew && fmt.Sprint(oldValue) == fmt.Sprint(newValue) {
+			continue
+		}
+		name := auditFields[key]
+		if name == "" {
+			name = key
+		}
+		change := gin.H{"Field": name}
+		if hadOld {
+			change["Old"] = formatAuditValue(oldValue)
+		}
+		if hasNew {
+			change["New"] = formatAuditValue(newValue)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// formatAuditValue возвращает значение поля в читаемом виде
+func formatAuditValue(value interface{}) string {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "да"
+		}
+		return "нет"
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			if t.IsZero() {
+				return "—"
+			}
+			return t.Format("02.01.2006 15:04")
+		}
+		if v == "" {
+			return "—"
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		// Отрезки работы за день: «08:00–12:00 Место; 13:00–17:00 Место»,
+		// перерывы: «12:00–12:45; 15 мин»
+		parts := []string{}
+		for _, item := range v {
+			fields, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Sprint(value)
+			}
+			_, isSession := fields["Place"]
+			_, hasTime := fields["StartTime"]
+			switch {
+			case isSession:
+				parts = append(parts, fmt.Sprintf("%v–%v %v", fields["StartTime"], fields["EndTime"], fields["Place"]))
+			case hasTime:
+				parts = append(parts, fmt.Sprintf("%v–%v", fields["StartTime"], fields["EndTime"]))
+			default:
+				parts = append(parts, fmt.Sprintf("%v мин", fields["Minutes"]))
+			}
+		}
+		return strings.Join(parts, "; ")
+	}
+	return fmt.Sprint(value)
+}
+
+// auditRecordLink возвращает подпись записи журнала и ссылку на её историю
+func auditRecordLink(record string) (string, string) {
+	link := "/history?record=" + url.QueryEscape(record)
+	kind, key, _ := strings.Cut(record, "/")
+	switch kind {
+	case "transaction":
+		return "Операция #" + key, link
+	case "work":
+		return "Табель за " + key, link
+	case "account":
+		return "Счёт #" + key, link
+	case "category":
+		return "Категория #" + key, link
+	case "budget":
+		return "Бюджет #" + key, link
+	case "recurring":
+		return "Регулярная операция #" + key, link
+	case "rate":
+		currency, date, _ := strings.Cut(key, "/")
+		return fmt.Sprintf("Курс %s на %s", currency, date), link
+	case storage.BaseCurrencyRecord, storage.WorkRulesRecord:
+		return auditKinds[kind], link
+	}
+	return record, link
+}
+
+func formatAuditEntries(entries []storage.AuditEntry) []gin.H {
+	items := make([]gin.H, len(entries))
+	for i, e := range entries {
+		title, link := auditRecordLink(e.Record)
+		items[i] = gin.H{
+			"ID":      e.ID,
+			"Time":    e.Time.Format("02.01.2006 15:04:05"),
+			"User":    e.User,
+			"Source":  auditSources[e.Source],
+			"Action":  auditActions[e.Action],
+			"Record":  title,
+			"Link":    link,
+			"Changes": auditFieldChanges(e.Old, e.New),
+		}
+	}
+	return items
+}
+
+// Activity показывает общую ленту изменений с фильтрами по виду записей,
+// источнику и действию
+func (h *AuditHandler) Activity(c *gin.Context) {
+	filter := storage.AuditFilter{
+		Kind:   c.Query("kind"),
+		Source: c.Query("source"),
+		Action: c.Query("action"),
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	entries, total := h.audit.Query(filter, (page-1)*auditPageSize, auditPageSize)
+
+	query := url.Values{}
+	for key, value := range map[string]string{"kind": filter.Kind, "source": filter.Source, "action": filter.Action} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	pageLink := func(page int) string {
+		query.Set("page", strconv.Itoa(page))
+		return "/activity?" + query.Encode()
+	}
+	view := gin.H{
+		"Title":   "Журнал изменений",
+		"Entries": formatAuditEntries(entries),
+		"Total":   total,
+		"Filter":  filter,
+		"Feed":    true,
+		"Kinds":   auditKindOptions(),
+		"Actions": auditActions,
+		"Sources": auditSources,
+	}
+	if page > 1 {
+		view["PrevPage"] = pageLink(page - 1)
+	}
+	if page*auditPageSize < total {
+		view["NextPage"] = pageLink(page + 1)
+	}
+
+	c.HTML(http.StatusOK, "activity.html", view)
+}
+
+// auditKindOptions возвращает виды записей для фильтра в порядке storage.AuditKinds
+func auditKindOptions() []gin.H {
+	options := make([]gin.H, len(storage.AuditKinds))
+	for i, kind := range storage.AuditKinds {
+		options[i] = gin.H{"Value": kind, "Title": auditKinds[kind]}
+	}
+	return options
+}
+
+// History показывает историю одной операции или записи табеля
+func (h *AuditHandler) History(c *gin.Context) {
+	record := c.Query("record")
+	if record == "" {
+		c.Redirect(http.StatusFound, "/activity")
+		return
+	}
+
+	entries, total := h.audit.Query(storage.AuditFilter{Record: record}, 0, 0)
+	title, _ := auditRecordLink(record)
+	c.HTML(http.StatusOK, "activity.html", gin.H{
+		"Title":   "История: " + title,
+		"Entries": formatAuditEntries(entries),
+		"Total":   total,
+	})
+}
+
+// apiAuditList отвечает страницей записей журнала. Пагинация limit/offset.
+func (h *AuditHandler) apiAuditList(c *gin.Context, filter storage.AuditFilter) {
+	var errs []apiFieldError
+	limit, offset := apiDefaultLimit, 0
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > apiMaxLimit {
+			errs = append(errs, apiFieldError{Field: "limit", Message: fmt.Sprintf("Ожидается число от 1 до %d", apiMaxLimit)})
+		}
+		limit = n
+	}
+	if value := c.Query("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			errs = append(errs, apiFieldError{Field: "offset", Message: "Ожидается неотрицательное целое число"})
+		}
+		offset = n
+	}
+	if filter.Kind != "" && auditKinds[filter.Kind] == "" {
+		errs = append(errs, apiFieldError{Field: "kind", Message: "Допустимые значения: " + strings.Join(storage.AuditKinds, ", ")})
+	}
+	if filter.Source != "" && auditSources[filter.Source] == "" {
+		errs = append(errs, apiFieldError{Field: "source", Message: "Допустимые значения: web, api, import, scheduler"})
+	}
+	if filter.Action != "" && auditActions[filter.Action] == "" {
+		errs = append(errs, apiFieldError{Field: "action", Message: "Допустимые значения: create, update, delete, restore, purge"})
+	}
+	if len(errs) > 0 {
+		apiAbort(c, http.StatusBadRequest, "invalid_query", "Некорректные параметры запроса", errs...)
+		return
+	}
+
+	items, total := h.audit.Query(filter, offset, limit)
+	c.JSON(http.StatusOK, gin.H{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// APIAudit — GET /api/v1/audit. Фильтры: record, kind, source, action; новые
+// записи сверху.
+func (h *AuditHandler) APIAudit(c *gin.Context) {
+	h.apiAuditList(c, storage.AuditFilter{
+		Record: c.Query("record"),
+		Kind:   c.Query("kind"),
+		Source: c.Query("source"),
+		Action: c.Query("action"),
+	})
+}
+
+// APITransactionHistory — GET /api/v1/transactions/:id/history
+func (h *AuditHandler) APITransactionHistory(c *gin.Context) {
+	id, ok := apiTransactionID(c)
+	if !ok {
+		return
+	}
+	h.apiAuditList(c, storage.AuditFilter{Record: storage.TransactionRecord(id)})
+}
+
+// APIWorkEntryHistory — GET /api/v1/worklog/:date/history
+func (h *AuditHandler) APIWorkEntryHistory(c *gin.Context) {
+	date, ok := apiWorkEntryDate(c)
+	if !ok {
+		return
+	}
+	h.apiAuditList(c, storage.AuditFilter{Record: storage.WorkEntryRecord(date)})
+}
@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"errors"
+	"finance-tracker/models"
+	"finance-tracker/storage"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BackupHandler struct {
+	financeStore *storage.FinanceStorage
+	workLogStore *storage.WorkLogStorage
+}
+
+func NewBackupHandler(financeStore *storage.FinanceStorage, workLogStore *storage.WorkLogStorage) *BackupHandler {
+	return &BackupHandler{financeStore: financeStore, workLogStore: workLogStore}
+}
+
+// backupReasons — подписи причин создания копии
+var backupReasons = map[string]string{
+	storage.BackupAuto:       "Автоматическая",
+	storage.BackupManual:     "Вручную",
+	storage.BackupPreRestore: "Перед восстановлением",
+	storage.BackupPreDelete:  "Перед удалением",
+}
+
+// backupStoreTitles — названия хранилищ для страницы копий
+var backupStoreTitles = map[string]string{
+	storage.BackupFinance: "Финансы",
+	storage.BackupWorkLog: "Табель",
+}
+
+// formatSize возвращает размер файла в удобных единицах
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f МБ", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f КБ", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d Б", size)
+	}
+}
+
+func formatBackups(list []storage.BackupInfo) []gin.H {
+	items := make([]gin.H, len(list))
+	for i, b := range list {
+		items[i] = gin.H{
+			"Name":    b.Name,
+			"Store":   b.Store,
+			"Created": b.Created.Format("02.01.2006 15:04:05"),
+			"Reason":  backupReasons[b.Reason],
+			"Records": b.Records,
+			"Size":    formatSize(b.Size),
+		}
+	}
+	return items
+}
+
+func formatDiffTransaction(t models.Transaction) gin.H {
+	return gin.H{
+		"ID":          t.ID,
+		"Amount":      fmt.Sprintf("%.2f", t.Amount),
+		"Currency":    t.Currency,
+		"Description": t.Description,
+		"DateTime":    t.DateTime.Format("02.01.2006 15:04"),
+		"IsPositive":  t.IsPositive,
+	}
+}
+
+func formatDiffWorkEntry(e models.WorkEntry) gin.H {
+	return gin.H{
+		"Date":      e.Date,
+		"Place":     e.Place,
+		"StartTime": e.StartTime,
+		"EndTime":   e.EndTime,
+		"IsDayOff":  e.IsDayOff,
+	}
+}
+
+// backupDiffView готовит сравнение копии с текущими данными для шаблона
+func (h *BackupHandler) backupDiffView(store, name string) (gin.H, error) {
+	switch store {
+	case storage.BackupFinance:
+		info, diff, err := h.financeStore.DiffBackup(name)
+		if err != nil {
+			return nil, err
+		}
+		view := gin.H{
+			"Store":    store,
+			"Title":    backupStoreTitles[store],
+			"Backup":   formatBackups([]storage.BackupInfo{info})[0],
+			"Empty":    diff.Empty(),
+			"Sections": diff.Sections,
+		}
+		added, removed, changed := []gin.H{}, []gin.H{}, []gin.H{}
+		for _, t := range diff.Added {
+			added = append(added, formatDiffTransaction(t))
+		}
+		for _, t := range diff.Removed {
+			removed = append(removed, formatDiffTransaction(t))
+		}
+		for _, ch := range diff.Changed {
+			changed = append(changed, gin.H{
+				"Backup":  formatDiffTransaction(ch.Backup),
+				"Current": formatDiffTransaction(ch.Current),
+			})
+		}
+		view["Added"], view["Removed"], view["Changed"] = added, removed, changed
+		return view, nil
+	case storage.BackupWorkLog:
+		info, diff, err := h.workLogStore.DiffBackup(name)
+		if err != nil {
+			return nil, err
+		}
+		view := gin.H{
+			"Store":  store,
+			"Title":  backupStoreTitles[store],
+			"Backup": formatBackups([]storage.BackupInfo{info})[0],
+			"Empty":  diff.Empty(),
+		}
+		added, removed, changed := []gin.H{}, []gin.H{}, []gin.H{}
+		for _, e := range diff.Added {
+			added = append(added, formatDiffWorkEntry(e))
+		}
+		for _, e := range diff.Removed {
+			removed = append(removed, formatDiffWorkEntry(e))
+		}
+		for _, ch := range diff.Changed {
+			changed = append(changed, gin.H{
+				"Backup":  formatDiffWorkEntry(ch.Backup),
+				"Current": formatDiffWorkEntry(ch.Current),
+			})
+		}
+		view["Added"], view["Removed"], view["Changed"] = added, removed, changed
+		return view, nil
+	}
+	return nil, storage.ErrNotFound
+}
+
+// Backups показывает копии обоих хранилищ и, если выбрана копия, её отличия
+// от текущих данных
+func (h *BackupHandler) Backups(c *gin.Context) {
+	financeBackups, err := h.financeStore.ListBackups()
+	if err != nil && !errors.Is(err, storage.ErrBackupsDisabled) {
+		c.String(http.StatusInternalServerError, "Ошибка получения списка резервных копий")
+		return
+	}
+	workLogBackups, err := h.workLogStore.ListBackups()
+	if err != nil && !errors.Is(err, storage.ErrBackupsDisabled) {
+		c.String(http.StatusInternalServerError, "Ошибка получения списка резервных копий")
+		return
+	}
+
+	view := gin.H{
+		"Stores": []gin.H{
+			{"Title": backupStoreTitles[storage.BackupFinance], "Items": formatBackups(financeBackups)},
+			{"Title": backupStoreTitles[storage.BackupWorkLog], "Items": formatBackups(workLogBackups)},
+		},
+	}
+	if name := c.Query("name"); name != "" {
+		diff, err := h.backupDiffView(c.Query("store"), name)
+		if err != nil {
+			c.Redirect(http.StatusFound, "/backups?message="+url.QueryEscape(backupErrorMessage(err)))
+			return
+		}
+		view["Diff"] = diff
+	}
+
+	c.HTML(http.StatusOK, "backups.html", view)
+}
+
+// backupErrorMessage возвращает текст уведомления об ошибке работы с копиями
+func backupErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return "Ошибка: Резервная копия не найдена"
+	case errors.Is(err, storage.ErrBackupsDisabled):
+		return "Ошибка: Резервное копирование не настроено"
+	}
+	return "Ошибка: " + err.Error()
+}
+
+// createBackup создаёт копию хранилища store по запросу пользователя
+func (h *BackupHandler) createBackup(store string) (storage.BackupInfo, error) {
+	switch store {
+	case storage.BackupFinance:
+		return h.financeStore.CreateBackup()
+	case storage.BackupWorkLog:
+		return h.workLogStore.CreateBackup()
+	}
+	return storage.BackupInfo{}, storage.ErrNotFound
+}
+
+// restoreBackup восстанавливает хранилище store из копии name
+func (h *BackupHandler) restoreBackup(actor storage.Actor, store, name string) (storage.BackupInfo, error) {
+	switch store {
+	case storage.BackupFinance:
+		return h.financeStore.RestoreBackup(actor, name)
+	case storage.BackupWorkLog:
+		return h.workLogStore.RestoreBackup(actor, name)
+	}
+	return storage.BackupInfo{}, storage.ErrNotFound
+}
+
+func (h *BackupHandler) CreateBackup(c *gin.Context) {
+	stores := []string{storage.BackupFinance, storage.BackupWorkLog}
+	if store := c.PostForm("store"); store != "" {
+		stores = []string{store}
+	}
+	for _, store := range stores {
+		if _, err := h.createBackup(store); err != nil {
+			c.Redirect(http.StatusFound, "/backups?message="+url.QueryEscape(backupErrorMessage(err)))
+			return
+		}
+	}
+	c.Redirect(http.StatusFound, "/backups?message=Резервная копия создана")
+}
+
+func (h *BackupHandler) RestoreBackup(c *gin.Context) {
+	safety, err := h.restoreBackup(requestActor(c), c.PostForm("store"), c.PostForm("name"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/backups?message="+url.QueryEscape(backupErrorMessage(err)))
+		return
+	}
+	c.Redirect(http.StatusFound, "/backups?message="+url.QueryEscape(
+		"Данные восстановлены. Прежнее состояние сохранено в копии от "+safety.Created.Format("02.01.2006 15:04:05")))
+}
+
+// apiBackup — представление резервной копии в API
+type apiBackup struct {
+	Name    string    `json:"name"`
+	Store   string    `json:"store"` // "finance" или "worklog"
+	Created time.Time `json:"created"`
+	Reason  string    `json:"reason"` // "auto", "manual", "pre-restore" или "pre-delete"
+	Records int       `json:"records"`
+	Size    int64     `json:"size"`
+}
+
+func toAPIBackup(b storage.BackupInfo) apiBackup {
+	return apiBackup{
+		Name:    b.Name,
+		Store:   b.Store,
+		Created: b.Created,
+		Reason:  b.Reason,
+		Records: b.Records,
+		Size:    b.Size,
+	}
+}
+
+func toAPIBackups(list []storage.BackupInfo) []apiBackup {
+	result := make([]apiBackup, len(list))
+	for i, b := range list {
+		result[i] = toAPIBackup(b)
+	}
+	return result
+}
+
+// apiBackupFailed отвечает на ошибку работы с копиями
+func apiBackupFailed(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		apiAbort(c, http.StatusNotFound, "not_found", "Резервная копия не найдена")
+	case errors.Is(err, storage.ErrBackupsDisabled):
+		apiAbort(c, http.StatusServiceUnavailable, "backups_disabled", "Резервное копирование не настроено")
+	default:
+		apiAbort(c, http.StatusInternalServerError, "backup_failed", err.Error())
+	}
+}
+
+// APIListBackups возвращает копии обоих хранилищ или только ?store=
+func (h *BackupHandler) APIListBackups(c *gin.Context) {
+	store := c.Query("store")
+	if store != "" && backupStoreTitles[store] == "" {
+		apiAbort(c, http.StatusBadRequest, "invalid_query", "Неизвестное хранилище: "+store)
+		return
+	}
+
+	result := gin.H{}
+	if store == "" || store == storage.BackupFinance {
+		list, err := h.financeStore.ListBackups()
+		if err != nil {
+			apiBackupFailed(c, err)
+			return
+		}
+		result[storage.BackupFinance] = toAPIBackups(list)
+	}
+	if store == "" || store == storage.BackupWorkLog {
+		list, err := h.workLogStore.ListBackups()
+		if err != nil {
+			apiBackupFailed(c, err)
+			return
+		}
+		result[storage.BackupWorkLog] = toAPIBackups(list)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// APICreateBackup создаёт копии обоих хранилищ или только ?store=
+func (h *BackupHandler) APICreateBackup(c *gin.Context) {
+	stores := []string{storage.BackupFinance, storage.BackupWorkLog}
+	if store := c.Query("store"); store != "" {
+		if backupStoreTitles[store] == "" {
+			apiAbort(c, http.StatusBadRequest, "invalid_query", "Неизвестное хранилище: "+store)
+			return
+		}
+		stores = []string{store}
+	}
+
+	created := []apiBackup{}
+	for _, store := range stores {
+		info, err := h.createBackup(store)
+		if err != nil {
+			apiBackupFailed(c, err)
+			return
+		}
+		created = append(created, toAPIBackup(info))
+	}
+	c.JSON(http.StatusCreated, gin.H{"items": created})
+}
+
+// APIBackupDiff сравнивает копию с текущими данными. added — записи, которые
+// пропадут при восстановлении, removed — записи, которые вернутся.
+func (h *BackupHandler) APIBackupDiff(c *gin.Context) {
+	store, name := c.Param("store"), c.Param("name")
+	now := time.Now()
+
+	switch store {
+	case storage.BackupFinance:
+		info, diff, err := h.financeStore.DiffBackup(name)
+		if err != nil {
+			apiBackupFailed(c, err)
+			return
+		}
+		added, removed := []apiTransaction{}, []apiTransaction{}
+		for _, t := range diff.Added {
+			added = append(added, toAPITransaction(t, now))
+		}
+		for _, t := range diff.Removed {
+			removed = append(removed, toAPITransaction(t, now))
+		}
+		changed := []gin.H{}
+		for _, ch := range diff.Changed {
+			changed = append(changed, gin.H{
+				"backup":  toAPITransaction(ch.Backup, now),
+				"current": toAPITransaction(ch.Current, now),
+			})
+		}
+		sections := diff.Sections
+		if sections == nil {
+			sections = []string{}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"backup":   toAPIBackup(info),
+			"added":    added,
+			"removed":  removed,
+			"changed":  changed,
+			"sections": sections,
+		})
+	case storage.BackupWorkLog:
+		info, diff, err := h.workLogStore.DiffBackup(name)
+		if err != nil {
+			apiBackupFailed(c, err)
+			return
+		}
+		workTime := h.workLogStore.WorkTime()
+		added, removed := []apiWorkEntry{}, []apiWorkEntry{}
+		for _, e := range diff.Added {
+			added = append(added, toAPIWorkEntry(e, workTime))
+		}
+		for _, e := range diff.Removed {
+			removed = append(removed, toAPIWorkEntry(e, workTime))
+		}
+		changed := []gin.H{}
+		for _, ch := range diff.Changed {
+			changed = append(changed, gin.H{
+				"backup":  toAPIWorkEntry(ch.Backup, workTime),
+				"current": toAPIWorkEntry(ch.Current, workTime),
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"backup":  toAPIBackup(info),
+			"added":   added,
+			"removed": removed,
+			"changed": changed,
+		})
+	default:
+		apiAbort(c, http.StatusNotFound, "not_found", "Неизвестное хранилище: "+store)
+	}
+}
+
+// APIRestoreBackup восстанавливает хранилище из копии и возвращает
+// страховочную копию, сделанную перед восстановлением
+func (h *BackupHandler) APIRestoreBackup(c *gin.Context) {
+	safety, err := h.restoreBackup(requestActor(c), c.Param("store"), c.Param("name"))
+	if err != nil {
+		apiBackupFailed(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pre_restore": toAPIBackup(safety)})
+}
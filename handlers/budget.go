@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"finance-tracker/models"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// budgetWarningShare — доля лимита, после которой бюджет считается почти исчерпанным
+const budgetWarningShare = 0.8
+
+// budgetProgress считает фактические расходы по каждому бюджету за месяц,
+// начинающийся с monthStart
+func budgetProgress(data *models.FinanceData, monthStart time.Time) []gin.H {
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	categories := categoryMap(data.Categories)
+	now := time.Now()
+
+	spent := make(map[string]float64)
+	for _, t := range data.Transactions {
+		if t.IsPositive || t.CategoryID == 0 || t.IsPlanned(now) || t.IsInternal() {
+			continue
+		}
+		if t.DateTime.Before(monthStart) || !t.DateTime.Before(monthEnd) {
+			continue
+		}
+		spent[fmt.Sprintf("%d/%s", t.CategoryID, t.Currency)] += t.Amount
+	}
+
+	result := []gin.H{}
+	for _, b := range data.Budgets {
+		cat := categories[b.CategoryID]
+		actual := spent[fmt.Sprintf("%d/%s", b.CategoryID, b.Currency)]
+		percent := 0.0
+		if b.Limit > 0 {
+			percent = actual / b.Limit * 100
+		}
+		barWidth := percent
+		if barWidth > 100 {
+			barWidth = 100
+		}
+		result = append(result, gin.H{
+			"ID":           b.ID,
+			"CategoryID":   b.CategoryID,
+			"Category":     cat.Name,
+			"CategoryIcon": cat.Icon,
+			"Currency":     b.Currency,
+			"Limit":        b.Limit,
+			"Spent":        actual,
+			"Remaining":    b.Limit - actual,
+			"Overspend":    actual - b.Limit,
+			"Percent":      percent,
+			"BarWidth":     barWidth,
+			"IsOver":       actual > b.Limit,
+			"IsWarning":    actual <= b.Limit && actual >= b.Limit*budgetWarningShare,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i]["Percent"].(float64) > result[j]["Percent"].(float64)
+	})
+	return result
+}
+
+// budgetInsights формирует подсказки по перерасходу бюджетов. Для текущего
+// месяца дополнительно сравнивается темп расходов с прошедшей частью месяца.
+func budgetInsights(progress []gin.H, monthStart, now time.Time) []string {
+	insights := []string{}
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	elapsedShare := -1.0
+	if !now.Before(monthStart) && now.Before(monthEnd) {
+		elapsedShare = now.Sub(monthStart).Hours() / monthEnd.Sub(monthStart).Hours()
+	}
+
+	for _, p := range progress {
+		name := p["Category"].(string)
+		currency := p["Currency"].(string)
+		limit := p["Limit"].(float64)
+		spent := p["Spent"].(float64)
+		switch {
+		case p["IsOver"].(bool):
+			insights = append(insights, fmt.Sprintf("Бюджет «%s» превышен на %.2f %s (%.0f%% от лимита %.2f %s).",
+				name, spent-limit, currency, p["Percent"].(float64), limit, currency))
+		case p["IsWarning"].(bool):
+			insights = append(insights, fmt.Sprintf("Бюджет «%s» израсходован на %.0f%%, осталось %.2f %s.",
+				name, p["Percent"].(float64), limit-spent, currency))
+		case elapsedShare > 0 && limit > 0 && spent/limit > elapsedShare+0.1:
+			insights = append(insights, fmt.Sprintf("Расходы по «%s» опережают план: потрачено %.0f%% бюджета за %.0f%% месяца.",
+				name, spent/limit*100, elapsedShare*100))
+		}
+	}
+	return insights
+}
+
+func (h *CategoryHandler) AddBudget(c *gin.Context) {
+	categoryID, err := strconv.Atoi(c.PostForm("category"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/categories?message=Ошибка: Выберите категорию")
+		return
+	}
+	currency := c.PostForm("currency")
+	if currency == "" {
+		c.Redirect(http.StatusFound, "/categories?message=Ошибка: Выберите валюту")
+		return
+	}
+	limit, err := strconv.ParseFloat(c.PostForm("limit"), 64)
+	if err != nil || limit <= 0 {
+		c.Redirect(http.StatusFound, "/categories?message=Ошибка: Неверный лимит")
+		return
+	}
+
+	replaced := false
+	err = h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		cat, ok := categoryMap(data.Categories)[categoryID]
+		if !ok || cat.IsIncome {
+			return formError("Ошибка: Бюджет можно задать только для категории расходов")
+		}
+
+		// Повторный бюджет для той же категории и валюты заменяет лимит
+		newID := 1
+		for i, b := range data.Budgets {
+			if b.CategoryID == categoryID && b.Currency == currency {
+				data.Budgets[i].Limit = limit
+				replaced = true
+				return nil
+			}
+			if b.ID >= newID {
+				newID = b.ID + 1
+			}
+		}
+
+		data.Budgets = append(data.Budgets, models.Budget{
+			ID:         newID,
+			CategoryID: categoryID,
+			Currency:   currency,
+			Limit:      limit,
+		})
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/categories?message="+errorMessage(err))
+		return
+	}
+
+	if replaced {
+		c.Redirect(http.StatusFound, "/categories?message=Бюджет обновлён")
+		return
+	}
+	c.Redirect(http.StatusFound, "/categories?message=Бюджет добавлен")
+}
+
+func (h *CategoryHandler) DeleteBudget(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/categories?message=Ошибка: Неверный ID бюджета")
+		return
+	}
+
+	err = h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		for i, b := range data.Budgets {
+			if b.ID == id {
+				data.Budgets = append(data.Budgets[:i], data.Budgets[i+1:]...)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/categories?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/categories?message=Бюджет удалён")
+}
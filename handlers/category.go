@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"finance-tracker/models"
+	"finance-tracker/storage"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryHandler struct {
+	financeStore *storage.FinanceStorage
+}
+
+func NewCategoryHandler(financeStore *storage.FinanceStorage) *CategoryHandler {
+	return &CategoryHandler{financeStore: financeStore}
+}
+
+// categoryMap строит индекс категорий по ID
+func categoryMap(categories []models.Category) map[int]models.Category {
+	result := make(map[int]models.Category, len(categories))
+	for _, cat := range categories {
+		result[cat.ID] = cat
+	}
+	return result
+}
+
+// parseCategoryID разбирает ID категории из формы и проверяет, что категория
+// существует и подходит к типу операции. Пустое значение означает "без категории".
+func parseCategoryID(value string, categories []models.Category, isPositive bool) (int, string) {
+	if value == "" || value == "0" {
+		return 0, ""
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, "Ошибка: Неверная категория"
+	}
+	cat, ok := categoryMap(categories)[id]
+	if !ok {
+		return 0, "Ошибка: Категория не найдена"
+	}
+	if cat.IsIncome != isPositive {
+		return 0, "Ошибка: Категория не соответствует типу операции"
+	}
+	return id, ""
+}
+
+func (h *CategoryHandler) Categories(c *gin.Context) {
+	data := h.financeStore.Snapshot()
+
+	// Считаем количество операций в каждой категории
+	usage := make(map[int]int)
+	for _, t := range data.Transactions {
+		usage[t.CategoryID]++
+	}
+
+	var incomeCategories, expenseCategories []gin.H
+	for _, cat := range data.Categories {
+		item := gin.H{
+			"ID":       cat.ID,
+			"Name":     cat.Name,
+			"Icon":     cat.Icon,
+			"IsIncome": cat.IsIncome,
+			"Count":    usage[cat.ID],
+		}
+		if cat.IsIncome {
+			incomeCategories = append(incomeCategories, item)
+		} else {
+			expenseCategories = append(expenseCategories, item)
+		}
+	}
+
+	byName := func(list []gin.H) func(i, j int) bool {
+		return func(i, j int) bool {
+			return list[i]["Name"].(string) < list[j]["Name"].(string)
+		}
+	}
+	sort.Slice(incomeCategories, byName(incomeCategories))
+	sort.Slice(expenseCategories, byName(expenseCategories))
+
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	c.HTML(http.StatusOK, "categories.html", gin.H{
+		"IncomeCategories":  incomeCategories,
+		"ExpenseCategories": expenseCategories,
+		"Budgets":           budgetProgress(data, monthStart),
+	})
+}
+
+func (h *CategoryHandler) AddCategory(c *gin.Context) {
+	name := strings.TrimSpace(c.PostForm("name"))
+	icon := strings.TrimSpace(c.PostForm("icon"))
+	isIncome := c.PostForm("kind") == "income"
+
+	if name == "" {
+		c.Redirect(http.StatusFound, "/categories?message=Ошибка: Название не может быть пустым")
+		return
+	}
+
+	err := h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		newID := 1
+		for _, cat := range data.Categories {
+			if strings.EqualFold(cat.Name, name) && cat.IsIncome == isIncome {
+				return formError("Ошибка: Такая категория уже существует")
+			}
+			if cat.ID >= newID {
+				newID = cat.ID + 1
+			}
+		}
+
+		data.Categories = append(data.Categories, models.Category{
+			ID:       newID,
+			Name:     name,
+			Icon:     icon,
+			IsIncome: isIncome,
+		})
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/categories?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/categories?message=Категория добавлена")
+}
+
+func (h *CategoryHandler) EditCategory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/categories?message=Ошибка: Неверный ID категории")
+		return
+	}
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	icon := strings.TrimSpace(c.PostForm("icon"))
+
+	if name == "" {
+		c.Redirect(http.StatusFound, "/categories?message=Ошибка: Название не может быть пустым")
+		return
+	}
+
+	err = h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		for i, cat := range data.Categories {
+			if cat.ID == id {
+				data.Categories[i].Name = name
+				data.Categories[i].Icon = icon
+				return nil
+			}
+		}
+		return formError("Ошибка: Категория не найдена")
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/categories?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/categories?message=Категория обновлена")
+}
+
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/categories?message=Ошибка: Неверный ID категории")
+		return
+	}
+
+	err = h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		for i, cat := range data.Categories {
+			if cat.ID == id {
+				data.Categories = append(data.Categories[:i], data.Categories[i+1:]...)
+				break
+			}
+		}
+
+		// Операции удалённой категории, в том числе в корзине, остаются без
+		// категории: иначе новая категория с тем же ID досталась бы им при
+		// восстановлении
+		for i, t := range data.Transactions {
+			if t.CategoryID == id {
+				data.Transactions[i].CategoryID = 0
+			}
+		}
+		for i, d := range data.Trash {
+			if d.CategoryID == id {
+				data.Trash[i].CategoryID = 0
+			}
+		}
+
+		for i, r := range data.Recurring {
+			if r.CategoryID == id {
+				data.Recurring[i].CategoryID = 0
+			}
+		}
+
+		// Бюджеты удалённой категории больше не имеют смысла
+		budgets := data.Budgets[:0]
+		for _, b := range data.Budgets {
+			if b.CategoryID != id {
+				budgets = append(budgets, b)
+			}
+		}
+		data.Budgets = budgets
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/categories?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/categories?message=Категория удалена")
+}
@@ -14,11 +14,12 @@ import (
 )
 
 type ExportHandler struct {
+	financeStore *storage.FinanceStorage
 	workLogStore *storage.WorkLogStorage
 }
 
-func NewExportHandler(workLogStore *storage.WorkLogStorage) *ExportHandler {
-	return &ExportHandler{workLogStore: workLogStore}
+func NewExportHandler(financeStore *storage.FinanceStorage, workLogStore *storage.WorkLogStorage) *ExportHandler {
+	return &ExportHandler{financeStore: financeStore, workLogStore: workLogStore}
 }
 
 func (h *ExportHandler) ExportWorkLogPDF(c *gin.Context) {
@@ -34,9 +35,8 @@ func (h *ExportHandler) ExportWorkLogPDF(c *gin.Context) {
 		return
 	}
 
-	data := h.workLogStore.GetData()
+	data := h.workLogStore.Snapshot()
 	var filteredEntries []models.WorkEntry
-	var allWorkedHours, overHours float64
 
 	for _, entry := range data.Entries {
 		entryDate, err := time.Parse("2006-01-02", entry.Date)
@@ -45,23 +45,12 @@ func (h *ExportHandler) ExportWorkLogPDF(c *gin.Context) {
 		}
 		if entryDate.Year() == selectedMonth.Year() && entryDate.Month() == selectedMonth.Month() && !entry.IsDayOff {
 			filteredEntries = append(filteredEntries, entry)
-
-			start, _ := time.Parse("15:04", entry.StartTime)
-			end, _ := time.Parse("15:04", entry.EndTime)
-			duration := end.Sub(start).Hours()
-			if duration < 0 {
-				duration += 24
-			}
-			if duration > 7 {
-				//Время обеда
-				duration = duration - 1
-			}
-			if duration > 8 {
-				overHours += duration - 8
-			}
-			allWorkedHours += duration
 		}
 	}
+	workTime := h.workLogStore.WorkTime()
+	days := workTime.Days(data.Entries)
+	summary := workPeriodSummary(workTime, days, selectedMonth.Format("2006-01"))
+	byDate := workDaysByDate(days)
 
 	sort.Slice(filteredEntries, func(i, j int) bool {
 		dateI, _ := time.Parse("2006-01-02", filteredEntries[i].Date)
@@ -102,45 +91,88 @@ func (h *ExportHandler) ExportWorkLogPDF(c *gin.Context) {
 	pdf.CellFormat(20, 10, "Число", "1", 0, "C", true, 0, "")
 	pdf.CellFormat(50, 10, "Место", "1", 0, "C", true, 0, "")
 	pdf.CellFormat(30, 10, "Время", "1", 0, "C", true, 0, "")
+	pdf.CellFormat(30, 10, "Перерыв", "1", 0, "C", true, 0, "")
 	pdf.CellFormat(40, 10, "Длительность", "1", 0, "C", true, 0, "")
 	pdf.Ln(-1)
 
 	pdf.SetFont("DejaVu", "", 10)
 	pdf.SetFillColor(255, 255, 255)
+	lunchByRule := false
 	for _, entry := range filteredEntries {
 		date, _ := time.Parse("2006-01-02", entry.Date)
 		formattedDate := fmt.Sprintf("%02d", date.Day())
+		day := byDate[entry.Date]
+		hoursWorked := fmt.Sprintf("%.1f ч", day.Hours)
+
+		// Записанные перерывы или обед по правилам, отмеченный звёздочкой
+		breakCell := ""
+		switch {
+		case day.BreaksRecorded:
+			breakCell = fmt.Sprintf("%.0f мин", day.Lunch*60)
+		case day.Lunch > 0:
+			breakCell = fmt.Sprintf("%.0f мин*", day.Lunch*60)
+			lunchByRule = true
+		}
 
-		start, _ := time.Parse("15:04", entry.StartTime)
-		end, _ := time.Parse("15:04", entry.EndTime)
-		duration := end.Sub(start).Hours()
-		if duration < 0 {
-			duration += 24
+		// Несколько отрезков за день — строка на каждый и итог за день
+		sessions := entry.WorkSessions()
+		for i, session := range sessions {
+			dateCell, sessionBreakCell, hoursCell := "", "", fmt.Sprintf("%.1f ч", session.Hours())
+			if i == 0 {
+				dateCell = formattedDate
+			}
+			if len(sessions) == 1 {
+				sessionBreakCell, hoursCell = breakCell, hoursWorked
+			}
+			pdf.CellFormat(20, 8, dateCell, "1", 0, "C", false, 0, "")
+			pdf.CellFormat(50, 8, session.Place, "1", 0, "L", false, 0, "")
+			pdf.CellFormat(30, 8, fmt.Sprintf("%s - %s", session.StartTime, session.EndTime), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(30, 8, sessionBreakCell, "1", 0, "C", false, 0, "")
+			pdf.CellFormat(40, 8, hoursCell, "1", 0, "C", false, 0, "")
+			pdf.Ln(-1)
 		}
-		if duration > 7 {
-			//Время обеда
-			duration = duration - 1
+		if len(sessions) > 1 {
+			pdf.CellFormat(20, 8, "", "1", 0, "C", false, 0, "")
+			pdf.CellFormat(80, 8, "Итого за день", "1", 0, "R", false, 0, "")
+			pdf.CellFormat(30, 8, breakCell, "1", 0, "C", false, 0, "")
+			pdf.CellFormat(40, 8, hoursWorked, "1", 0, "C", false, 0, "")
+			pdf.Ln(-1)
 		}
-		hoursWorked := fmt.Sprintf("%.1f ч", duration)
-
-		pdf.CellFormat(20, 8, formattedDate, "1", 0, "C", false, 0, "")
-		pdf.CellFormat(50, 8, entry.Place, "1", 0, "L", false, 0, "")
-		pdf.CellFormat(30, 8, fmt.Sprintf("%s - %s", entry.StartTime, entry.EndTime), "1", 0, "C", false, 0, "")
-		pdf.CellFormat(40, 8, hoursWorked, "1", 0, "C", false, 0, "")
-		pdf.Ln(-1)
+	}
+	if lunchByRule {
+		pdf.SetFont("DejaVu", "", 9)
+		pdf.Cell(0, 8, "* перерывы не записаны, обед вычтен по правилам")
+		pdf.Ln(4)
 	}
 
 	pdf.Ln(5)
 	pdf.SetFont("DejaVu", "", 12)
-	pdf.Cell(0, 10, fmt.Sprintf("Всего рабочих дней: %d", len(filteredEntries)))
-	pdf.Ln(5)
-	pdf.Cell(0, 10, fmt.Sprintf("Всего отработано часов: %.1f", allWorkedHours))
+	pdf.Cell(0, 10, fmt.Sprintf("Всего рабочих дней: %d", summary.WorkDays))
 	pdf.Ln(5)
-	pdf.Cell(0, 10, fmt.Sprintf("Отработано часов выше нормы (более 8 часов в сутки): %.1f", overHours))
+	pdf.Cell(0, 10, fmt.Sprintf("Всего отработано часов: %.1f", summary.TotalHours))
 	pdf.Ln(5)
-	pdf.Cell(0, 10, fmt.Sprintf("Всего часов (включая сверхурочные): %.1f", allWorkedHours+overHours))
+	pdf.Cell(0, 10, fmt.Sprintf("Отработано часов выше нормы: %.1f", summary.OvertimeHours))
+	if summary.WeeklyOvertimeHours > 0 {
+		pdf.Ln(5)
+		pdf.Cell(0, 10, fmt.Sprintf("  в том числе сверх недельной нормы: %.1f", summary.WeeklyOvertimeHours))
+	}
+	if summary.NightHours > 0 {
+		pdf.Ln(5)
+		pdf.Cell(0, 10, fmt.Sprintf("Ночных часов: %.1f", summary.NightHours))
+	}
+	if summary.WeekendHours > 0 {
+		pdf.Ln(5)
+		pdf.Cell(0, 10, fmt.Sprintf("Часов в выходные дни: %.1f", summary.WeekendHours))
+	}
 	pdf.Ln(5)
-	pdf.Cell(0, 10, "Если в сутки > 7 часов, отнимается 1 час обеда")
+	pdf.Cell(0, 10, fmt.Sprintf("Всего часов к оплате (с коэффициентами): %.1f", summary.TotalWithOvertime))
+
+	pdf.Ln(10)
+	pdf.SetFont("DejaVu", "", 9)
+	for _, line := range workRulesDescription(workTime.Rules()) {
+		pdf.Cell(0, 10, line)
+		pdf.Ln(4)
+	}
 
 	fileName := fmt.Sprintf("worklog_%s.pdf", selectedMonth.Format("2006-01"))
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"finance-tracker/models"
+	"finance-tracker/storage"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Финансовый отчёт в PDF за день, неделю, месяц, год или произвольный период.
+// Итоги, крупнейшие операции, категории и график считаются так же, как на
+// странице статистики: в базовой валюте по курсу на дату операции, без
+// переводов, обменов и запланированных операций. Остатки по валютам —
+// в валюте счетов, вместе с переводами и обменами, как баланс на главной.
+
+// reportMonths — названия месяцев в родительном падеже
+var reportMonths = map[time.Month]string{
+	time.January:   "января",
+	time.February:  "февраля",
+	time.March:     "марта",
+	time.April:     "апреля",
+	time.May:       "мая",
+	time.June:      "июня",
+	time.July:      "июля",
+	time.August:    "августа",
+	time.September: "сентября",
+	time.October:   "октября",
+	time.November:  "ноября",
+	time.December:  "декабря",
+}
+
+// reportMonthTitles — названия месяцев для заголовка отчёта за месяц
+var reportMonthTitles = map[time.Month]string{
+	time.January:   "январь",
+	time.February:  "февраль",
+	time.March:     "март",
+	time.April:     "апрель",
+	time.May:       "май",
+	time.June:      "июнь",
+	time.July:      "июль",
+	time.August:    "август",
+	time.September: "сентябрь",
+	time.October:   "октябрь",
+	time.November:  "ноябрь",
+	time.December:  "декабрь",
+}
+
+// reportMaxDays — самый длинный произвольный период отчёта
+const reportMaxDays = 366
+
+// reportPeriod возвращает начало и конец (не включая) периода отчёта и его
+// название. date — любой день периода (для месяца можно ГГГГ-ММ, для года —
+// ГГГГ), from и to — границы произвольного периода включительно.
+func reportPeriod(period, date, from, to string) (time.Time, time.Time, string, string) {
+	parseDay := func(value string) (time.Time, bool) {
+		t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		return t, err == nil
+	}
+	dayTitle := func(t time.Time) string {
+		return fmt.Sprintf("%d %s %d", t.Day(), reportMonths[t.Month()], t.Year())
+	}
+
+	if period == "custom" {
+		start, okStart := parseDay(from)
+		last, okEnd := parseDay(to)
+		if !okStart || !okEnd {
+			return time.Time{}, time.Time{}, "", "Ошибка: Укажите начало и конец периода"
+		}
+		if last.Before(start) {
+			return time.Time{}, time.Time{}, "", "Ошибка: Конец периода раньше начала"
+		}
+		end := last.AddDate(0, 0, 1)
+		if end.Sub(start).Hours()/24 > reportMaxDays {
+			return time.Time{}, time.Time{}, "", "Ошибка: Период отчёта не может быть длиннее года"
+		}
+		return start, end, fmt.Sprintf("период с %s по %s", dayTitle(start), dayTitle(last)), ""
+	}
+
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+	selected, ok := parseDay(date)
+	if !ok && period == "month" {
+		t, err := time.ParseInLocation("2006-01", date, time.Local)
+		selected, ok = t, err == nil
+	}
+	if !ok && period == "year" {
+		t, err := time.ParseInLocation("2006", date, time.Local)
+		selected, ok = t, err == nil
+	}
+	if !ok {
+		return time.Time{}, time.Time{}, "", "Ошибка: Неверный формат даты"
+	}
+
+	switch period {
+	case "day":
+		return selected, selected.AddDate(0, 0, 1), dayTitle(selected), ""
+	case "week":
+		weekday := int(selected.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		start := selected.AddDate(0, 0, 1-weekday)
+		end := start.AddDate(0, 0, 7)
+		return start, end, fmt.Sprintf("неделю с %s по %s", dayTitle(start), dayTitle(end.AddDate(0, 0, -1))), ""
+	case "month":
+		start := time.Date(selected.Year(), selected.Month(), 1, 0, 0, 0, 0, time.Local)
+		return start, start.AddDate(0, 1, 0), fmt.Sprintf("%s %d", reportMonthTitles[start.Month()], start.Year()), ""
+	case "year":
+		start := time.Date(selected.Year(), time.January, 1, 0, 0, 0, 0, time.Local)
+		return start, start.AddDate(1, 0, 0), fmt.Sprintf("%d год", start.Year()), ""
+	}
+	return time.Time{}, time.Time{}, "", "Ошибка: Неверный период"
+}
+
+// currencyMovement — остатки и движение денег в одной валюте за период
+type currencyMovement struct {
+	Currency string
+	Opening  float64
+	Income   float64
+	Expense  float64
+	Internal float64 // Переводы и обмены: сумма со знаком
+	Closing  float64
+}
+
+// currencyMovements считает остатки по валютам на начало и конец периода.
+// Запланированные операции, как и в балансе, не учитываются до своей даты.
+func currencyMovements(data *models.FinanceData, start, end, now time.Time) []currencyMovement {
+	byCurrency := map[string]*currencyMovement{}
+	get := func(currency string) *currencyMovement {
+		if byCurrency[currency] == nil {
+			byCurrency[currency] = &currencyMovement{Currency: currency}
+		}
+		return byCurrency[currency]
+	}
+	for _, a := range data.Accounts {
+		get(a.Currency).Opening += a.OpeningBalance
+	}
+	for _, t := range data.Transactions {
+		if t.IsPlanned(now) || !t.DateTime.Before(end) {
+			continue
+		}
+		m := get(t.Currency)
+		amount := signedAmount(t)
+		switch {
+		case t.DateTime.Before(start):
+			m.Opening += amount
+		case t.IsInternal():
+			m.Internal += amount
+		case t.IsPositive:
+			m.Income += t.Amount
+		default:
+			m.Expense += t.Amount
+		}
+	}
+
+	result := []currencyMovement{}
+	for _, m := range byCurrency {
+		m.Closing = m.Opening + m.Income - m.Expense + m.Internal
+		if m.Opening == 0 && m.Closing == 0 && m.Income == 0 && m.Expense == 0 {
+			continue
+		}
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Currency < result[j].Currency
+	})
+	return result
+}
+
+// pdfFit обрезает текст, чтобы он поместился в ячейку ширины width
+func pdfFit(pdf *gofpdf.Fpdf, text string, width float64) string {
+	width -= 2 * pdf.GetCellMargin()
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// pdfTableHeader выводит строку заголовка таблицы
+func pdfTableHeader(pdf *gofpdf.Fpdf, widths []float64, titles ...string) {
+	pdf.SetFont("DejaVu", "", 9)
+	pdf.SetFillColor(200, 200, 200)
+	for i, title := range titles {
+		pdf.CellFormat(widths[i], 7, pdfFit(pdf, title, widths[i]), "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// pdfSection выводит заголовок раздела, начиная новую страницу, если до
+// конца текущей осталось меньше need миллиметров
+func pdfSection(pdf *gofpdf.Fpdf, title string, need float64) {
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+need > pageHeight-bottom-20 {
+		pdf.AddPage()
+	} else {
+		pdf.Ln(4)
+	}
+	pdf.SetFont("DejaVu", "", 13)
+	pdf.Cell(0, 8, title)
+	pdf.Ln(9)
+}
+
+// pdfCashflowChart рисует столбчатый график доходов и расходов по дням
+func pdfCashflowChart(pdf *gofpdf.Fpdf, days []time.Time, incomes, expenses []float64, currency string) {
+	const height = 60.0
+	left, _, right, _ := pdf.GetMargins()
+	pageWidth, _ := pdf.GetPageSize()
+	x0 := left + 18
+	width := pageWidth - right - x0
+	y0 := pdf.GetY()
+	baseline := y0 + height
+
+	maxValue := 0.0
+	for i := range days {
+		maxValue = math.Max(maxValue, math.Max(incomes[i], expenses[i]))
+	}
+
+	pdf.SetFont("DejaVu", "", 7)
+	pdf.SetDrawColor(180, 180, 180)
+	pdf.Line(x0, baseline, x0+width, baseline)
+	pdf.Line(x0, y0, x0, baseline)
+	if maxValue > 0 {
+		pdf.Line(x0-1, y0, x0+width, y0)
+		pdf.SetXY(left, y0-2)
+		pdf.CellFormat(17, 4, fmt.Sprintf("%.0f", maxValue), "", 0, "R", false, 0, "")
+		pdf.Line(x0-1, y0+height/2, x0+width, y0+height/2)
+		pdf.SetXY(left, y0+height/2-2)
+		pdf.CellFormat(17, 4, fmt.Sprintf("%.0f", maxValue/2), "", 0, "R", false, 0, "")
+	}
+	pdf.SetXY(left, baseline-2)
+	pdf.CellFormat(17, 4, "0 "+currency, "", 0, "R", false, 0, "")
+
+	slot := width / float64(len(days))
+	bar := slot * 0.4
+	labelEvery := int(math.Ceil(float64(len(days)) / 16))
+	for i, day := range days {
+		x := x0 + slot*float64(i) + slot*0.1
+		if maxValue > 0 {
+			if h := incomes[i] / maxValue * height; h > 0 {
+				pdf.SetFillColor(76, 175, 80)
+				pdf.Rect(x, baseline-h, bar, h, "F")
+			}
+			if h := expenses[i] / maxValue * height; h > 0 {
+				pdf.SetFillColor(244, 67, 54)
+				pdf.Rect(x+bar, baseline-h, bar, h, "F")
+			}
+		}
+		if i%labelEvery == 0 {
+			pdf.SetXY(x0+slot*float64(i)-4, baseline+1)
+			pdf.CellFormat(slot+8, 4, day.Format("02.01"), "", 0, "C", false, 0, "")
+		}
+	}
+
+	// Легенда
+	pdf.SetXY(x0, baseline+6)
+	pdf.SetFillColor(76, 175, 80)
+	pdf.Rect(x0, baseline+7, 3, 3, "F")
+	pdf.SetX(x0 + 4)
+	pdf.CellFormat(20, 5, "Доходы", "", 0, "L", false, 0, "")
+	pdf.SetFillColor(244, 67, 54)
+	pdf.Rect(x0+25, baseline+7, 3, 3, "F")
+	pdf.SetX(x0 + 29)
+	pdf.CellFormat(20, 5, "Расходы", "", 0, "L", false, 0, "")
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetY(baseline + 12)
+}
+
+// ExportFinancePDF — GET /export/finance: финансовый отчёт за период.
+// Параметры: period=day|week|month|year|custom, date, from и to.
+func (h *ExportHandler) ExportFinancePDF(c *gin.Context) {
+	period := c.DefaultQuery("period", "month")
+	start, end, periodTitle, errMsg := reportPeriod(period, c.Query("date"), c.Query("from"), c.Query("to"))
+	if errMsg != "" {
+		c.Redirect(http.StatusFound, "/stats?message="+url.QueryEscape(errMsg))
+		return
+	}
+
+	data := h.financeStore.Snapshot()
+	conv := storage.NewConverter(data)
+	baseCurrency := conv.Base()
+	now := time.Now()
+
+	// Операции периода в базовой валюте
+	originals := make(map[int]models.Transaction)
+	var converted []models.Transaction
+	plannedIncome, plannedExpense := 0.0, 0.0
+	for _, t := range data.Transactions {
+		if t.DateTime.Before(start) || !t.DateTime.Before(end) || t.IsInternal() {
+			continue
+		}
+		amount, ok := conv.Amount(t)
+		if !ok {
+			continue
+		}
+		originals[t.ID] = t
+		t.Amount = amount
+		if t.IsPlanned(now) {
+			if t.IsPositive {
+				plannedIncome += amount
+			} else {
+				plannedExpense += amount
+			}
+			continue
+		}
+		converted = append(converted, t)
+	}
+
+	totalIncome, totalExpense := 0.0, 0.0
+	var incomes, expenses []models.Transaction
+	days := []time.Time{}
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	dailyIncome := make([]float64, len(days))
+	dailyExpense := make([]float64, len(days))
+	for _, t := range converted {
+		local := t.DateTime.In(time.Local)
+		index := int(time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local).Sub(start).Hours() / 24)
+		if index >= len(days) {
+			index = len(days) - 1
+		}
+		if t.IsPositive {
+			totalIncome += t.Amount
+			dailyIncome[index] += t.Amount
+			incomes = append(incomes, t)
+		} else {
+			totalExpense += t.Amount
+			dailyExpense[index] += t.Amount
+			expenses = append(expenses, t)
+		}
+	}
+	sort.Slice(incomes, func(i, j int) bool { return incomes[i].Amount > incomes[j].Amount })
+	sort.Slice(expenses, func(i, j int) bool { return expenses[i].Amount > expenses[j].Amount })
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(10, 10, 10)
+	pdf.AddUTF8Font("DejaVu", "", "DejaVuSans.ttf")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("DejaVu", "", 8)
+		pdf.CellFormat(0, 6, fmt.Sprintf("Страница %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	pdf.SetFont("DejaVu", "", 16)
+	pdf.Cell(0, 10, "Финансовый отчёт за "+periodTitle)
+	pdf.Ln(10)
+	pdf.SetFont("DejaVu", "", 9)
+	pdf.Cell(0, 5, fmt.Sprintf("%s — %s. Сформирован %s.",
+		start.Format("02.01.2006"), end.AddDate(0, 0, -1).Format("02.01.2006"), now.Format("02.01.2006 15:04")))
+	pdf.Ln(6)
+
+	// Остатки по валютам
+	pdfSection(pdf, "Остатки по валютам", 30)
+	widths := []float64{25, 33, 33, 33, 33, 33}
+	pdfTableHeader(pdf, widths, "Валюта", "На начало", "Доходы", "Расходы", "Переводы, обмены", "На конец")
+	for _, m := range currencyMovements(data, start, end, now) {
+		pdf.CellFormat(widths[0], 7, m.Currency, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(widths[1], 7, fmt.Sprintf("%.2f", m.Opening), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], 7, fmt.Sprintf("%.2f", m.Income), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 7, fmt.Sprintf("%.2f", m.Expense), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 7, fmt.Sprintf("%+.2f", m.Internal), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], 7, fmt.Sprintf("%.2f", m.Closing), "1", 0, "R", false, 0, "")
+		pdf.Ln(-1)
+	}
+
+	// Итоги
+	pdfSection(pdf, fmt.Sprintf("Итоги в %s", baseCurrency), 35)
+	pdf.SetFont("DejaVu", "", 10)
+	lines := []string{
+		fmt.Sprintf("Доходы: %.2f %s", totalIncome, baseCurrency),
+		fmt.Sprintf("Расходы: %.2f %s", totalExpense, baseCurrency),
+		fmt.Sprintf("Чистый результат: %+.2f %s", totalIncome-totalExpense, baseCurrency),
+		fmt.Sprintf("Средние расходы в день: %.2f %s", totalExpense/float64(len(days)), baseCurrency),
+	}
+	if plannedIncome > 0 || plannedExpense > 0 {
+		lines = append(lines, fmt.Sprintf("Запланировано: +%.2f / −%.2f %s", plannedIncome, plannedExpense, baseCurrency))
+	}
+	for _, line := range lines {
+		pdf.Cell(0, 6, line)
+		pdf.Ln(6)
+	}
+	pdf.SetFont("DejaVu", "", 8)
+	pdf.Cell(0, 5, "Суммы пересчитаны по курсу на дату операции. Переводы и обмены не входят в доходы и расходы.")
+	pdf.Ln(5)
+	if missing := conv.Missing(); len(missing) > 0 {
+		pdf.Cell(0, 5, fmt.Sprintf("Нет курсов для валют: %s. Операции в них не учтены в итогах.", strings.Join(missing, ", ")))
+		pdf.Ln(5)
+	}
+
+	// График
+	pdfSection(pdf, "Движение денег по дням", 85)
+	pdfCashflowChart(pdf, days, dailyIncome, dailyExpense, baseCurrency)
+
+	// Крупнейшие операции
+	topWidths := []float64{22, 98, 40, 30}
+	for _, group := range []struct {
+		title        string
+		transactions []models.Transaction
+	}{
+		{"Крупнейшие доходы", incomes},
+		{"Крупнейшие расходы", expenses},
+	} {
+		pdfSection(pdf, group.title, 50)
+		if len(group.transactions) == 0 {
+			pdf.SetFont("DejaVu", "", 10)
+			pdf.Cell(0, 6, "Операций за период нет.")
+			pdf.Ln(6)
+			continue
+		}
+		pdfTableHeader(pdf, topWidths, "Дата", "Описание", "Сумма", "В "+baseCurrency)
+		for i, t := range group.transactions {
+			if i >= 10 {
+				break
+			}
+			top := topTransaction(originals[t.ID], t.Amount, baseCurrency)
+			pdf.CellFormat(topWidths[0], 7, top["DateTime"].(string), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(topWidths[1], 7, pdfFit(pdf, top["Description"].(string), topWidths[1]), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(topWidths[2], 7, fmt.Sprintf("%s %s", top["Amount"], top["Currency"]), "1", 0, "R", false, 0, "")
+			pdf.CellFormat(topWidths[3], 7, fmt.Sprintf("%.2f", t.Amount), "1", 0, "R", false, 0, "")
+			pdf.Ln(-1)
+		}
+	}
+
+	// Категории
+	categoryWidths := []float64{100, 25, 40, 25}
+	for _, group := range []struct {
+		title  string
+		income bool
+	}{
+		{"Расходы по категориям", false},
+		{"Доходы по категориям", true},
+	} {
+		categories := categoryBreakdown(converted, nil, data.Categories, group.income, baseCurrency)
+		if len(categories) == 0 {
+			continue
+		}
+		pdfSection(pdf, group.title, 30)
+		pdfTableHeader(pdf, categoryWidths, "Категория", "Операций", "Сумма, "+baseCurrency, "Доля")
+		for _, cat := range categories {
+			pdf.CellFormat(categoryWidths[0], 7, pdfFit(pdf, cat["Name"].(string), categoryWidths[0]), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(categoryWidths[1], 7, fmt.Sprintf("%d", cat["Count"]), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(categoryWidths[2], 7, fmt.Sprintf("%.2f", cat["Total"]), "1", 0, "R", false, 0, "")
+			pdf.CellFormat(categoryWidths[3], 7, fmt.Sprintf("%.1f%%", cat["Share"]), "1", 0, "R", false, 0, "")
+			pdf.Ln(-1)
+		}
+	}
+
+	fileName := fmt.Sprintf("finance_%s_%s.pdf", start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+	c.Header("Content-Type", "application/pdf")
+
+	if err := pdf.Output(c.Writer); err != nil {
+		c.Redirect(http.StatusFound, "/stats?message=Ошибка при генерации PDF")
+		return
+	}
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"finance-tracker/models"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Экспорт операций по фильтру главной страницы в CSV, XLSX и JSON. В итоги
+// не входят переводы, обмены и запланированные операции — как в статистике
+// за месяц на главной странице.
+
+// Форматы экспорта операций
+const (
+	exportCSV  = "csv"
+	exportXLSX = "xlsx"
+	exportJSON = "json"
+)
+
+// exportTotal — итог по валюте или по категории в валюте
+type exportTotal struct {
+	Category string  `json:"category,omitempty"`
+	Currency string  `json:"currency"`
+	Income   float64 `json:"income"`
+	Expense  float64 `json:"expense"`
+	Count    int     `json:"count"`
+}
+
+// exportTotals считает итоги по валютам и по категориям в каждой валюте
+func exportTotals(transactions []models.Transaction, categories map[int]models.Category, now time.Time) (byCurrency, byCategory []exportTotal) {
+	byCurrency, byCategory = []exportTotal{}, []exportTotal{}
+	currencyIndex := map[string]int{}
+	categoryIndex := map[string]int{}
+	add := func(totals []exportTotal, index map[string]int, key string, total exportTotal, t models.Transaction) []exportTotal {
+		i, ok := index[key]
+		if !ok {
+			i = len(totals)
+			index[key] = i
+			totals = append(totals, total)
+		}
+		if t.IsPositive {
+			totals[i].Income += t.Amount
+		} else {
+			totals[i].Expense += t.Amount
+		}
+		totals[i].Count++
+		return totals
+	}
+
+	for _, t := range transactions {
+		if t.IsInternal() || t.IsPlanned(now) {
+			continue
+		}
+		byCurrency = add(byCurrency, currencyIndex, t.Currency, exportTotal{Currency: t.Currency}, t)
+		category := categories[t.CategoryID].Name
+		if category == "" {
+			category = "Без категории"
+		}
+		byCategory = add(byCategory, categoryIndex, category+"|"+t.Currency, exportTotal{Category: category, Currency: t.Currency}, t)
+	}
+
+	sort.Slice(byCurrency, func(i, j int) bool {
+		return byCurrency[i].Currency < byCurrency[j].Currency
+	})
+	sort.Slice(byCategory, func(i, j int) bool {
+		if byCategory[i].Currency != byCategory[j].Currency {
+			return byCategory[i].Currency < byCategory[j].Currency
+		}
+		return byCategory[i].Expense+byCategory[i].Income > byCategory[j].Expense+byCategory[j].Income
+	})
+	return byCurrency, byCategory
+}
+
+// exportKind возвращает вид операции для таблиц экспорта
+func exportKind(t models.Transaction) string {
+	switch {
+	case t.IsTransfer():
+		return "Перевод"
+	case t.IsExchange():
+		return "Обмен"
+	case t.IsPositive:
+		return "Доход"
+	}
+	return "Расход"
+}
+
+// signedAmount возвращает сумму со знаком: расход отрицательный
+func signedAmount(t models.Transaction) float64 {
+	if t.IsPositive {
+		return t.Amount
+	}
+	return -t.Amount
+}
+
+// exportFilterDescription описывает фильтр экспорта строками «параметр —
+// значение»
+func exportFilterDescription(filter transactionFilter) [][2]string {
+	types := map[string]string{"income": "Доходы", "expense": "Расходы"}
+	rows := [][2]string{}
+	if filter.Type != "" {
+		rows = append(rows, [2]string{"Тип", types[filter.Type]})
+	}
+	if filter.DateStart != "" {
+		rows = append(rows, [2]string{"Дата (с)", filter.DateStart})
+	}
+	if filter.DateEnd != "" {
+		rows = append(rows, [2]string{"Дата (по)", filter.DateEnd})
+	}
+	if filter.Currency != "" {
+		rows = append(rows, [2]string{"Валюта", filter.Currency})
+	}
+	if filter.Text != "" {
+		rows = append(rows, [2]string{"Поиск", filter.Text})
+	}
+	return rows
+}
+
+// ExportTransactions — GET /export/transactions?format=csv|xlsx|json и
+// параметры фильтра главной страницы
+func (h *ExportHandler) ExportTransactions(c *gin.Context) {
+	format := c.DefaultQuery("format", exportCSV)
+	if format != exportCSV && format != exportXLSX && format != exportJSON {
+		c.Redirect(http.StatusFound, "/?message=Ошибка: Неизвестный формат экспорта")
+		return
+	}
+
+	filter := queryTransactionFilter(c)
+	data, transactions, err := filter.Apply(h.financeStore)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/?message="+url.QueryEscape("Ошибка в запросе: "+err.Error()))
+		return
+	}
+	categories := categoryMap(data.Categories)
+	accounts := accountMap(data.Accounts)
+	now := time.Now()
+
+	fileName := fmt.Sprintf("transactions_%s.%s", now.Format("2006-01-02"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+
+	switch format {
+	case exportCSV:
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		err = writeTransactionsCSV(c, transactions, categories, accounts)
+	case exportXLSX:
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		err = writeXLSX(c.Writer, transactionSheets(filter, transactions, categories, accounts, now))
+	case exportJSON:
+		items := make([]apiTransaction, 0, len(transactions))
+		for _, t := range transactions {
+			items = append(items, toAPITransaction(t, now))
+		}
+		byCurrency, byCategory := exportTotals(transactions, categories, now)
+		c.JSON(http.StatusOK, gin.H{
+			"exported_at": now,
+			"filter": gin.H{
+				"type":       filter.Type,
+				"date_start": filter.DateStart,
+				"date_end":   filter.DateEnd,
+				"currency":   filter.Currency,
+				"text":       filter.Text,
+			},
+			"items":       items,
+			"total":       len(items),
+			"totals":      byCurrency,
+			"by_category": byCategory,
+		})
+	}
+	if err != nil {
+		fmt.Printf("Ошибка при экспорте операций: %v\n", err)
+	}
+}
+
+// writeTransactionsCSV записывает операции в CSV с BOM, чтобы Excel узнал
+// UTF-8. Сумма со знаком, так что файл можно снова загрузить через импорт.
+func writeTransactionsCSV(c *gin.Context, transactions []models.Transaction, categories map[int]models.Category, accounts map[int]models.Account) error {
+	if _, err := c.Writer.WriteString("\xef\xbb\xbf"); err != nil {
+		return err
+	}
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"ID", "Дата", "Вид", "Сумма", "Валюта", "Описание", "Категория", "Счёт", "Заметки", "ID в банке"})
+	for _, t := range transactions {
+		w.Write([]string{
+			strconv.Itoa(t.ID),
+			t.DateTime.Local().Format("2006-01-02 15:04"),
+			exportKind(t),
+			strconv.FormatFloat(signedAmount(t), 'f', 2, 64),
+			t.Currency,
+			t.Description,
+			categories[t.CategoryID].Name,
+			accounts[t.AccountID].Name,
+			t.Notes,
+			t.ExternalID,
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// transactionSheets составляет листы книги XLSX: операции и итоги
+func transactionSheets(filter transactionFilter, transactions []models.Transaction, categories map[int]models.Category, accounts map[int]models.Account, now time.Time) []*xlsxSheet {
+	list := &xlsxSheet{name: "Операции", widths: []float64{8, 17, 10, 12, 8, 40, 20, 20, 30}}
+	list.addRow(xlsxHeader("ID"), xlsxHeader("Дата"), xlsxHeader("Вид"), xlsxHeader("Сумма"), xlsxHeader("Валюта"),
+		xlsxHeader("Описание"), xlsxHeader("Категория"), xlsxHeader("Счёт"), xlsxHeader("Заметки"))
+	for _, t := range transactions {
+		list.addRow(xlsxInt(t.ID), xlsxDate(t.DateTime), xlsxText(exportKind(t)), xlsxNumber(signedAmount(t)), xlsxText(t.Currency),
+			xlsxText(t.Description), xlsxText(categories[t.CategoryID].Name), xlsxText(accounts[t.AccountID].Name), xlsxText(t.Notes))
+	}
+
+	summary := &xlsxSheet{name: "Итоги", widths: []float64{24, 10, 14, 14, 14, 10}}
+	summary.addRow(xlsxHeader("Экспорт операций"), xlsxText(""), xlsxDate(now))
+	for _, row := range exportFilterDescription(filter) {
+		summary.addRow(xlsxText(row[0]), xlsxText(row[1]))
+	}
+	summary.addRow(xlsxText("Операций"), xlsxInt(len(transactions)))
+	summary.addRow()
+	summary.addRow(xlsxText("Переводы, обмены и запланированные операции в итоги не входят"))
+	summary.addRow()
+
+	byCurrency, byCategory := exportTotals(transactions, categories, now)
+	summary.addRow(xlsxHeader("По валютам"), xlsxHeader("Валюта"), xlsxHeader("Доходы"), xlsxHeader("Расходы"), xlsxHeader("Итого"), xlsxHeader("Операций"))
+	for _, total := range byCurrency {
+		summary.addRow(xlsxText(""), xlsxText(total.Currency), xlsxNumber(total.Income), xlsxNumber(total.Expense),
+			xlsxNumber(total.Income-total.Expense), xlsxInt(total.Count))
+	}
+	summary.addRow()
+	summary.addRow(xlsxHeader("Категория"), xlsxHeader("Валюта"), xlsxHeader("Доходы"), xlsxHeader("Расходы"), xlsxHeader("Итого"), xlsxHeader("Операций"))
+	for _, total := range byCategory {
+		summary.addRow(xlsxText(total.Category), xlsxText(total.Currency), xlsxNumber(total.Income), xlsxNumber(total.Expense),
+			xlsxNumber(total.Income-total.Expense), xlsxInt(total.Count))
+	}
+	return []*xlsxSheet{list, summary}
+}
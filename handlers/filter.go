@@ -0,0 +1,95 @@
+// handlers/filter.go
+package handlers
+
+import (
+	"finance-tracker/models"
+	"finance-tracker/storage"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transactionFilter — фильтр списка операций на главной странице. Тот же
+// фильтр применяется при подгрузке списка и при экспорте, поэтому параметры
+// запроса у них общие.
+type transactionFilter struct {
+	Type      string // "income", "expense" или пусто
+	DateStart string // Дата ГГГГ-ММ-ДД, включительно
+	DateEnd   string // Дата ГГГГ-ММ-ДД, включительно
+	Currency  string
+	Text      string // Поисковый запрос, см. storage.ParseSearchQuery
+}
+
+// queryTransactionFilter читает фильтр из параметров запроса filter-*
+func queryTransactionFilter(c *gin.Context) transactionFilter {
+	return transactionFilter{
+		Type:      c.Query("filter-type"),
+		DateStart: c.Query("filter-date-start"),
+		DateEnd:   c.Query("filter-date-end"),
+		Currency:  strings.ToUpper(strings.TrimSpace(c.Query("filter-currency"))),
+		Text:      strings.TrimSpace(c.Query("filter-text")),
+	}
+}
+
+// IsEmpty сообщает, что фильтр не задан
+func (f transactionFilter) IsEmpty() bool {
+	return f == transactionFilter{}
+}
+
+// Query возвращает параметры запроса фильтра для ссылок
+func (f transactionFilter) Query() string {
+	values := url.Values{}
+	for name, value := range map[string]string{
+		"filter-type":       f.Type,
+		"filter-date-start": f.DateStart,
+		"filter-date-end":   f.DateEnd,
+		"filter-currency":   f.Currency,
+		"filter-text":       f.Text,
+	} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	return values.Encode()
+}
+
+// Apply возвращает подходящие под фильтр операции, новые сверху, и копию
+// данных, снятую вместе с ними, — по ней подставляются названия категорий и
+// счетов. Неверные даты не ограничивают период, ошибка возвращается только
+// для неверного поискового запроса.
+func (f transactionFilter) Apply(store *storage.FinanceStorage) (*models.FinanceData, []models.Transaction, error) {
+	query, err := storage.ParseSearchQuery(f.Text)
+	if err != nil {
+		return store.Snapshot(), []models.Transaction{}, err
+	}
+
+	var start, end time.Time
+	if f.DateStart != "" {
+		if t, err := time.ParseInLocation("2006-01-02", f.DateStart, time.Local); err == nil {
+			start = t
+		}
+	}
+	if f.DateEnd != "" {
+		if t, err := time.ParseInLocation("2006-01-02", f.DateEnd, time.Local); err == nil {
+			end = t.AddDate(0, 0, 1)
+		}
+	}
+
+	data, result := store.SearchSnapshot(query, func(t models.Transaction) bool {
+		if f.Type == "income" && !t.IsPositive || f.Type == "expense" && t.IsPositive {
+			return false
+		}
+		if !start.IsZero() && t.DateTime.Before(start) || !end.IsZero() && !t.DateTime.Before(end) {
+			return false
+		}
+		return f.Currency == "" || t.Currency == f.Currency
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DateTime.After(result[j].DateTime)
+	})
+	return data, result, nil
+}
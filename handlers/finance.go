@@ -2,12 +2,15 @@
 package handlers
 
 import (
+	"errors"
+	"finance-tracker/auth"
 	"finance-tracker/models"
 	"finance-tracker/storage"
 	"fmt"
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -36,40 +39,14 @@ func (h *FinanceHandler) Index(c *gin.Context) {
 	const pageSize = 10
 
 	// Фильтрация
-	filterType := c.Query("filter-type")
-	filterDateStart := c.Query("filter-date-start")
-	filterDateEnd := c.Query("filter-date-end")
-
-	data := h.financeStore.GetData()
-	filteredTrans := []models.Transaction{}
-	for _, t := range data.Transactions {
-		if filterType != "" {
-			if filterType == "income" && !t.IsPositive {
-				continue
-			}
-			if filterType == "expense" && t.IsPositive {
-				continue
-			}
-		}
-		if filterDateStart != "" {
-			startDate, err := time.Parse("2006-01-02", filterDateStart)
-			if err == nil && t.DateTime.Before(startDate) {
-				continue
-			}
-		}
-		if filterDateEnd != "" {
-			endDate, err := time.Parse("2006-01-02", filterDateEnd)
-			if err == nil && t.DateTime.After(endDate) {
-				continue
-			}
-		}
-		filteredTrans = append(filteredTrans, t)
+	filter := queryTransactionFilter(c)
+	searchError := ""
+	data, filteredTrans, err := filter.Apply(h.financeStore)
+	if err != nil {
+		searchError = "Ошибка в запросе: " + err.Error()
 	}
 
-	// Сортировка по дате (новые сверху)
-	sort.Slice(filteredTrans, func(i, j int) bool {
-		return filteredTrans[i].DateTime.After(filteredTrans[j].DateTime)
-	})
+	now := time.Now()
 
 	// Пагинация
 	totalTrans := len(filteredTrans)
@@ -77,6 +54,9 @@ func (h *FinanceHandler) Index(c *gin.Context) {
 	if page > totalPages {
 		page = totalPages
 	}
+	if page < 1 {
+		page = 1
+	}
 	start := (page - 1) * pageSize
 	end := start + pageSize
 	if end > totalTrans {
@@ -85,31 +65,49 @@ func (h *FinanceHandler) Index(c *gin.Context) {
 	paginatedTrans := filteredTrans[start:end]
 
 	// Форматирование транзакций
+	categories := categoryMap(data.Categories)
+	accounts := accountMap(data.Accounts)
 	formattedTrans := make([]gin.H, len(paginatedTrans))
 	for i, t := range paginatedTrans {
+		category := categories[t.CategoryID]
 		formattedTrans[i] = gin.H{
-			"ID":          t.ID,
-			"Amount":      fmt.Sprintf("%.2f", t.Amount),
-			"Description": t.Description,
-			"DateTime":    t.DateTime.Format("02.01.2006 15:04"),
-			"IsPositive":  t.IsPositive,
-			"Currency":    t.Currency,
-			"Notes":       t.Notes,
+			"ID":           t.ID,
+			"Amount":       fmt.Sprintf("%.2f", t.Amount),
+			"Description":  t.Description,
+			"DateTime":     t.DateTime.Format("02.01.2006 15:04"),
+			"IsPositive":   t.IsPositive,
+			"Currency":     t.Currency,
+			"Notes":        t.Notes,
+			"CategoryID":   t.CategoryID,
+			"Category":     category.Name,
+			"CategoryIcon": category.Icon,
+			"RecurringID":  t.RecurringID,
+			"DateInput":    t.DateTime.Format("2006-01-02T15:04"),
+			"IsPlanned":    t.IsPlanned(now),
+			"AccountID":    t.AccountID,
+			"Account":      accounts[t.AccountID].Name,
+			"IsTransfer":   t.IsTransfer(),
+			"IsExchange":   t.IsExchange(),
 		}
 	}
 
-	// Вычисление статистики за месяц
+	// Вычисление статистики за месяц в базовой валюте. Операции в валютах без
+	// курса не учитываются, такие валюты перечисляются на странице.
 	monthlyIncome := 0.0
 	monthlyExpense := 0.0
-	now := time.Now()
 	oneMonthAgo := now.AddDate(0, -1, 0)
+	conv := storage.NewConverter(data)
 
 	for _, t := range data.Transactions {
-		if t.DateTime.After(oneMonthAgo) {
+		if t.DateTime.After(oneMonthAgo) && !t.IsPlanned(now) && !t.IsInternal() {
+			amount, ok := conv.Amount(t)
+			if !ok {
+				continue
+			}
 			if t.IsPositive {
-				monthlyIncome += t.Amount
+				monthlyIncome += amount
 			} else {
-				monthlyExpense += t.Amount
+				monthlyExpense += amount
 			}
 		}
 	}
@@ -133,6 +131,16 @@ func (h *FinanceHandler) Index(c *gin.Context) {
 		})
 	}
 
+	// Балансы счетов
+	accountBalances := []gin.H{}
+	for _, a := range activeAccounts(data.Accounts) {
+		accountBalances = append(accountBalances, gin.H{
+			"Name":     a.Name,
+			"Currency": a.Currency,
+			"Balance":  fmt.Sprintf("%.2f", data.AccountBalances[a.ID]),
+		})
+	}
+
 	// Данные для пагинации
 	pagination := gin.H{
 		"CurrentPage": page,
@@ -143,17 +151,33 @@ func (h *FinanceHandler) Index(c *gin.Context) {
 		"NextPage":    page + 1,
 	}
 
+	// Бюджеты текущего месяца
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	budgets := budgetProgress(data, monthStart)
+
 	// Получаем записи о работе
-	workData := h.workLogStore.GetData()
+	workData := h.workLogStore.Snapshot()
 
 	c.HTML(http.StatusOK, "index.html", gin.H{
-		"balances":       balances,
-		"transactions":   formattedTrans,
-		"monthlyIncome":  fmt.Sprintf("%.2f", monthlyIncome),
-		"monthlyExpense": fmt.Sprintf("%.2f", monthlyExpense),
-		"pagination":     pagination,
-		"today":          time.Now().Format("2006-01-02"),
-		"workEntries":    workData.Entries,
+		"balances":        balances,
+		"accountBalances": accountBalances,
+		"accounts":        activeAccounts(data.Accounts),
+		"transactions":    formattedTrans,
+		"monthlyIncome":   fmt.Sprintf("%.2f", monthlyIncome),
+		"monthlyExpense":  fmt.Sprintf("%.2f", monthlyExpense),
+		"pagination":      pagination,
+		"today":           now.Format("2006-01-02"),
+		"now":             now.Format("2006-01-02T15:04"),
+		"workEntries":     workData.Entries,
+		"categories":      data.Categories,
+		"budgets":         budgets,
+		"baseCurrency":    conv.Base(),
+		"missingRates":    strings.Join(conv.Missing(), ", "),
+		"undoLabel":       undoLabel(h.financeStore.LastAction()),
+		"filter":          filter,
+		"searchError":     searchError,
+		"filterQuery":     filter.Query(),
+		"currencies":      knownCurrencies(data),
 	})
 }
 
@@ -166,40 +190,14 @@ func (h *FinanceHandler) GetTransactions(c *gin.Context) {
 	const pageSize = 10
 
 	// Фильтрация
-	filterType := c.Query("filter-type")
-	filterDateStart := c.Query("filter-date-start")
-	filterDateEnd := c.Query("filter-date-end")
-
-	data := h.financeStore.GetData()
-	filteredTrans := []models.Transaction{}
-	for _, t := range data.Transactions {
-		if filterType != "" {
-			if filterType == "income" && !t.IsPositive {
-				continue
-			}
-			if filterType == "expense" && t.IsPositive {
-				continue
-			}
-		}
-		if filterDateStart != "" {
-			startDate, err := time.Parse("2006-01-02", filterDateStart)
-			if err == nil && t.DateTime.Before(startDate) {
-				continue
-			}
-		}
-		if filterDateEnd != "" {
-			endDate, err := time.Parse("2006-01-02", filterDateEnd)
-			if err == nil && t.DateTime.After(endDate) {
-				continue
-			}
-		}
-		filteredTrans = append(filteredTrans, t)
+	filter := queryTransactionFilter(c)
+	data, filteredTrans, err := filter.Apply(h.financeStore)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка в запросе: " + err.Error()})
+		return
 	}
 
-	// Сортировка по дате (новые сверху)
-	sort.Slice(filteredTrans, func(i, j int) bool {
-		return filteredTrans[i].DateTime.After(filteredTrans[j].DateTime)
-	})
+	now := time.Now()
 
 	// Пагинация
 	totalTrans := len(filteredTrans)
@@ -207,6 +205,9 @@ func (h *FinanceHandler) GetTransactions(c *gin.Context) {
 	if page > totalPages {
 		page = totalPages
 	}
+	if page < 1 {
+		page = 1
+	}
 	start := (page - 1) * pageSize
 	end := start + pageSize
 	if end > totalTrans {
@@ -215,16 +216,29 @@ func (h *FinanceHandler) GetTransactions(c *gin.Context) {
 	paginatedTrans := filteredTrans[start:end]
 
 	// Форматирование транзакций
+	categories := categoryMap(data.Categories)
+	accounts := accountMap(data.Accounts)
 	formattedTrans := make([]gin.H, len(paginatedTrans))
 	for i, t := range paginatedTrans {
+		category := categories[t.CategoryID]
 		formattedTrans[i] = gin.H{
-			"ID":          t.ID,
-			"Amount":      fmt.Sprintf("%.2f", t.Amount),
-			"Description": t.Description,
-			"DateTime":    t.DateTime.Format("02.01.2006 15:04"),
-			"IsPositive":  t.IsPositive,
-			"Currency":    t.Currency,
-			"Notes":       t.Notes,
+			"ID":           t.ID,
+			"Amount":       fmt.Sprintf("%.2f", t.Amount),
+			"Description":  t.Description,
+			"DateTime":     t.DateTime.Format("02.01.2006 15:04"),
+			"IsPositive":   t.IsPositive,
+			"Currency":     t.Currency,
+			"Notes":        t.Notes,
+			"CategoryID":   t.CategoryID,
+			"Category":     category.Name,
+			"CategoryIcon": category.Icon,
+			"RecurringID":  t.RecurringID,
+			"DateInput":    t.DateTime.Format("2006-01-02T15:04"),
+			"IsPlanned":    t.IsPlanned(now),
+			"AccountID":    t.AccountID,
+			"Account":      accounts[t.AccountID].Name,
+			"IsTransfer":   t.IsTransfer(),
+			"IsExchange":   t.IsExchange(),
 		}
 	}
 
@@ -258,27 +272,40 @@ func (h *FinanceHandler) AddTransaction(c *gin.Context) {
 		return
 	}
 
-	data := h.financeStore.GetData()
-	newID := 1
-	if len(data.Transactions) > 0 {
-		newID = data.Transactions[len(data.Transactions)-1].ID + 1
+	dateTime, errMsg := parseTransactionDateTime(c.PostForm("datetime"), c.PostForm("tz_offset"), time.Now())
+	if errMsg != "" {
+		c.Redirect(http.StatusFound, "/?message="+errMsg)
+		return
 	}
 
-	newTransaction := models.Transaction{
-		ID:          newID,
-		Amount:      amount,
-		Description: description,
-		DateTime:    time.Now(),
-		IsPositive:  action == "add-income",
-		Currency:    currency,
-		Notes:       notes,
+	isPositive := action == "add-income"
+	newTransaction, err := h.financeStore.AddTransaction(requestActor(c), func(data *models.FinanceData) (models.Transaction, error) {
+		accountID, currency, errMsg := resolveAccount(data, c.PostForm("account"), currency)
+		if errMsg != "" {
+			return models.Transaction{}, formError(errMsg)
+		}
+		categoryID, errMsg := parseCategoryID(c.PostForm("category"), data.Categories, isPositive)
+		if errMsg != "" {
+			return models.Transaction{}, formError(errMsg)
+		}
+		return models.Transaction{
+			Amount:      amount,
+			Description: description,
+			DateTime:    dateTime,
+			IsPositive:  isPositive,
+			Currency:    currency,
+			Notes:       notes,
+			CategoryID:  categoryID,
+			AccountID:   accountID,
+		}, nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/?message="+errorMessage(err))
+		return
 	}
 
-	data.Transactions = append(data.Transactions, newTransaction)
-
-	h.financeStore.RecalculateBalances()
-	if err := h.financeStore.Save(); err != nil {
-		c.Redirect(http.StatusFound, "/?message=Ошибка при сохранении данных")
+	if newTransaction.IsPlanned(time.Now()) {
+		c.Redirect(http.StatusFound, "/?message=Транзакция запланирована")
 		return
 	}
 
@@ -315,21 +342,35 @@ func (h *FinanceHandler) EditTransaction(c *gin.Context) {
 		return
 	}
 
-	data := h.financeStore.GetData()
-	for i, t := range data.Transactions {
-		if t.ID == id {
-			data.Transactions[i].Amount = amount
-			data.Transactions[i].Description = description
-			data.Transactions[i].Currency = currency
-			data.Transactions[i].Notes = notes
-			data.Transactions[i].IsPositive = action == "add-income"
-			break
+	isPositive := action == "add-income"
+	_, err = h.financeStore.UpdateTransaction(requestActor(c), id, func(data *models.FinanceData, t *models.Transaction) error {
+		if t.IsInternal() {
+			return formError("Ошибка: Перевод или обмен нельзя изменить, удалите его и создайте заново")
 		}
-	}
-
-	h.financeStore.RecalculateBalances()
-	if err := h.financeStore.Save(); err != nil {
-		c.Redirect(http.StatusFound, "/?message=Ошибка при сохранении данных")
+		accountID, currency, errMsg := resolveAccount(data, c.PostForm("account"), currency)
+		if errMsg != "" {
+			return formError(errMsg)
+		}
+		categoryID, errMsg := parseCategoryID(c.PostForm("category"), data.Categories, isPositive)
+		if errMsg != "" {
+			return formError(errMsg)
+		}
+		dateTime, errMsg := parseTransactionDateTime(c.PostForm("datetime"), c.PostForm("tz_offset"), t.DateTime)
+		if errMsg != "" {
+			return formError(errMsg)
+		}
+		t.DateTime = dateTime
+		t.Amount = amount
+		t.Description = description
+		t.Currency = currency
+		t.Notes = notes
+		t.IsPositive = isPositive
+		t.CategoryID = categoryID
+		t.AccountID = accountID
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/?message="+errorMessage(err))
 		return
 	}
 
@@ -344,19 +385,91 @@ func (h *FinanceHandler) DeleteTransaction(c *gin.Context) {
 		return
 	}
 
-	data := h.financeStore.GetData()
-	for i, t := range data.Transactions {
-		if t.ID == id {
-			data.Transactions = append(data.Transactions[:i], data.Transactions[i+1:]...)
-			break
-		}
+	// Перевод и обмен удаляются целиком, вместе с парной операцией
+	if _, err := h.financeStore.DeleteTransactions(requestActor(c), []int{id}); err != nil {
+		c.Redirect(http.StatusFound, "/?message=Ошибка при сохранении данных")
+		return
 	}
 
-	h.financeStore.RecalculateBalances()
-	if err := h.financeStore.Save(); err != nil {
-		c.Redirect(http.StatusFound, "/?message=Ошибка при сохранении данных")
+	c.Redirect(http.StatusFound, "/?message=Транзакция перенесена в корзину")
+}
+
+// Undo отменяет последнее добавление, изменение или удаление операций
+func (h *FinanceHandler) Undo(c *gin.Context) {
+	if _, err := h.financeStore.Undo(requestActor(c)); err != nil {
+		c.Redirect(http.StatusFound, "/?message="+undoErrorMessage(err))
 		return
 	}
 
-	c.Redirect(http.StatusFound, "/?message=Транзакция удалена")
+	c.Redirect(http.StatusFound, "/?message=Действие отменено")
+}
+
+// parseTransactionDateTime разбирает дату и время операции из поля
+// datetime-local. tzOffset — смещение часового пояса браузера в минутах
+// (результат getTimezoneOffset), чтобы время трактовалось так, как его ввёл
+// пользователь, а не в поясе сервера. Пустое значение возвращает fallback.
+func parseTransactionDateTime(value, tzOffset string, fallback time.Time) (time.Time, string) {
+	if value == "" {
+		return fallback, ""
+	}
+
+	loc := time.Local
+	if tzOffset != "" {
+		offset, err := strconv.Atoi(tzOffset)
+		if err != nil || offset < -14*60 || offset > 14*60 {
+			return time.Time{}, "Ошибка: Неверный часовой пояс"
+		}
+		loc = time.FixedZone("", -offset*60)
+	}
+
+	dateTime, err := time.ParseInLocation("2006-01-02T15:04", value, loc)
+	if err != nil {
+		return time.Time{}, "Ошибка: Неверный формат даты и времени"
+	}
+	if errMsg := checkTransactionDate(dateTime); errMsg != "" {
+		return time.Time{}, errMsg
+	}
+	return dateTime, ""
+}
+
+// checkTransactionDate проверяет, что дата операции лежит в допустимом
+// диапазоне: не раньше 2000 года и не позже чем через 5 лет
+func checkTransactionDate(dateTime time.Time) string {
+	if dateTime.Before(time.Date(2000, 1, 1, 0, 0, 0, 0, dateTime.Location())) {
+		return "Ошибка: Слишком ранняя дата"
+	}
+	if dateTime.After(time.Now().AddDate(5, 0, 0)) {
+		return "Ошибка: Дата не может быть позже чем через 5 лет"
+	}
+	return ""
+}
+
+// formError — ошибка проверки данных формы внутри транзакции хранилища. Её
+// текст показывается пользователю как есть.
+type formError string
+
+func (e formError) Error() string {
+	return string(e)
+}
+
+// errorMessage возвращает текст уведомления об ошибке изменения данных
+func errorMessage(err error) string {
+	var fe formError
+	if errors.As(err, &fe) {
+		return string(fe)
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return "Ошибка: Запись не найдена"
+	}
+	return "Ошибка при сохранении данных"
+}
+
+// requestActor возвращает автора изменения для журнала: пользователя и
+// источник — API или веб-интерфейс
+func requestActor(c *gin.Context) storage.Actor {
+	source := storage.SourceWeb
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		source = storage.SourceAPI
+	}
+	return storage.Actor{User: auth.User(c), Source: source}
 }
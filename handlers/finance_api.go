@@ -0,0 +1,632 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"finance-tracker/models"
+	"finance-tracker/storage"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Версия API /api/v1 работает с типизированным JSON: суммы — числа, даты —
+// RFC3339. Ошибки возвращаются в виде {"error": {...}} с машинным кодом,
+// сообщением и списком ошибок по полям.
+
+const (
+	apiDefaultLimit = 50
+	apiMaxLimit     = 500
+)
+
+// apiTransaction — представление операции в API
+type apiTransaction struct {
+	ID          int       `json:"id"`
+	Type        string    `json:"type"` // "income" или "expense"
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency"`
+	Description string    `json:"description"`
+	Notes       string    `json:"notes"`
+	DateTime    time.Time `json:"date_time"`
+	CategoryID  int       `json:"category_id"`
+	AccountID   int       `json:"account_id"`
+	RecurringID int       `json:"recurring_id,omitempty"`
+	Kind        string    `json:"kind"` // "regular", "transfer" или "exchange"
+	LinkedID    int       `json:"linked_id,omitempty"`
+	Rate        float64   `json:"rate,omitempty"`
+	Planned     bool      `json:"planned"`
+	ExternalID  string    `json:"external_id,omitempty"` // ID операции в банке для импортированных из выписки
+}
+
+// apiTransactionInput — тело запроса на создание или изменение операции.
+// Указатели позволяют отличить отсутствующее поле от нулевого значения.
+type apiTransactionInput struct {
+	Type        *string  `json:"type"`
+	Amount      *float64 `json:"amount"`
+	Currency    *string  `json:"currency"`
+	Description *string  `json:"description"`
+	Notes       *string  `json:"notes"`
+	DateTime    *string  `json:"date_time"`
+	CategoryID  *int     `json:"category_id"`
+	AccountID   *int     `json:"account_id"`
+}
+
+// apiBulkUpdate — частичное изменение операции в пакетном запросе
+type apiBulkUpdate struct {
+	ID int `json:"id"`
+	apiTransactionInput
+}
+
+// apiBulkRequest — пакетный запрос: все изменения применяются вместе или не
+// применяются вовсе
+type apiBulkRequest struct {
+	Create []apiTransactionInput `json:"create"`
+	Update []apiBulkUpdate       `json:"update"`
+	Delete []int                 `json:"delete"`
+}
+
+type apiFieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type apiError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Fields  []apiFieldError `json:"fields,omitempty"`
+}
+
+func apiAbort(c *gin.Context, status int, code, message string, fields ...apiFieldError) {
+	c.AbortWithStatusJSON(status, gin.H{"error": apiError{
+		Code:    code,
+		Message: message,
+		Fields:  fields,
+	}})
+}
+
+func apiValidationFailed(c *gin.Context, fields []apiFieldError) {
+	apiAbort(c, http.StatusUnprocessableEntity, "validation_failed", "Некорректные данные", fields...)
+}
+
+// apiFailure — ответ об ошибке, возвращаемый из транзакции хранилища. Изменения
+// откатываются, а ответ отправляется уже после снятия блокировки.
+type apiFailure struct {
+	status int
+	body   apiError
+}
+
+func (e *apiFailure) Error() string {
+	return e.body.Message
+}
+
+func apiFail(status int, code, message string) error {
+	return &apiFailure{status: status, body: apiError{Code: code, Message: message}}
+}
+
+func apiValidationError(fields []apiFieldError) error {
+	return &apiFailure{
+		status: http.StatusUnprocessableEntity,
+		body:   apiError{Code: "validation_failed", Message: "Некорректные данные", Fields: fields},
+	}
+}
+
+// apiStoreFailed отвечает на ошибку изменения данных: ошибки API передаются
+// клиенту как есть, отсутствующая запись даёт 404, остальное — ошибка сохранения
+func apiStoreFailed(c *gin.Context, err error, notFound string) {
+	var failure *apiFailure
+	switch {
+	case errors.As(err, &failure):
+		apiAbort(c, failure.status, failure.body.Code, failure.body.Message, failure.body.Fields...)
+	case errors.Is(err, storage.ErrNotFound):
+		apiAbort(c, http.StatusNotFound, "not_found", notFound)
+	default:
+		apiAbort(c, http.StatusInternalServerError, "save_failed", "Ошибка при сохранении данных")
+	}
+}
+
+// apiMessage убирает префикс «Ошибка: » из сообщений общих валидаторов формы
+func apiMessage(errMsg string) string {
+	return strings.TrimPrefix(errMsg, "Ошибка: ")
+}
+
+// decodeJSON разбирает тело запроса, отклоняя неизвестные поля, чтобы опечатки
+// в скриптах не терялись молча
+func decodeJSON(c *gin.Context, v interface{}) error {
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+func toAPITransaction(t models.Transaction, now time.Time) apiTransaction {
+	transactionType := "expense"
+	if t.IsPositive {
+		transactionType = "income"
+	}
+	kind := t.Kind
+	if kind == "" {
+		kind = "regular"
+	}
+	return apiTransaction{
+		ID:          t.ID,
+		Type:        transactionType,
+		Amount:      t.Amount,
+		Currency:    t.Currency,
+		Description: t.Description,
+		Notes:       t.Notes,
+		DateTime:    t.DateTime,
+		CategoryID:  t.CategoryID,
+		AccountID:   t.AccountID,
+		RecurringID: t.RecurringID,
+		Kind:        kind,
+		ExternalID:  t.ExternalID,
+		LinkedID:    t.LinkedID,
+		Rate:        t.Rate,
+		Planned:     t.IsPlanned(now),
+	}
+}
+
+// applyTransactionInput применяет поля запроса к операции. При partial
+// отсутствующие поля сохраняют прежние значения, иначе обязательные поля
+// должны быть указаны. prefix добавляется к именам полей в ошибках.
+// Вызывается внутри транзакции хранилища.
+func applyTransactionInput(data *models.FinanceData, t models.Transaction, in apiTransactionInput, partial bool, prefix string) (models.Transaction, []apiFieldError) {
+	var errs []apiFieldError
+	fail := func(field, message string) {
+		errs = append(errs, apiFieldError{Field: prefix + field, Message: message})
+	}
+	required := "Обязательное поле"
+
+	switch {
+	case in.Type != nil:
+		switch *in.Type {
+		case "income":
+			t.IsPositive = true
+		case "expense":
+			t.IsPositive = false
+		default:
+			fail("type", "Допустимые значения: income, expense")
+		}
+	case !partial:
+		fail("type", required)
+	}
+
+	switch {
+	case in.Amount != nil:
+		if *in.Amount <= 0 {
+			fail("amount", "Сумма должна быть больше нуля")
+		} else {
+			t.Amount = *in.Amount
+		}
+	case !partial:
+		fail("amount", required)
+	}
+
+	switch {
+	case in.Description != nil:
+		description := strings.TrimSpace(*in.Description)
+		if description == "" {
+			fail("description", "Описание не может быть пустым")
+		} else {
+			t.Description = description
+		}
+	case !partial:
+		fail("description", required)
+	}
+
+	if in.Notes != nil {
+		t.Notes = *in.Notes
+	}
+
+	if in.DateTime != nil {
+		dateTime, err := time.Parse(time.RFC3339, *in.DateTime)
+		if err != nil {
+			fail("date_time", "Ожидается дата в формате RFC3339, например 2025-03-01T12:00:00+03:00")
+		} else if errMsg := checkTransactionDate(dateTime); errMsg != "" {
+			fail("date_time", apiMessage(errMsg))
+		} else {
+			t.DateTime = dateTime
+		}
+	} else if t.DateTime.IsZero() {
+		t.DateTime = time.Now()
+	}
+
+	// Счёт определяет валюту; без счёта используется счёт по умолчанию для валюты
+	if in.AccountID != nil || in.Currency != nil || !partial {
+		accountValue := ""
+		if in.AccountID != nil {
+			accountValue = strconv.Itoa(*in.AccountID)
+		}
+		currency := ""
+		if in.Currency != nil {
+			currency = strings.ToUpper(strings.TrimSpace(*in.Currency))
+		}
+		if accountValue == "" && currency == "" {
+			fail("currency", "Укажите currency или account_id")
+		} else {
+			accountID, accountCurrency, errMsg := resolveAccount(data, accountValue, currency)
+			switch {
+			case errMsg != "":
+				field := "account_id"
+				if accountValue == "" {
+					field = "currency"
+				}
+				fail(field, apiMessage(errMsg))
+			case currency != "" && currency != accountCurrency:
+				fail("currency", fmt.Sprintf("Валюта счёта — %s", accountCurrency))
+			default:
+				t.AccountID = accountID
+				t.Currency = accountCurrency
+			}
+		}
+	}
+
+	// Категория проверяется и при смене типа операции
+	if in.CategoryID != nil {
+		t.CategoryID = *in.CategoryID
+	}
+	if _, errMsg := parseCategoryID(strconv.Itoa(t.CategoryID), data.Categories, t.IsPositive); errMsg != "" {
+		fail("category_id", apiMessage(errMsg))
+	}
+
+	return t, errs
+}
+
+// filterAPITransactions отбирает операции по параметрам запроса
+func filterAPITransactions(c *gin.Context, store *storage.FinanceStorage, now time.Time) ([]models.Transaction, []apiFieldError) {
+	var errs []apiFieldError
+
+	parseBound := func(name string, endOfDay bool) time.Time {
+		value := c.Query(name)
+		if value == "" {
+			return time.Time{}
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t
+		}
+		t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			errs = append(errs, apiFieldError{Field: name, Message: "Ожидается дата YYYY-MM-DD или RFC3339"})
+			return time.Time{}
+		}
+		if endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t
+	}
+	parseInt := func(name string) int {
+		value := c.Query(name)
+		if value == "" {
+			return -1
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			errs = append(errs, apiFieldError{Field: name, Message: "Ожидается неотрицательное целое число"})
+			return -1
+		}
+		return n
+	}
+
+	from := parseBound("from", false)
+	to := parseBound("to", true)
+	categoryID := parseInt("category_id")
+	accountID := parseInt("account_id")
+	transactionType := c.Query("type")
+	if transactionType != "" && transactionType != "income" && transactionType != "expense" {
+		errs = append(errs, apiFieldError{Field: "type", Message: "Допустимые значения: income, expense"})
+	}
+	kind := c.Query("kind")
+	if kind != "" && kind != "regular" && kind != models.KindTransfer && kind != models.KindExchange {
+		errs = append(errs, apiFieldError{Field: "kind", Message: "Допустимые значения: regular, transfer, exchange"})
+	}
+	planned := c.Query("planned")
+	if planned != "" && planned != "true" && planned != "false" {
+		errs = append(errs, apiFieldError{Field: "planned", Message: "Допустимые значения: true, false"})
+	}
+	currency := strings.ToUpper(c.Query("currency"))
+	query, err := storage.ParseSearchQuery(c.Query("q"))
+	if err != nil {
+		errs = append(errs, apiFieldError{Field: "q", Message: "Ошибка в запросе: " + err.Error()})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return store.SearchTransactions(query, func(t models.Transaction) bool {
+		if transactionType == "income" && !t.IsPositive || transactionType == "expense" && t.IsPositive {
+			return false
+		}
+		if !from.IsZero() && t.DateTime.Before(from) || !to.IsZero() && t.DateTime.After(to) {
+			return false
+		}
+		if currency != "" && t.Currency != currency {
+			return false
+		}
+		if categoryID >= 0 && t.CategoryID != categoryID || accountID >= 0 && t.AccountID != accountID {
+			return false
+		}
+		if kind == "regular" && t.Kind != "" || kind != "" && kind != "regular" && t.Kind != kind {
+			return false
+		}
+		return planned == "" || t.IsPlanned(now) == (planned == "true")
+	}), nil
+}
+
+// APIListTransactions — GET /api/v1/transactions. Фильтры: type, from, to,
+// currency, category_id, account_id, kind, planned и поисковый запрос q в
+// синтаксисе storage.ParseSearchQuery; пагинация limit/offset;
+// order=asc|desc по дате (по умолчанию новые сверху).
+func (h *FinanceHandler) APIListTransactions(c *gin.Context) {
+	now := time.Now()
+	filtered, errs := filterAPITransactions(c, h.financeStore, now)
+
+	limit, offset := apiDefaultLimit, 0
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > apiMaxLimit {
+			errs = append(errs, apiFieldError{Field: "limit", Message: fmt.Sprintf("Ожидается число от 1 до %d", apiMaxLimit)})
+		}
+		limit = n
+	}
+	if value := c.Query("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			errs = append(errs, apiFieldError{Field: "offset", Message: "Ожидается неотрицательное целое число"})
+		}
+		offset = n
+	}
+	order := c.DefaultQuery("order", "desc")
+	if order != "asc" && order != "desc" {
+		errs = append(errs, apiFieldError{Field: "order", Message: "Допустимые значения: asc, desc"})
+	}
+	if len(errs) > 0 {
+		apiAbort(c, http.StatusBadRequest, "invalid_query", "Некорректные параметры запроса", errs...)
+		return
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if order == "asc" {
+			return filtered[i].DateTime.Before(filtered[j].DateTime)
+		}
+		return filtered[i].DateTime.After(filtered[j].DateTime)
+	})
+
+	total := len(filtered)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	items := make([]apiTransaction, 0, end-offset)
+	for _, t := range filtered[offset:end] {
+		items = append(items, toAPITransaction(t, now))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// apiTransactionID разбирает ID операции из пути запроса и отвечает 400, если
+// это не удалось
+func apiTransactionID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apiAbort(c, http.StatusBadRequest, "invalid_id", "Неверный ID операции")
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *FinanceHandler) APIGetTransaction(c *gin.Context) {
+	id, ok := apiTransactionID(c)
+	if !ok {
+		return
+	}
+	found := h.financeStore.QueryTransactions(func(t models.Transaction) bool { return t.ID == id })
+	if len(found) == 0 {
+		apiAbort(c, http.StatusNotFound, "not_found", "Операция не найдена")
+		return
+	}
+	c.JSON(http.StatusOK, toAPITransaction(found[0], time.Now()))
+}
+
+func (h *FinanceHandler) APICreateTransaction(c *gin.Context) {
+	var in apiTransactionInput
+	if err := decodeJSON(c, &in); err != nil {
+		apiAbort(c, http.StatusBadRequest, "invalid_json", "Некорректный JSON: "+err.Error())
+		return
+	}
+
+	t, err := h.financeStore.AddTransaction(requestActor(c), func(data *models.FinanceData) (models.Transaction, error) {
+		t, errs := applyTransactionInput(data, models.Transaction{}, in, false, "")
+		if len(errs) > 0 {
+			return t, apiValidationError(errs)
+		}
+		return t, nil
+	})
+	if err != nil {
+		apiStoreFailed(c, err, "Операция не найдена")
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/v1/transactions/%d", t.ID))
+	c.JSON(http.StatusCreated, toAPITransaction(t, time.Now()))
+}
+
+// APIUpdateTransaction обрабатывает PUT (все обязательные поля) и PATCH
+// (только переданные поля). Переводы и обмены через API не изменяются.
+func (h *FinanceHandler) APIUpdateTransaction(c *gin.Context) {
+	id, ok := apiTransactionID(c)
+	if !ok {
+		return
+	}
+
+	var in apiTransactionInput
+	if err := decodeJSON(c, &in); err != nil {
+		apiAbort(c, http.StatusBadRequest, "invalid_json", "Некорректный JSON: "+err.Error())
+		return
+	}
+
+	partial := c.Request.Method == http.MethodPatch
+	t, err := h.financeStore.UpdateTransaction(requestActor(c), id, func(data *models.FinanceData, t *models.Transaction) error {
+		if t.IsInternal() {
+			return apiFail(http.StatusConflict, "internal_transaction", "Перевод или обмен нельзя изменить, удалите его и создайте заново")
+		}
+		updated, errs := applyTransactionInput(data, *t, in, partial, "")
+		if len(errs) > 0 {
+			return apiValidationError(errs)
+		}
+		*t = updated
+		return nil
+	})
+	if err != nil {
+		apiStoreFailed(c, err, "Операция не найдена")
+		return
+	}
+
+	c.JSON(http.StatusOK, toAPITransaction(t, time.Now()))
+}
+
+// APIDeleteTransaction переносит операцию в корзину; перевод и обмен
+// удаляются вместе с парной операцией
+func (h *FinanceHandler) APIDeleteTransaction(c *gin.Context) {
+	id, ok := apiTransactionID(c)
+	if !ok {
+		return
+	}
+
+	removed, err := h.financeStore.DeleteTransactions(requestActor(c), []int{id})
+	if err == nil && len(removed) == 0 {
+		err = storage.ErrNotFound
+	}
+	if err != nil {
+		apiStoreFailed(c, err, "Операция не найдена")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// APIUndo — POST /api/v1/transactions/undo: отменяет последнее добавление,
+// изменение или удаление операций, в том числе пакетное
+func (h *FinanceHandler) APIUndo(c *gin.Context) {
+	action, err := h.financeStore.Undo(requestActor(c))
+	if err != nil {
+		apiUndoFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"undone": toAPIUndoAction(action)})
+}
+
+// APIBulkTransactions — POST /api/v1/transactions/bulk. Сначала проверяются
+// все элементы, и только если ошибок нет, изменения применяются и сохраняются
+// одним действием.
+func (h *FinanceHandler) APIBulkTransactions(c *gin.Context) {
+	var req apiBulkRequest
+	if err := decodeJSON(c, &req); err != nil {
+		apiAbort(c, http.StatusBadRequest, "invalid_json", "Некорректный JSON: "+err.Error())
+		return
+	}
+	if len(req.Create)+len(req.Update)+len(req.Delete) == 0 {
+		apiAbort(c, http.StatusBadRequest, "empty_request", "Пустой пакетный запрос")
+		return
+	}
+
+	var created, updated []models.Transaction
+	var removed []int
+	err := h.financeStore.Change(requestActor(c), storage.UndoBulk, func(data *models.FinanceData) error {
+		index := make(map[int]int, len(data.Transactions))
+		for i, t := range data.Transactions {
+			index[t.ID] = i
+		}
+
+		var errs []apiFieldError
+		deleted := make(map[int]bool)
+		for n, id := range req.Delete {
+			if _, ok := index[id]; !ok {
+				errs = append(errs, apiFieldError{Field: fmt.Sprintf("delete[%d]", n), Message: "Операция не найдена"})
+			}
+			deleted[id] = true
+		}
+
+		updated = make([]models.Transaction, 0, len(req.Update))
+		seen := make(map[int]bool)
+		for n, u := range req.Update {
+			prefix := fmt.Sprintf("update[%d].", n)
+			i, ok := index[u.ID]
+			switch {
+			case !ok:
+				errs = append(errs, apiFieldError{Field: prefix + "id", Message: "Операция не найдена"})
+				continue
+			case deleted[u.ID]:
+				errs = append(errs, apiFieldError{Field: prefix + "id", Message: "Операция удаляется в этом же запросе"})
+				continue
+			case seen[u.ID]:
+				errs = append(errs, apiFieldError{Field: prefix + "id", Message: "Операция изменяется в запросе повторно"})
+				continue
+			case data.Transactions[i].IsInternal():
+				errs = append(errs, apiFieldError{Field: prefix + "id", Message: "Перевод или обмен нельзя изменить"})
+				continue
+			}
+			seen[u.ID] = true
+			t, fieldErrs := applyTransactionInput(data, data.Transactions[i], u.apiTransactionInput, true, prefix)
+			errs = append(errs, fieldErrs...)
+			updated = append(updated, t)
+		}
+
+		created = make([]models.Transaction, 0, len(req.Create))
+		for n, in := range req.Create {
+			t, fieldErrs := applyTransactionInput(data, models.Transaction{}, in, false, fmt.Sprintf("create[%d].", n))
+			errs = append(errs, fieldErrs...)
+			created = append(created, t)
+		}
+
+		if len(errs) > 0 {
+			return apiValidationError(errs)
+		}
+
+		for _, t := range updated {
+			data.Transactions[index[t.ID]] = t
+		}
+		// Вместе с половиной перевода или обмена удаляется и парная операция,
+		// поэтому клиенту возвращаются ID всех операций, попавших в корзину
+		removed = storage.RemoveTransactions(data, req.Delete)
+		nextID := storage.NextTransactionID(data)
+		for i := range created {
+			created[i].ID = nextID
+			nextID++
+			data.Transactions = append(data.Transactions, created[i])
+		}
+		return nil
+	})
+	if err != nil {
+		apiStoreFailed(c, err, "Операция не найдена")
+		return
+	}
+
+	now := time.Now()
+	createdItems := make([]apiTransaction, 0, len(created))
+	for _, t := range created {
+		createdItems = append(createdItems, toAPITransaction(t, now))
+	}
+	updatedItems := make([]apiTransaction, 0, len(updated))
+	for _, t := range updated {
+		updatedItems = append(updatedItems, toAPITransaction(t, now))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"created": createdItems,
+		"updated": updatedItems,
+		"deleted": removed,
+	})
+}
@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"finance-tracker/models"
+	"finance-tracker/storage"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Импорт операций из CSV и банковских выписок (OFX, QIF, CAMT.053) проходит
+// в три шага: загрузка файла, предпросмотр с настройкой колонок и
+// сохранение. Содержимое файла между шагами передаётся в скрытом поле формы,
+// поэтому сервер ничего не хранит до сохранения.
+
+const (
+	importMaxSize     = 5 << 20 // Наибольший размер файла импорта
+	importPreviewRows = 10      // Строк файла в таблице колонок
+)
+
+type ImportHandler struct {
+	financeStore *storage.FinanceStorage
+}
+
+func NewImportHandler(financeStore *storage.FinanceStorage) *ImportHandler {
+	return &ImportHandler{financeStore: financeStore}
+}
+
+// importSigns — подписи способов задания знака суммы
+var importSigns = []gin.H{
+	{"Value": storage.SignSigned, "Title": "Минус — расход, плюс — доход"},
+	{"Value": storage.SignInverted, "Title": "Плюс — расход, минус — доход"},
+	{"Value": storage.SignExpense, "Title": "Все строки — расходы"},
+}
+
+// importStatuses — подписи состояний строк импорта
+var importStatuses = map[string]string{
+	storage.ImportReady:     "Будет импортирована",
+	storage.ImportDuplicate: "Возможный дубликат",
+	storage.ImportExists:    "Уже импортирована",
+	storage.ImportInvalid:   "Ошибка",
+	storage.ImportImported:  "Импортирована",
+	storage.ImportSkipped:   "Пропущена как дубликат",
+}
+
+// importFile возвращает содержимое файла импорта: загруженного или
+// переданного с предыдущего шага в поле data
+func importFile(c *gin.Context) ([]byte, string) {
+	if encoded := c.PostForm("data"); encoded != "" {
+		body, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, "Ошибка: Повреждены данные файла, загрузите его заново"
+		}
+		return body, ""
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return nil, "Ошибка: Выберите файл"
+	}
+	if file.Size > importMaxSize {
+		return nil, "Ошибка: Файл больше 5 МБ"
+	}
+	f, err := file.Open()
+	if err != nil {
+		return nil, "Ошибка: Не удалось открыть файл"
+	}
+	defer f.Close()
+	body, err := io.ReadAll(f)
+	if err != nil {
+		return nil, "Ошибка: Не удалось прочитать файл"
+	}
+	return body, ""
+}
+
+// importColumn разбирает номер колонки из поля формы: пусто — колонки нет
+func importColumn(value string, columns int) (int, bool) {
+	if value == "" {
+		return -1, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n >= columns {
+		return 0, false
+	}
+	return n, true
+}
+
+// importMapping читает соответствие колонок из формы. Если форма ещё не
+// заполнялась, соответствие подбирается по заголовкам файла.
+func importMapping(c *gin.Context, records [][]string) (storage.CSVMapping, string) {
+	if c.PostForm("mapped") == "" {
+		m := storage.GuessCSVMapping(records)
+		m.DefaultCurrency = strings.ToUpper(strings.TrimSpace(c.PostForm("currency")))
+		return m, ""
+	}
+
+	columns := 0
+	for _, record := range records {
+		if len(record) > columns {
+			columns = len(record)
+		}
+	}
+	m := storage.CSVMapping{
+		HasHeader:       c.PostForm("has_header") != "",
+		Sign:            c.PostForm("sign"),
+		DateFormat:      c.PostForm("date_format"),
+		DefaultCurrency: strings.ToUpper(strings.TrimSpace(c.PostForm("currency"))),
+	}
+	fields := []struct {
+		name   string
+		column *int
+	}{
+		{"col_amount", &m.Amount},
+		{"col_description", &m.Description},
+		{"col_datetime", &m.DateTime},
+		{"col_currency", &m.Currency},
+		{"col_notes", &m.Notes},
+	}
+	for _, f := range fields {
+		column, ok := importColumn(c.PostForm(f.name), columns)
+		if !ok {
+			return m, "Ошибка: Неверный номер колонки"
+		}
+		*f.column = column
+	}
+	if !storage.ValidSign(m.Sign) {
+		return m, "Ошибка: Неверный способ задания знака суммы"
+	}
+	if !storage.ValidCSVDateFormat(m.DateFormat) {
+		return m, "Ошибка: Неверный формат даты"
+	}
+	return m, ""
+}
+
+// checkImportMapping проверяет, что обязательные колонки выбраны
+func checkImportMapping(m storage.CSVMapping) string {
+	switch {
+	case m.Amount < 0:
+		return "Выберите колонку суммы"
+	case m.Description < 0:
+		return "Выберите колонку описания"
+	case m.DateTime < 0:
+		return "Выберите колонку даты"
+	case m.Currency < 0 && m.DefaultCurrency == "":
+		return "Выберите колонку валюты или укажите валюту"
+	}
+	return ""
+}
+
+// importSource — разобранный файл импорта: банковская выписка или CSV
+type importSource struct {
+	body      []byte
+	statement *storage.Statement // nil для CSV
+	records   [][]string
+	comma     rune
+	mapping   storage.CSVMapping
+}
+
+// loadImport читает файл импорта из формы и разбирает его. Формат выписки
+// определяется по содержимому, остальные файлы считаются CSV.
+func loadImport(c *gin.Context) (importSource, string) {
+	body, errMsg := importFile(c)
+	if errMsg != "" {
+		return importSource{}, errMsg
+	}
+	if format := storage.DetectStatementFormat(body); format != "" {
+		statement, err := storage.ParseStatement(body, format, strings.TrimSpace(c.PostForm("currency")))
+		if err != nil {
+			return importSource{}, "Ошибка: " + err.Error()
+		}
+		return importSource{body: body, statement: &statement}, ""
+	}
+
+	records, comma, err := storage.ReadCSV(bytes.NewReader(body))
+	if err != nil {
+		return importSource{}, "Ошибка: " + err.Error()
+	}
+	m, errMsg := importMapping(c, records)
+	if errMsg != "" {
+		return importSource{}, errMsg
+	}
+	return importSource{body: body, records: records, comma: comma, mapping: m}, ""
+}
+
+// problem возвращает, чего не хватает для разбора строк CSV
+func (s importSource) problem() string {
+	if s.statement != nil {
+		return ""
+	}
+	return checkImportMapping(s.mapping)
+}
+
+// rows возвращает строки импорта и признак того, что валюта указана в файле
+func (s importSource) rows() ([]storage.ImportRow, bool) {
+	if s.statement != nil {
+		return append([]storage.ImportRow(nil), s.statement.Rows...), s.statement.Currency != ""
+	}
+	return storage.ParseCSVTransactions(s.records, s.mapping), s.mapping.Currency >= 0
+}
+
+// importAccount возвращает выбранный счёт. Пока форма не заполнялась, для
+// выписки выбирается счёт, сопоставленный с её номером счёта в банке.
+func importAccount(c *gin.Context, data *models.FinanceData, source importSource) string {
+	if c.PostForm("mapped") == "" && source.statement != nil {
+		if account, ok := storage.FindBankAccount(data, source.statement.Account); ok {
+			return strconv.Itoa(account.ID)
+		}
+	}
+	return c.PostForm("account")
+}
+
+// prepareImportRows разбирает файл и проверяет строки: дату, счёт и
+// совпадения с уже заведёнными операциями. Возвращает строки и выбранный счёт
+// (нулевой, если операции распределяются по счетам по умолчанию).
+// Вызывается внутри Update или на снимке данных для предпросмотра.
+func prepareImportRows(data *models.FinanceData, source importSource, accountValue string) ([]storage.ImportRow, models.Account, string) {
+	rows, currencyFromFile := source.rows()
+	for i, row := range rows {
+		if row.Status != storage.ImportInvalid {
+			if errMsg := checkTransactionDate(row.Transaction.DateTime); errMsg != "" {
+				rows[i] = storage.ImportRow{Line: row.Line, Status: storage.ImportInvalid, Error: strings.TrimPrefix(errMsg, "Ошибка: ")}
+			}
+		}
+	}
+
+	var account models.Account
+	if accountValue != "" && accountValue != "0" {
+		id, err := strconv.Atoi(accountValue)
+		if err != nil {
+			return nil, account, "Ошибка: Неверный счёт"
+		}
+		found, ok := accountMap(data.Accounts)[id]
+		if !ok {
+			return nil, account, "Ошибка: Счёт не найден"
+		}
+		if found.Archived {
+			return nil, account, "Ошибка: Счёт в архиве"
+		}
+		account = found
+		storage.AssignImportAccount(rows, account, currencyFromFile)
+	}
+	for i, row := range rows {
+		if row.Status != storage.ImportInvalid && row.Transaction.Currency == "" {
+			rows[i] = storage.ImportRow{Line: row.Line, Status: storage.ImportInvalid, Error: "не указана валюта, выберите счёт или валюту"}
+		}
+	}
+
+	storage.MarkImportDuplicates(data, rows)
+	return rows, account, ""
+}
+
+func formatImportRows(rows []storage.ImportRow, accounts map[int]models.Account) []gin.H {
+	items := make([]gin.H, len(rows))
+	for i, row := range rows {
+		t := row.Transaction
+		item := gin.H{
+			"Line":        row.Line,
+			"Status":      row.Status,
+			"StatusTitle": importStatuses[row.Status],
+			"Error":       row.Error,
+			"DuplicateOf": row.DuplicateOf,
+		}
+		if row.Status != storage.ImportInvalid {
+			item["ID"] = t.ID
+			item["Amount"] = fmt.Sprintf("%.2f", t.Amount)
+			item["Currency"] = t.Currency
+			item["Description"] = t.Description
+			item["DateTime"] = t.DateTime.Format("02.01.2006 15:04")
+			item["IsPositive"] = t.IsPositive
+			item["Notes"] = t.Notes
+			item["Account"] = accounts[t.AccountID].Name
+		}
+		items[i] = item
+	}
+	return items
+}
+
+// importView — общие данные страницы импорта
+func importView(data *models.FinanceData) gin.H {
+	return gin.H{
+		"Accounts":    activeAccounts(data.Accounts),
+		"Signs":       importSigns,
+		"DateFormats": storage.CSVDateFormats,
+	}
+}
+
+// Import показывает форму загрузки файла
+func (h *ImportHandler) Import(c *gin.Context) {
+	c.HTML(http.StatusOK, "import.html", importView(h.financeStore.Snapshot()))
+}
+
+// Preview разбирает загруженный файл и показывает, какие строки будут
+// импортированы, с выбором колонок и формата для CSV
+func (h *ImportHandler) Preview(c *gin.Context) {
+	source, errMsg := loadImport(c)
+	if errMsg != "" {
+		c.Redirect(http.StatusFound, "/import?message="+url.QueryEscape(errMsg))
+		return
+	}
+
+	data := h.financeStore.Snapshot()
+	account := importAccount(c, data, source)
+	view := importView(data)
+	view["Data"] = base64.StdEncoding.EncodeToString(source.body)
+	view["Account"] = account
+	view["Currency"] = strings.ToUpper(strings.TrimSpace(c.PostForm("currency")))
+
+	if st := source.statement; st != nil {
+		view["Statement"] = gin.H{
+			"Format":   storage.StatementTitles[st.Format],
+			"Account":  st.Account,
+			"Currency": st.Currency,
+		}
+	} else {
+		records, m := source.records, source.mapping
+		view["Mapping"] = m
+		view["Delimiter"] = map[rune]string{',': "запятая", ';': "точка с запятой", '\t': "табуляция"}[source.comma]
+		sample := records
+		if len(sample) > importPreviewRows {
+			sample = sample[:importPreviewRows]
+		}
+		columns := 0
+		for _, record := range records {
+			if len(record) > columns {
+				columns = len(record)
+			}
+		}
+		columnTitles := make([]gin.H, columns)
+		for i := range columnTitles {
+			title := fmt.Sprintf("Колонка %d", i+1)
+			if m.HasHeader && i < len(records[0]) && strings.TrimSpace(records[0][i]) != "" {
+				title = fmt.Sprintf("%d: %s", i+1, strings.TrimSpace(records[0][i]))
+			}
+			columnTitles[i] = gin.H{"Index": i, "Title": title}
+		}
+		view["Columns"] = columnTitles
+		view["Sample"] = sample
+	}
+
+	if problem := source.problem(); problem != "" {
+		view["Problem"] = problem
+		c.HTML(http.StatusOK, "import.html", view)
+		return
+	}
+
+	rows, _, errMsg := prepareImportRows(data, source, account)
+	if errMsg != "" {
+		c.Redirect(http.StatusFound, "/import?message="+url.QueryEscape(errMsg))
+		return
+	}
+	counts := map[string]int{}
+	for _, row := range rows {
+		counts[row.Status]++
+	}
+	view["Rows"] = formatImportRows(rows, accountMap(data.Accounts))
+	view["Ready"] = counts[storage.ImportReady]
+	view["Duplicates"] = counts[storage.ImportDuplicate]
+	view["Exists"] = counts[storage.ImportExists]
+	view["Invalid"] = counts[storage.ImportInvalid]
+
+	c.HTML(http.StatusOK, "import.html", view)
+}
+
+// Commit сохраняет разобранные строки одним изменением и показывает итог по
+// каждой строке. Импорт целиком отменяется кнопкой отмены на главной. Счёт,
+// выбранный для выписки, запоминается для её номера счёта в банке.
+func (h *ImportHandler) Commit(c *gin.Context) {
+	source, errMsg := loadImport(c)
+	if errMsg == "" {
+		if problem := source.problem(); problem != "" {
+			errMsg = "Ошибка: " + problem
+		}
+	}
+	if errMsg != "" {
+		c.Redirect(http.StatusFound, "/import?message="+url.QueryEscape(errMsg))
+		return
+	}
+
+	actor := requestActor(c)
+	actor.Source = storage.SourceImport
+	var rows []storage.ImportRow
+	var result storage.ImportResult
+	err := h.financeStore.Change(actor, storage.UndoBulk, func(data *models.FinanceData) error {
+		var account models.Account
+		var errMsg string
+		rows, account, errMsg = prepareImportRows(data, source, c.PostForm("account"))
+		if errMsg != "" {
+			return formError(errMsg)
+		}
+		result = storage.ApplyImport(data, rows, c.PostForm("include_duplicates") != "")
+
+		if source.statement != nil && source.statement.Account != "" && account.ID != 0 && account.BankAccount == "" {
+			for i := range data.Accounts {
+				if data.Accounts[i].ID == account.ID {
+					data.Accounts[i].BankAccount = source.statement.Account
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/import?message="+url.QueryEscape(errorMessage(err)))
+		return
+	}
+
+	data := h.financeStore.Snapshot()
+	view := importView(data)
+	view["Report"] = formatImportRows(rows, accountMap(data.Accounts))
+	view["Result"] = result
+	c.HTML(http.StatusOK, "import.html", view)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"finance-tracker/models"
+	"finance-tracker/storage"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RatesHandler struct {
+	financeStore *storage.FinanceStorage
+}
+
+func NewRatesHandler(financeStore *storage.FinanceStorage) *RatesHandler {
+	return &RatesHandler{financeStore: financeStore}
+}
+
+// knownCurrencies возвращает отсортированный список валют, встречающихся в
+// счетах, операциях и курсах, вместе с национальной валютой
+func knownCurrencies(data *models.FinanceData) []string {
+	set := map[string]bool{storage.NationalCurrency: true, "USD": true, "EUR": true}
+	for _, a := range data.Accounts {
+		set[a.Currency] = true
+	}
+	for _, t := range data.Transactions {
+		set[t.Currency] = true
+	}
+	for _, r := range data.Rates {
+		set[r.Currency] = true
+	}
+	result := []string{}
+	for currency := range set {
+		if currency != "" {
+			result = append(result, currency)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (h *RatesHandler) Rates(c *gin.Context) {
+	data := h.financeStore.Snapshot()
+
+	// Новые курсы показываем первыми
+	items := []gin.H{}
+	for i := len(data.Rates) - 1; i >= 0; i-- {
+		r := data.Rates[i]
+		items = append(items, gin.H{
+			"Date":     r.Date,
+			"Currency": r.Currency,
+			"Rate":     strconv.FormatFloat(r.Rate, 'f', -1, 64),
+		})
+	}
+
+	c.HTML(http.StatusOK, "rates.html", gin.H{
+		"Rates":            items,
+		"BaseCurrency":     storage.NewConverter(data).Base(),
+		"Currencies":       knownCurrencies(data),
+		"NationalCurrency": storage.NationalCurrency,
+		"Today":            time.Now().Format("2006-01-02"),
+	})
+}
+
+func (h *RatesHandler) AddRate(c *gin.Context) {
+	currency := strings.ToUpper(strings.TrimSpace(c.PostForm("currency")))
+	if currency == "" || currency == storage.NationalCurrency {
+		c.Redirect(http.StatusFound, "/rates?message=Ошибка: Неверная валюта")
+		return
+	}
+	date, err := time.Parse("2006-01-02", c.PostForm("date"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/rates?message=Ошибка: Неверная дата")
+		return
+	}
+	rate, err := strconv.ParseFloat(strings.ReplaceAll(c.PostForm("rate"), ",", "."), 64)
+	if err != nil || rate <= 0 {
+		c.Redirect(http.StatusFound, "/rates?message=Ошибка: Неверный курс")
+		return
+	}
+	scale := 1.0
+	if value := c.PostForm("scale"); value != "" {
+		scale, err = strconv.ParseFloat(value, 64)
+		if err != nil || scale <= 0 {
+			c.Redirect(http.StatusFound, "/rates?message=Ошибка: Неверное количество единиц")
+			return
+		}
+	}
+
+	err = h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		storage.MergeRates(data, []models.ExchangeRate{{
+			Date:     date.Format("2006-01-02"),
+			Currency: currency,
+			Rate:     rate / scale,
+		}})
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/rates?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/rates?message=Курс сохранён")
+}
+
+// ImportRates загружает файл с курсами в формате JSON API Нацбанка
+// (https://api.nbrb.by/exrates/rates?ondate=... или .../rates/dynamics/...)
+func (h *RatesHandler) ImportRates(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.Redirect(http.StatusFound, "/rates?message=Ошибка: Выберите файл")
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.Redirect(http.StatusFound, "/rates?message=Ошибка: Не удалось открыть файл")
+		return
+	}
+	defer f.Close()
+
+	rates, err := storage.ParseNBRBRates(f, c.PostForm("currency"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/rates?message=Ошибка: "+err.Error())
+		return
+	}
+
+	changed := 0
+	err = h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		changed = storage.MergeRates(data, rates)
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/rates?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprintf("/rates?message=Импортировано курсов: %d из %d", changed, len(rates)))
+}
+
+func (h *RatesHandler) DeleteRate(c *gin.Context) {
+	date := c.PostForm("date")
+	currency := c.PostForm("currency")
+
+	err := h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		for i, r := range data.Rates {
+			if r.Date == date && r.Currency == currency {
+				data.Rates = append(data.Rates[:i], data.Rates[i+1:]...)
+				return nil
+			}
+		}
+		return formError("Ошибка: Курс не найден")
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/rates?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/rates?message=Курс удалён")
+}
+
+// SetBaseCurrency меняет валюту, в которой считаются итоги и статистика
+func (h *RatesHandler) SetBaseCurrency(c *gin.Context) {
+	currency := strings.ToUpper(strings.TrimSpace(c.PostForm("currency")))
+	if currency == "" {
+		c.Redirect(http.StatusFound, "/rates?message=Ошибка: Выберите валюту")
+		return
+	}
+
+	err := h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		data.BaseCurrency = currency
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/rates?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/rates?message=Базовая валюта: "+currency)
+}
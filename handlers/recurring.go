@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"finance-tracker/models"
+	"finance-tracker/scheduler"
+	"finance-tracker/storage"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RecurringHandler struct {
+	financeStore *storage.FinanceStorage
+}
+
+func NewRecurringHandler(financeStore *storage.FinanceStorage) *RecurringHandler {
+	return &RecurringHandler{financeStore: financeStore}
+}
+
+var frequencyNames = map[string]string{
+	storage.FrequencyDaily:   "Ежедневно",
+	storage.FrequencyWeekly:  "Еженедельно",
+	storage.FrequencyMonthly: "Ежемесячно",
+	storage.FrequencyYearly:  "Ежегодно",
+}
+
+func (h *RecurringHandler) Recurring(c *gin.Context) {
+	data := h.financeStore.Snapshot()
+	categories := categoryMap(data.Categories)
+	accounts := accountMap(data.Accounts)
+
+	items := []gin.H{}
+	for _, r := range data.Recurring {
+		category := categories[r.CategoryID]
+		nextDate := ""
+		finished := storage.Finished(r)
+		if !finished {
+			nextDate = storage.Occurrence(r, r.Generated).Format("02.01.2006 15:04")
+		}
+		endDate := ""
+		if !r.EndDate.IsZero() {
+			endDate = r.EndDate.Format("02.01.2006")
+		}
+		items = append(items, gin.H{
+			"ID":           r.ID,
+			"Amount":       fmt.Sprintf("%.2f", r.Amount),
+			"Description":  r.Description,
+			"IsPositive":   r.IsPositive,
+			"Currency":     r.Currency,
+			"Notes":        r.Notes,
+			"Category":     category.Name,
+			"CategoryIcon": category.Icon,
+			"Account":      accounts[r.AccountID].Name,
+			"Frequency":    frequencyNames[r.Frequency],
+			"StartDate":    r.StartDate.Format("02.01.2006 15:04"),
+			"EndDate":      endDate,
+			"Count":        r.Count,
+			"Generated":    r.Generated,
+			"NextDate":     nextDate,
+			"Finished":     finished,
+			"Paused":       r.Paused,
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i]["ID"].(int) > items[j]["ID"].(int)
+	})
+
+	c.HTML(http.StatusOK, "recurring.html", gin.H{
+		"Items":      items,
+		"Categories": data.Categories,
+		"Accounts":   activeAccounts(data.Accounts),
+		"Today":      time.Now().Format("2006-01-02"),
+	})
+}
+
+func (h *RecurringHandler) AddRecurring(c *gin.Context) {
+	amount, err := strconv.ParseFloat(c.PostForm("amount"), 64)
+	if err != nil || amount <= 0 {
+		c.Redirect(http.StatusFound, "/recurring?message=Ошибка: Неверная сумма")
+		return
+	}
+
+	description := strings.TrimSpace(c.PostForm("description"))
+	if description == "" {
+		c.Redirect(http.StatusFound, "/recurring?message=Ошибка: Описание не может быть пустым")
+		return
+	}
+
+	currency := c.PostForm("currency")
+	if currency == "" {
+		c.Redirect(http.StatusFound, "/recurring?message=Ошибка: Выберите валюту")
+		return
+	}
+
+	frequency := c.PostForm("frequency")
+	if !storage.ValidFrequency(frequency) {
+		c.Redirect(http.StatusFound, "/recurring?message=Ошибка: Неверная периодичность")
+		return
+	}
+
+	startTime := c.PostForm("start_time")
+	if startTime == "" {
+		startTime = "09:00"
+	}
+	startDate, err := time.ParseInLocation("2006-01-02 15:04", c.PostForm("start_date")+" "+startTime, time.Local)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/recurring?message=Ошибка: Неверная дата начала")
+		return
+	}
+
+	var endDate time.Time
+	if endStr := c.PostForm("end_date"); endStr != "" {
+		endDay, err := time.ParseInLocation("2006-01-02", endStr, time.Local)
+		if err != nil {
+			c.Redirect(http.StatusFound, "/recurring?message=Ошибка: Неверная дата окончания")
+			return
+		}
+		// Дата окончания включается целиком
+		endDate = endDay.Add(24*time.Hour - time.Second)
+		if endDate.Before(startDate) {
+			c.Redirect(http.StatusFound, "/recurring?message=Ошибка: Дата окончания раньше даты начала")
+			return
+		}
+	}
+
+	count := 0
+	if countStr := c.PostForm("count"); countStr != "" {
+		count, err = strconv.Atoi(countStr)
+		if err != nil || count < 0 {
+			c.Redirect(http.StatusFound, "/recurring?message=Ошибка: Неверное количество повторений")
+			return
+		}
+	}
+
+	isPositive := c.PostForm("type") == "income"
+	err = h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		accountID, currency, errMsg := resolveAccount(data, c.PostForm("account"), currency)
+		if errMsg != "" {
+			return formError(errMsg)
+		}
+		categoryID, errMsg := parseCategoryID(c.PostForm("category"), data.Categories, isPositive)
+		if errMsg != "" {
+			return formError(errMsg)
+		}
+
+		// ID не переиспользуются: по нему операции связаны с удалёнными шаблонами
+		newID := 1
+		for _, r := range data.Recurring {
+			if r.ID >= newID {
+				newID = r.ID + 1
+			}
+		}
+		for _, t := range data.Transactions {
+			if t.RecurringID >= newID {
+				newID = t.RecurringID + 1
+			}
+		}
+
+		data.Recurring = append(data.Recurring, models.RecurringTransaction{
+			ID:          newID,
+			Amount:      amount,
+			Description: description,
+			IsPositive:  isPositive,
+			Currency:    currency,
+			Notes:       c.PostForm("notes"),
+			CategoryID:  categoryID,
+			AccountID:   accountID,
+			Frequency:   frequency,
+			StartDate:   startDate,
+			EndDate:     endDate,
+			Count:       count,
+		})
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/recurring?message="+errorMessage(err))
+		return
+	}
+
+	// Повторения с датой в прошлом создаются сразу
+	scheduler.RunRecurring(h.financeStore)
+
+	c.Redirect(http.StatusFound, "/recurring?message=Регулярная операция добавлена")
+}
+
+func (h *RecurringHandler) ToggleRecurring(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/recurring?message=Ошибка: Неверный ID")
+		return
+	}
+
+	now := time.Now()
+	message := ""
+	err = h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		for i, r := range data.Recurring {
+			if r.ID != id {
+				continue
+			}
+			if r.Paused {
+				// Повторения, пропущенные во время паузы, не создаются задним числом
+				for !storage.Finished(data.Recurring[i]) && !storage.Occurrence(data.Recurring[i], data.Recurring[i].Generated).After(now) {
+					data.Recurring[i].Generated++
+				}
+				data.Recurring[i].Paused = false
+				message = "Регулярная операция возобновлена"
+			} else {
+				data.Recurring[i].Paused = true
+				message = "Регулярная операция приостановлена"
+			}
+			return nil
+		}
+		return formError("Ошибка: Регулярная операция не найдена")
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/recurring?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/recurring?message="+message)
+}
+
+func (h *RecurringHandler) DeleteRecurring(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/recurring?message=Ошибка: Неверный ID")
+		return
+	}
+
+	// Уже созданные операции остаются в истории
+	err = h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		for i, r := range data.Recurring {
+			if r.ID == id {
+				data.Recurring = append(data.Recurring[:i], data.Recurring[i+1:]...)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/recurring?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/recurring?message=Регулярная операция удалена")
+}
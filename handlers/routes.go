@@ -7,27 +7,128 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(r *gin.Engine, financeStore *storage.FinanceStorage, workLogStore *storage.WorkLogStorage) {
+func RegisterRoutes(r *gin.Engine, financeStore *storage.FinanceStorage, workLogStore *storage.WorkLogStorage, audit *storage.AuditLog) {
 	financeHandler := NewFinanceHandler(financeStore, workLogStore)
 	workLogHandler := NewWorkLogHandler(workLogStore)
 	statsHandler := NewStatsHandler(financeStore)
-	exportHandler := NewExportHandler(workLogStore)
+	categoryHandler := NewCategoryHandler(financeStore)
+	recurringHandler := NewRecurringHandler(financeStore)
+	accountHandler := NewAccountHandler(financeStore)
+	ratesHandler := NewRatesHandler(financeStore)
+	exportHandler := NewExportHandler(financeStore, workLogStore)
+	backupHandler := NewBackupHandler(financeStore, workLogStore)
+	trashHandler := NewTrashHandler(financeStore, workLogStore)
+	auditHandler := NewAuditHandler(audit)
+	importHandler := NewImportHandler(financeStore)
 
 	// Маршруты для финансов
 	r.GET("/", financeHandler.Index)
 	r.POST("/add", financeHandler.AddTransaction)
 	r.POST("/edit/:id", financeHandler.EditTransaction)
 	r.POST("/delete/:id", financeHandler.DeleteTransaction)
+	r.POST("/undo", financeHandler.Undo)
 	r.GET("/api/transactions", financeHandler.GetTransactions)
 
+	// JSON API v1
+	v1 := r.Group("/api/v1")
+	v1.GET("/transactions", financeHandler.APIListTransactions)
+	v1.POST("/transactions", financeHandler.APICreateTransaction)
+	v1.POST("/transactions/bulk", financeHandler.APIBulkTransactions)
+	v1.POST("/transactions/undo", financeHandler.APIUndo)
+	v1.GET("/transactions/:id", financeHandler.APIGetTransaction)
+	v1.GET("/transactions/:id/history", auditHandler.APITransactionHistory)
+	v1.PUT("/transactions/:id", financeHandler.APIUpdateTransaction)
+	v1.PATCH("/transactions/:id", financeHandler.APIUpdateTransaction)
+	v1.DELETE("/transactions/:id", financeHandler.APIDeleteTransaction)
+	v1.GET("/worklog", workLogHandler.APIListWorkEntries)
+	v1.POST("/worklog", workLogHandler.APICreateWorkEntry)
+	v1.DELETE("/worklog", workLogHandler.APIDeleteWorkEntries)
+	v1.POST("/worklog/undo", workLogHandler.APIUndo)
+	v1.GET("/worklog/summary", workLogHandler.APIWorkLogSummary)
+	v1.GET("/worklog/rules", workLogHandler.APIGetWorkRules)
+	v1.PUT("/worklog/rules", workLogHandler.APIUpdateWorkRules)
+	v1.GET("/worklog/:date", workLogHandler.APIGetWorkEntry)
+	v1.GET("/worklog/:date/history", auditHandler.APIWorkEntryHistory)
+	v1.PUT("/worklog/:date", workLogHandler.APIUpdateWorkEntry)
+	v1.PATCH("/worklog/:date", workLogHandler.APIUpdateWorkEntry)
+	v1.DELETE("/worklog/:date", workLogHandler.APIDeleteWorkEntry)
+	v1.GET("/trash", trashHandler.APITrash)
+	v1.DELETE("/trash", trashHandler.APIEmptyTrash)
+	v1.POST("/trash/transactions/:id/restore", trashHandler.APIRestoreTransaction)
+	v1.DELETE("/trash/transactions/:id", trashHandler.APIPurgeTransaction)
+	v1.POST("/trash/worklog/:date/restore", trashHandler.APIRestoreWorkEntry)
+	v1.DELETE("/trash/worklog/:date", trashHandler.APIPurgeWorkEntry)
+	v1.GET("/backups", backupHandler.APIListBackups)
+	v1.POST("/backups", backupHandler.APICreateBackup)
+	v1.GET("/backups/:store/:name/diff", backupHandler.APIBackupDiff)
+	v1.POST("/backups/:store/:name/restore", backupHandler.APIRestoreBackup)
+	v1.GET("/audit", auditHandler.APIAudit)
+
+	// Маршруты для импорта
+	r.GET("/import", importHandler.Import)
+	r.POST("/import/preview", importHandler.Preview)
+	r.POST("/import/commit", importHandler.Commit)
+
+	// Маршруты для категорий
+	r.GET("/categories", categoryHandler.Categories)
+	r.POST("/categories/add", categoryHandler.AddCategory)
+	r.POST("/categories/edit/:id", categoryHandler.EditCategory)
+	r.POST("/categories/delete/:id", categoryHandler.DeleteCategory)
+	r.POST("/budgets/add", categoryHandler.AddBudget)
+	r.POST("/budgets/delete/:id", categoryHandler.DeleteBudget)
+
+	// Маршруты для счетов
+	r.GET("/accounts", accountHandler.Accounts)
+	r.POST("/accounts/add", accountHandler.AddAccount)
+	r.POST("/accounts/edit/:id", accountHandler.EditAccount)
+	r.POST("/transfer", accountHandler.Transfer)
+	r.POST("/exchange", accountHandler.Exchange)
+
+	// Маршруты для курсов валют
+	r.GET("/rates", ratesHandler.Rates)
+	r.POST("/rates/add", ratesHandler.AddRate)
+	r.POST("/rates/import", ratesHandler.ImportRates)
+	r.POST("/rates/delete", ratesHandler.DeleteRate)
+	r.POST("/rates/base", ratesHandler.SetBaseCurrency)
+
+	// Маршруты для регулярных операций
+	r.GET("/recurring", recurringHandler.Recurring)
+	r.POST("/recurring/add", recurringHandler.AddRecurring)
+	r.POST("/recurring/toggle/:id", recurringHandler.ToggleRecurring)
+	r.POST("/recurring/delete/:id", recurringHandler.DeleteRecurring)
+
 	// Маршруты для табеля
 	r.GET("/worklog", workLogHandler.WorkLog)
 	r.POST("/add-work", workLogHandler.AddWork)
+	r.POST("/worklog/fill", workLogHandler.FillWork)
 	r.POST("/edit-work/:date", workLogHandler.EditWork) // Новый маршрут для редактирования
+	r.POST("/delete-work/:date", workLogHandler.DeleteWork)
+	r.POST("/worklog/delete", workLogHandler.DeleteWorkRange)
+	r.POST("/worklog/rules", workLogHandler.SaveWorkRules)
+	r.POST("/undo-work", workLogHandler.UndoWork)
 	r.GET("/worklog/export", exportHandler.ExportWorkLogPDF)
+	r.GET("/export/transactions", exportHandler.ExportTransactions)
+	r.GET("/export/finance", exportHandler.ExportFinancePDF)
 	// Новый маршрут для получения сводки по месяцам
 	r.GET("/worklog/summary", workLogHandler.GetWorkLogSummary)
 
+	// Маршруты для корзины
+	r.GET("/trash", trashHandler.Trash)
+	r.POST("/trash/restore/:id", trashHandler.RestoreTransaction)
+	r.POST("/trash/purge/:id", trashHandler.PurgeTransaction)
+	r.POST("/trash/restore-work/:date", trashHandler.RestoreWork)
+	r.POST("/trash/purge-work/:date", trashHandler.PurgeWork)
+	r.POST("/trash/empty", trashHandler.EmptyTrash)
+
+	// Маршруты для резервных копий
+	r.GET("/backups", backupHandler.Backups)
+	r.POST("/backups/create", backupHandler.CreateBackup)
+	r.POST("/backups/restore", backupHandler.RestoreBackup)
+
+	// Маршруты для журнала изменений
+	r.GET("/activity", auditHandler.Activity)
+	r.GET("/history", auditHandler.History)
+
 	// Маршруты для статистики
 	r.GET("/stats", statsHandler.Stats)
 }
@@ -8,6 +8,7 @@ import (
 	"log"
 	"net/http"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -137,10 +138,39 @@ func (h *StatsHandler) Stats(c *gin.Context) {
 	}
 
 	// Фильтруем транзакции за период
-	data := h.financeStore.GetData()
-	var filteredTrans []models.Transaction
+	data := h.financeStore.Snapshot()
+	// Все суммы пересчитываются в базовую валюту по курсу на дату операции.
+	// Переводы и обмены валюты не являются доходом или расходом (обмены
+	// показываются отдельно), запланированные операции не входят в фактические
+	// итоги и считаются отдельно.
+	conv := storage.NewConverter(data)
+	baseCurrency := conv.Base()
+	originals := make(map[int]models.Transaction)
+	var filteredTrans, exchangeTrans []models.Transaction
+	plannedIncome, plannedExpense := 0.0, 0.0
+	now := time.Now()
 	for _, t := range data.Transactions {
 		if (t.DateTime.Equal(startDate) || t.DateTime.After(startDate)) && (t.DateTime.Before(endDate) || t.DateTime.Equal(endDate)) {
+			if t.IsInternal() {
+				if t.IsExchange() && !t.IsPositive && !t.IsPlanned(now) {
+					exchangeTrans = append(exchangeTrans, t)
+				}
+				continue
+			}
+			amount, ok := conv.Amount(t)
+			if !ok {
+				continue
+			}
+			originals[t.ID] = t
+			t.Amount = amount
+			if t.IsPlanned(now) {
+				if t.IsPositive {
+					plannedIncome += t.Amount
+				} else {
+					plannedExpense += t.Amount
+				}
+				continue
+			}
 			filteredTrans = append(filteredTrans, t)
 		}
 	}
@@ -190,24 +220,40 @@ func (h *StatsHandler) Stats(c *gin.Context) {
 		if i >= 5 {
 			break
 		}
-		topIncomes = append(topIncomes, gin.H{
-			"Amount":      fmt.Sprintf("%.2f", t.Amount),
-			"Description": t.Description,
-			"DateTime":    t.DateTime.Format("02.01.2006"),
-		})
+		topIncomes = append(topIncomes, topTransaction(originals[t.ID], t.Amount, baseCurrency))
 	}
 	for i, t := range expenses {
 		if i >= 5 {
 			break
 		}
-		topExpenses = append(topExpenses, gin.H{
-			"Amount":      fmt.Sprintf("%.2f", t.Amount),
-			"Description": t.Description,
-			"DateTime":    t.DateTime.Format("02.01.2006"),
-		})
+		topExpenses = append(topExpenses, topTransaction(originals[t.ID], t.Amount, baseCurrency))
 	}
 	log.Printf("Top Incomes: %d, Top Expenses: %d", len(topIncomes), len(topExpenses))
 
+	// Разбивка по категориям с динамикой относительно предыдущего периода
+	var prevStartDate time.Time
+	switch period {
+	case "day":
+		prevStartDate = startDate.AddDate(0, 0, -1)
+	case "week":
+		prevStartDate = startDate.AddDate(0, 0, -7)
+	case "month":
+		prevStartDate = startDate.AddDate(0, -1, 0)
+	}
+	var prevTrans []models.Transaction
+	for _, t := range data.Transactions {
+		if !t.DateTime.Before(prevStartDate) && t.DateTime.Before(startDate) && !t.IsPlanned(now) && !t.IsInternal() {
+			amount, ok := conv.Amount(t)
+			if !ok {
+				continue
+			}
+			t.Amount = amount
+			prevTrans = append(prevTrans, t)
+		}
+	}
+	expenseCategories := categoryBreakdown(filteredTrans, prevTrans, data.Categories, false, baseCurrency)
+	incomeCategories := categoryBreakdown(filteredTrans, prevTrans, data.Categories, true, baseCurrency)
+
 	// Данные для графика
 	type ChartData struct {
 		Labels   []string  `json:"labels"`
@@ -273,10 +319,14 @@ func (h *StatsHandler) Stats(c *gin.Context) {
 	}
 	log.Printf("Chart Data: Labels=%v, Incomes=%v, Expenses=%v", chartData.Labels, chartData.Incomes, chartData.Expenses)
 
-	// Инсайты
-	insights := []string{}
-	if totalExpense > totalIncome*0.8 {
-		insights = append(insights, "Вы потратили более 80% доходов за период. Попробуйте сократить мелкие траты.")
+	// Бюджеты месяца, в который попадает период, и инсайты по перерасходу
+	budgetMonth := time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, startDate.Location())
+	budgets := budgetProgress(data, budgetMonth)
+	insights := budgetInsights(budgets, budgetMonth, now)
+	exchanges, exchangeResult := exchangeSummary(exchangeTrans, data.Transactions, conv)
+	if missing := conv.Missing(); len(missing) > 0 {
+		insights = append(insights, fmt.Sprintf("Нет курсов для валют: %s. Операции в них не учтены в итогах — добавьте курсы на странице «Курсы валют».",
+			strings.Join(missing, ", ")))
 	}
 	log.Printf("Insights: %v", insights)
 
@@ -290,16 +340,141 @@ func (h *StatsHandler) Stats(c *gin.Context) {
 
 	// Передаём числовые значения как float64 и ChartData как JSON-строку
 	c.HTML(http.StatusOK, "stats.html", gin.H{
-		"SelectedPeriod":  period,
-		"SelectedDate":    selectedDateForInput,
-		"Period":          periodDisplay,
-		"TotalIncome":     totalIncome,
-		"TotalExpense":    totalExpense,
-		"NetBalance":      netBalance,
-		"AvgDailyExpense": avgDailyExpense,
-		"TopIncomes":      topIncomes,
-		"TopExpenses":     topExpenses,
-		"ChartDataJSON":   string(chartDataJSON),
-		"Insights":        insights,
+		"SelectedPeriod":    period,
+		"SelectedDate":      selectedDateForInput,
+		"Period":            periodDisplay,
+		"TotalIncome":       totalIncome,
+		"TotalExpense":      totalExpense,
+		"NetBalance":        netBalance,
+		"AvgDailyExpense":   avgDailyExpense,
+		"PlannedIncome":     plannedIncome,
+		"PlannedExpense":    plannedExpense,
+		"TopIncomes":        topIncomes,
+		"TopExpenses":       topExpenses,
+		"ExpenseCategories": expenseCategories,
+		"IncomeCategories":  incomeCategories,
+		"Budgets":           budgets,
+		"ChartDataJSON":     string(chartDataJSON),
+		"Insights":          insights,
+		"BaseCurrency":      baseCurrency,
+		"Exchanges":         exchanges,
+		"ExchangeResult":    exchangeResult,
+	})
+}
+
+// categoryBreakdown группирует доходы или расходы по категориям: сумма, доля от
+// общего итога и изменение относительно предыдущего периода в процентах
+func categoryBreakdown(current, previous []models.Transaction, categories []models.Category, income bool, currency string) []gin.H {
+	catMap := categoryMap(categories)
+
+	totals := make(map[int]float64)
+	counts := make(map[int]int)
+	total := 0.0
+	for _, t := range current {
+		if t.IsPositive != income {
+			continue
+		}
+		totals[t.CategoryID] += t.Amount
+		counts[t.CategoryID]++
+		total += t.Amount
+	}
+
+	prevTotals := make(map[int]float64)
+	for _, t := range previous {
+		if t.IsPositive == income {
+			prevTotals[t.CategoryID] += t.Amount
+		}
+	}
+
+	result := []gin.H{}
+	for id, sum := range totals {
+		name, icon := "Без категории", "❔"
+		if cat, ok := catMap[id]; ok {
+			name, icon = cat.Name, cat.Icon
+		}
+		share := 0.0
+		if total > 0 {
+			share = sum / total * 100
+		}
+		prev := prevTotals[id]
+		trend := 0.0
+		if prev > 0 {
+			trend = (sum - prev) / prev * 100
+		}
+		result = append(result, gin.H{
+			"ID":        id,
+			"Name":      name,
+			"Icon":      icon,
+			"Total":     sum,
+			"Count":     counts[id],
+			"Share":     share,
+			"PrevTotal": prev,
+			"Trend":     trend,
+			"HasTrend":  prev > 0,
+			"Currency":  currency,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i]["Total"].(float64) > result[j]["Total"].(float64)
 	})
+	return result
+}
+
+// topTransaction форматирует операцию для списка топ-5: исходная сумма в
+// валюте операции и, если валюта другая, сумма в базовой валюте
+func topTransaction(t models.Transaction, baseAmount float64, baseCurrency string) gin.H {
+	converted := ""
+	if t.Currency != baseCurrency {
+		converted = fmt.Sprintf("%.2f %s", baseAmount, baseCurrency)
+	}
+	return gin.H{
+		"Amount":      fmt.Sprintf("%.2f", t.Amount),
+		"Currency":    t.Currency,
+		"Converted":   converted,
+		"Description": t.Description,
+		"DateTime":    t.DateTime.Format("02.01.2006"),
+	}
+}
+
+// exchangeSummary описывает обмены валюты за период. Результат обмена — разница
+// между стоимостью полученной и отданной суммы по официальным курсам на дату
+// обмена в базовой валюте: положительная означает выгодный обмен.
+func exchangeSummary(sources, all []models.Transaction, conv *storage.Converter) ([]gin.H, float64) {
+	byID := make(map[int]models.Transaction, len(all))
+	for _, t := range all {
+		byID[t.ID] = t
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].DateTime.Before(sources[j].DateTime)
+	})
+
+	items := []gin.H{}
+	total := 0.0
+	for _, src := range sources {
+		dst, ok := byID[src.LinkedID]
+		if !ok {
+			continue
+		}
+		item := gin.H{
+			"DateTime":       src.DateTime.Format("02.01.2006"),
+			"Description":    src.Description,
+			"SourceAmount":   fmt.Sprintf("%.2f", src.Amount),
+			"SourceCurrency": src.Currency,
+			"TargetAmount":   fmt.Sprintf("%.2f", dst.Amount),
+			"TargetCurrency": dst.Currency,
+			"Rate":           formatExchangeRate(src.Amount, src.Currency, dst.Amount, dst.Currency),
+			"HasResult":      false,
+			"Result":         0.0,
+		}
+		given, okGiven := conv.Amount(src)
+		received, okReceived := conv.Amount(dst)
+		if okGiven && okReceived {
+			item["HasResult"] = true
+			item["Result"] = received - given
+			total += received - given
+		}
+		items = append(items, item)
+	}
+	return items, total
 }
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"errors"
+	"finance-tracker/models"
+	"finance-tracker/storage"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrashHandler struct {
+	financeStore *storage.FinanceStorage
+	workLogStore *storage.WorkLogStorage
+}
+
+func NewTrashHandler(financeStore *storage.FinanceStorage, workLogStore *storage.WorkLogStorage) *TrashHandler {
+	return &TrashHandler{financeStore: financeStore, workLogStore: workLogStore}
+}
+
+// undoKinds — подписи отменяемых действий
+var undoKinds = map[string]string{
+	storage.UndoAdd:    "добавление",
+	storage.UndoEdit:   "изменение",
+	storage.UndoDelete: "удаление",
+	storage.UndoBulk:   "пакетное изменение",
+}
+
+// undoLabel возвращает подпись кнопки отмены или пустую строку, если
+// отменять нечего
+func undoLabel(action storage.UndoAction, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("Отменить %s (%s)", undoKinds[action.Kind], action.At.Format("15:04"))
+}
+
+// undoErrorMessage возвращает текст уведомления об ошибке отмены
+func undoErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrNothingToUndo):
+		return "Ошибка: Нет действий для отмены"
+	case errors.Is(err, storage.ErrUndoConflict):
+		return "Ошибка: Действие нельзя отменить, записи изменены позже"
+	}
+	return "Ошибка при сохранении данных"
+}
+
+// apiUndoFailed отвечает на ошибку отмены действия
+func apiUndoFailed(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNothingToUndo):
+		apiAbort(c, http.StatusConflict, "nothing_to_undo", "Нет действий для отмены")
+	case errors.Is(err, storage.ErrUndoConflict):
+		apiAbort(c, http.StatusConflict, "undo_conflict", "Действие нельзя отменить, записи изменены позже")
+	default:
+		apiAbort(c, http.StatusInternalServerError, "save_failed", "Ошибка при сохранении данных")
+	}
+}
+
+// apiUndoAction — представление отменённого действия в API
+type apiUndoAction struct {
+	Kind    string    `json:"kind"` // "add", "edit", "delete" или "bulk"
+	At      time.Time `json:"at"`
+	Records int       `json:"records"`
+}
+
+func toAPIUndoAction(action storage.UndoAction) apiUndoAction {
+	return apiUndoAction{Kind: action.Kind, At: action.At, Records: action.Records}
+}
+
+// Trash показывает удалённые операции и записи табеля, новые сверху
+func (h *TrashHandler) Trash(c *gin.Context) {
+	finance := h.financeStore.Snapshot()
+	workLog := h.workLogStore.Snapshot()
+
+	sort.Slice(finance.Trash, func(i, j int) bool { return finance.Trash[i].DeletedAt.After(finance.Trash[j].DeletedAt) })
+	sort.Slice(workLog.Trash, func(i, j int) bool { return workLog.Trash[i].DeletedAt.After(workLog.Trash[j].DeletedAt) })
+
+	categories := categoryMap(finance.Categories)
+	accounts := accountMap(finance.Accounts)
+	transactions := []gin.H{}
+	for _, d := range finance.Trash {
+		category := categories[d.CategoryID]
+		transactions = append(transactions, gin.H{
+			"ID":           d.ID,
+			"Amount":       fmt.Sprintf("%.2f", d.Amount),
+			"Description":  d.Description,
+			"DateTime":     d.DateTime.Format("02.01.2006 15:04"),
+			"IsPositive":   d.IsPositive,
+			"Currency":     d.Currency,
+			"Category":     category.Name,
+			"CategoryIcon": category.Icon,
+			"Account":      accounts[d.AccountID].Name,
+			"IsInternal":   d.IsInternal(),
+			"DeletedAt":    d.DeletedAt.Format("02.01.2006 15:04"),
+		})
+	}
+	entries := []gin.H{}
+	for _, d := range workLog.Trash {
+		entries = append(entries, gin.H{
+			"Date":      d.Date,
+			"Place":     d.Place,
+			"StartTime": d.StartTime,
+			"EndTime":   d.EndTime,
+			"IsDayOff":  d.IsDayOff,
+			"DeletedAt": d.DeletedAt.Format("02.01.2006 15:04"),
+		})
+	}
+
+	c.HTML(http.StatusOK, "trash.html", gin.H{
+		"Transactions": transactions,
+		"Entries":      entries,
+	})
+}
+
+func (h *TrashHandler) RestoreTransaction(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/trash?message=Ошибка: Неверный ID транзакции")
+		return
+	}
+
+	err = h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		if len(storage.RestoreTransactions(data, []int{id})) == 0 {
+			return storage.ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/trash?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/trash?message=Транзакция восстановлена")
+}
+
+func (h *TrashHandler) PurgeTransaction(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/trash?message=Ошибка: Неверный ID транзакции")
+		return
+	}
+
+	err = h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		if storage.PurgeTransactions(data, []int{id}) == 0 {
+			return storage.ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/trash?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/trash?message=Транзакция удалена окончательно")
+}
+
+func (h *TrashHandler) RestoreWork(c *gin.Context) {
+	date := c.Param("date")
+
+	err := h.workLogStore.Update(requestActor(c), func(data *models.WorkLogData) error {
+		return storage.RestoreWorkEntry(data, date)
+	})
+	if errors.Is(err, storage.ErrExists) {
+		c.Redirect(http.StatusFound, "/trash?message=Ошибка: Запись за "+date+" уже существует")
+		return
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, "/trash?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/trash?message=Запись о работе восстановлена")
+}
+
+func (h *TrashHandler) PurgeWork(c *gin.Context) {
+	date := c.Param("date")
+
+	err := h.workLogStore.Update(requestActor(c), func(data *models.WorkLogData) error {
+		if storage.PurgeWorkEntries(data, []string{date}) == 0 {
+			return storage.ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		c.Redirect(http.StatusFound, "/trash?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/trash?message=Запись о работе удалена окончательно")
+}
+
+// EmptyTrash окончательно удаляет всё содержимое обеих корзин
+func (h *TrashHandler) EmptyTrash(c *gin.Context) {
+	err := h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		storage.PurgeTransactions(data, nil)
+		return nil
+	})
+	if err == nil {
+		err = h.workLogStore.Update(requestActor(c), func(data *models.WorkLogData) error {
+			storage.PurgeWorkEntries(data, nil)
+			return nil
+		})
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, "/trash?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/trash?message=Корзина очищена")
+}
+
+// apiDeletedTransaction — операция в корзине
+type apiDeletedTransaction struct {
+	apiTransaction
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// apiDeletedWorkEntry — запись табеля в корзине
+type apiDeletedWorkEntry struct {
+	apiWorkEntry
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// APITrash возвращает содержимое обеих корзин
+func (h *TrashHandler) APITrash(c *gin.Context) {
+	finance := h.financeStore.Snapshot()
+	workLog := h.workLogStore.Snapshot()
+
+	now := time.Now()
+	transactions := make([]apiDeletedTransaction, 0, len(finance.Trash))
+	for _, d := range finance.Trash {
+		transactions = append(transactions, apiDeletedTransaction{toAPITransaction(d.Transaction, now), d.DeletedAt})
+	}
+	workTime := h.workLogStore.WorkTime()
+	entries := make([]apiDeletedWorkEntry, 0, len(workLog.Trash))
+	for _, d := range workLog.Trash {
+		entries = append(entries, apiDeletedWorkEntry{toAPIWorkEntry(d.WorkEntry, workTime), d.DeletedAt})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transactions": transactions,
+		"worklog":      entries,
+	})
+}
+
+// APIRestoreTransaction возвращает операцию из корзины вместе с парной
+// операцией перевода или обмена
+func (h *TrashHandler) APIRestoreTransaction(c *gin.Context) {
+	id, ok := apiTransactionID(c)
+	if !ok {
+		return
+	}
+
+	var restored []int
+	err := h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		restored = storage.RestoreTransactions(data, []int{id})
+		if len(restored) == 0 {
+			return storage.ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		apiStoreFailed(c, err, "Операция не найдена в корзине")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"restored": restored})
+}
+
+// APIPurgeTransaction окончательно удаляет операцию из корзины
+func (h *TrashHandler) APIPurgeTransaction(c *gin.Context) {
+	id, ok := apiTransactionID(c)
+	if !ok {
+		return
+	}
+
+	err := h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		if storage.PurgeTransactions(data, []int{id}) == 0 {
+			return storage.ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		apiStoreFailed(c, err, "Операция не найдена в корзине")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TrashHandler) APIRestoreWorkEntry(c *gin.Context) {
+	date, ok := apiWorkEntryDate(c)
+	if !ok {
+		return
+	}
+
+	err := h.workLogStore.Update(requestActor(c), func(data *models.WorkLogData) error {
+		return storage.RestoreWorkEntry(data, date)
+	})
+	if errors.Is(err, storage.ErrExists) {
+		apiAbort(c, http.StatusConflict, "already_exists", fmt.Sprintf("Запись за %s уже существует", date))
+		return
+	}
+	if err != nil {
+		apiStoreFailed(c, err, "Запись не найдена в корзине")
+		return
+	}
+
+	entry := h.workLogStore.QueryEntries(func(entry models.WorkEntry) bool { return entry.Date == date })
+	c.JSON(http.StatusOK, toAPIWorkEntry(entry[0], h.workLogStore.WorkTime()))
+}
+
+func (h *TrashHandler) APIPurgeWorkEntry(c *gin.Context) {
+	date, ok := apiWorkEntryDate(c)
+	if !ok {
+		return
+	}
+
+	err := h.workLogStore.Update(requestActor(c), func(data *models.WorkLogData) error {
+		if storage.PurgeWorkEntries(data, []string{date}) == 0 {
+			return storage.ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		apiStoreFailed(c, err, "Запись не найдена в корзине")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// APIEmptyTrash окончательно удаляет всё содержимое обеих корзин
+func (h *TrashHandler) APIEmptyTrash(c *gin.Context) {
+	err := h.financeStore.Update(requestActor(c), func(data *models.FinanceData) error {
+		storage.PurgeTransactions(data, nil)
+		return nil
+	})
+	if err == nil {
+		err = h.workLogStore.Update(requestActor(c), func(data *models.WorkLogData) error {
+			storage.PurgeWorkEntries(data, nil)
+			return nil
+		})
+	}
+	if err != nil {
+		apiStoreFailed(c, err, "")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
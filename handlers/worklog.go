@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"finance-tracker/models"
 	"finance-tracker/storage"
 	"fmt"
 	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -19,16 +23,10 @@ func NewWorkLogHandler(workLogStore *storage.WorkLogStorage) *WorkLogHandler {
 	return &WorkLogHandler{workLogStore: workLogStore}
 }
 
-// WorkLogSummary представляет данные о работе за месяц
-type WorkLogSummary struct {
-	WorkDays          int     `json:"work_days"`
-	TotalHours        float64 `json:"total_hours"`
-	OvertimeHours     float64 `json:"overtime_hours"`
-	TotalWithOvertime float64 `json:"total_with_overtime"`
-}
-
 func (h *WorkLogHandler) WorkLog(c *gin.Context) {
-	data := h.workLogStore.GetData()
+	data := h.workLogStore.Snapshot()
+	workTime := h.workLogStore.WorkTime()
+	days := workDaysByDate(workTime.Days(data.Entries))
 	formattedEntries := []gin.H{}
 	for _, entry := range data.Entries {
 		date, _ := time.Parse("2006-01-02", entry.Date)
@@ -57,19 +55,36 @@ func (h *WorkLogHandler) WorkLog(c *gin.Context) {
 		}[date.Month().String()])
 
 		var hoursWorked string
+		var extras, breakTexts []string
+		sessions := []gin.H{}
+		breaks := []gin.H{}
 		if !entry.IsDayOff {
-			start, _ := time.Parse("15:04", entry.StartTime)
-			end, _ := time.Parse("15:04", entry.EndTime)
-			duration := end.Sub(start).Hours()
-			if duration < 0 {
-				duration += 24
+			for _, session := range entry.WorkSessions() {
+				sessions = append(sessions, gin.H{
+					"Place":     session.Place,
+					"StartTime": session.StartTime,
+					"EndTime":   session.EndTime,
+					"Hours":     fmt.Sprintf("%.1f ч", session.Hours()),
+				})
+			}
+			for _, b := range entry.Breaks {
+				breaks = append(breaks, gin.H{
+					"StartTime": b.StartTime,
+					"EndTime":   b.EndTime,
+					"Minutes":   b.Minutes,
+					"HasTime":   b.HasTime(),
+				})
+				breakTexts = append(breakTexts, workBreakText(b))
 			}
-			if duration > 7 {
-				// Время обеда
-				hoursWorked = fmt.Sprintf("%.1f часов - 1", duration)
-			} else {
-				hoursWorked = fmt.Sprintf("%.1f часов", duration)
+			day := days[entry.Date]
+			hoursWorked = fmt.Sprintf("%.1f ч", day.Hours)
+			switch {
+			case day.BreaksRecorded:
+				hoursWorked += fmt.Sprintf(" (перерывы %.0f мин)", day.Lunch*60)
+			case day.Lunch > 0:
+				hoursWorked += fmt.Sprintf(" (обед %.0f мин)", day.Lunch*60)
 			}
+			extras = workDayExtras(day)
 		}
 
 		formattedEntries = append(formattedEntries, gin.H{
@@ -79,7 +94,11 @@ func (h *WorkLogHandler) WorkLog(c *gin.Context) {
 			"StartTime":     entry.StartTime,
 			"EndTime":       entry.EndTime,
 			"IsDayOff":      entry.IsDayOff,
+			"Sessions":      sessions,
+			"Breaks":        breaks,
+			"BreaksText":    strings.Join(breakTexts, ", "),
 			"HoursWorked":   hoursWorked,
+			"Extras":        strings.Join(extras, ", "),
 		})
 	}
 
@@ -88,10 +107,90 @@ func (h *WorkLogHandler) WorkLog(c *gin.Context) {
 	})
 
 	c.HTML(http.StatusOK, "worklog.html", gin.H{
-		"entries": formattedEntries,
+		"entries":       formattedEntries,
+		"today":         time.Now().Format("2006-01-02"),
+		"undoLabel":     undoLabel(h.workLogStore.LastAction()),
+		"deletePreview": workLogDeletePreview(c, formattedEntries),
+		"rules":         workRulesView(workTime.Rules()),
 	})
 }
 
+// workBreakText описывает перерыв: «12:00–12:45 (45 мин)» или «15 мин»
+func workBreakText(b models.WorkBreak) string {
+	if b.HasTime() {
+		return fmt.Sprintf("%s–%s (%d мин)", b.StartTime, b.EndTime, b.Minutes)
+	}
+	return fmt.Sprintf("%d мин", b.Minutes)
+}
+
+// workDaysByDate раскладывает рассчитанные дни по датам
+func workDaysByDate(days []storage.WorkDay) map[string]storage.WorkDay {
+	result := make(map[string]storage.WorkDay, len(days))
+	for _, day := range days {
+		result[day.Date] = day
+	}
+	return result
+}
+
+// workDayExtras перечисляет часы дня, оплачиваемые по особым правилам
+func workDayExtras(day storage.WorkDay) []string {
+	extras := []string{}
+	if day.Overtime > 0 {
+		extras = append(extras, fmt.Sprintf("переработка %.1f ч", day.Overtime))
+	}
+	if day.WeeklyOvertime > 0 {
+		extras = append(extras, fmt.Sprintf("сверх недельной нормы %.1f ч", day.WeeklyOvertime))
+	}
+	if day.Night > 0 {
+		extras = append(extras, fmt.Sprintf("ночные %.1f ч", day.Night))
+	}
+	if day.Weekend > 0 {
+		extras = append(extras, fmt.Sprintf("в выходной %.1f ч", day.Weekend))
+	}
+	return extras
+}
+
+// workPeriodSummary считает итоги по дням, дата которых начинается с period
+// (YYYY-MM или YYYY). Дни рассчитываются по всем записям, чтобы недельная
+// норма учитывала недели на границе периода.
+func workPeriodSummary(workTime storage.WorkTime, days []storage.WorkDay, period string) storage.WorkTimeSummary {
+	var selected []storage.WorkDay
+	for _, day := range days {
+		if strings.HasPrefix(day.Date, period) {
+			selected = append(selected, day)
+		}
+	}
+	return workTime.Summarize(selected)
+}
+
+// workLogDeletePreview составляет подтверждение удаления записей за период
+// из параметров delete_from и delete_to. Возвращает nil, если период не
+// запрошен.
+func workLogDeletePreview(c *gin.Context, entries []gin.H) gin.H {
+	from, to := c.Query("delete_from"), c.Query("delete_to")
+	if from == "" && to == "" {
+		return nil
+	}
+	if _, ok := parseAPIDate(from); !ok {
+		return gin.H{"Error": "Укажите дату начала периода"}
+	}
+	if _, ok := parseAPIDate(to); !ok {
+		return gin.H{"Error": "Укажите дату окончания периода"}
+	}
+	if to < from {
+		return gin.H{"Error": "Дата окончания раньше даты начала"}
+	}
+
+	// Записи уже отсортированы от новых к старым
+	dates := []string{}
+	for _, entry := range entries {
+		if date := entry["Date"].(string); date >= from && date <= to {
+			dates = append(dates, entry["FormattedDate"].(string))
+		}
+	}
+	return gin.H{"From": from, "To": to, "Dates": dates}
+}
+
 func (h *WorkLogHandler) GetWorkLogSummary(c *gin.Context) {
 	month := c.Query("month") // Формат: "YYYY-MM"
 	if month == "" {
@@ -105,146 +204,331 @@ func (h *WorkLogHandler) GetWorkLogSummary(c *gin.Context) {
 		return
 	}
 
-	data := h.workLogStore.GetData()
-	var totalHours, overtimeHours float64
-	var workDays int
+	workTime := h.workLogStore.WorkTime()
+	days := workTime.Days(h.workLogStore.QueryEntries(nil))
+	c.JSON(http.StatusOK, workPeriodSummary(workTime, days, monthTime.Format("2006-01")))
+}
 
-	for _, entry := range data.Entries {
-		entryDate, _ := time.Parse("2006-01-02", entry.Date)
-		if entryDate.Year() == monthTime.Year() && entryDate.Month() == monthTime.Month() && !entry.IsDayOff {
-			workDays++
-			start, _ := time.Parse("15:04", entry.StartTime)
-			end, _ := time.Parse("15:04", entry.EndTime)
-			duration := end.Sub(start).Hours()
-			if duration < 0 {
-				duration += 24
-			}
-			// Учитываем обед, если работа больше 7 часов
-			if duration > 7 {
-				duration -= 1 // Вычитаем час на обед
-			}
-			if duration > 8 {
-				overtimeHours += duration - 8
-			}
-			totalHours += duration
+// workLogFillDays — наибольшая длина периода для заполнения табеля
+const workLogFillDays = 366
+
+// workLogReturnPath возвращает страницу, на которую вернуть пользователя после
+// добавления записи: форма есть и на главной странице, и в табеле
+func workLogReturnPath(c *gin.Context) string {
+	if c.PostForm("return") == "worklog" {
+		return "/worklog"
+	}
+	return "/"
+}
+
+// workEntryForm читает из формы отрезки работы и перерывы: поля place,
+// start_time и end_time повторяются для каждого отрезка, break_start,
+// break_end и break_minutes — для каждого перерыва, полностью пустые строки
+// пропускаются. Выходной день хранится без места и со стандартным временем.
+// Вторым значением возвращается текст ошибки.
+func workEntryForm(c *gin.Context, isDayOff bool) (models.WorkEntry, string) {
+	if isDayOff {
+		return models.WorkEntry{StartTime: "08:00", EndTime: "17:00", IsDayOff: true}, ""
+	}
+
+	places := c.PostFormArray("place")
+	starts := c.PostFormArray("start_time")
+	ends := c.PostFormArray("end_time")
+	field := func(values []string, i int) string {
+		if i < len(values) {
+			return strings.TrimSpace(values[i])
+		}
+		return ""
+	}
+
+	sessions := []models.WorkSession{}
+	for i := 0; i < len(places) || i < len(starts) || i < len(ends); i++ {
+		session := models.WorkSession{Place: field(places, i), StartTime: field(starts, i), EndTime: field(ends, i)}
+		if session == (models.WorkSession{}) {
+			continue
+		}
+		if session.Place == "" {
+			return models.WorkEntry{}, "Укажите место работы"
+		}
+		if session.StartTime == "" || session.EndTime == "" {
+			return models.WorkEntry{}, "Укажите время работы"
 		}
+		if _, err := time.Parse("15:04", session.StartTime); err != nil {
+			return models.WorkEntry{}, "Неверный формат времени начала"
+		}
+		if _, err := time.Parse("15:04", session.EndTime); err != nil {
+			return models.WorkEntry{}, "Неверный формат времени окончания"
+		}
+		sessions = append(sessions, session)
+	}
+	if len(sessions) == 0 {
+		return models.WorkEntry{}, "Укажите место работы"
 	}
 
-	summary := WorkLogSummary{
-		WorkDays:          workDays,
-		TotalHours:        totalHours,
-		OvertimeHours:     overtimeHours,
-		TotalWithOvertime: totalHours + overtimeHours,
+	breakStarts := c.PostFormArray("break_start")
+	breakEnds := c.PostFormArray("break_end")
+	breakMinutes := c.PostFormArray("break_minutes")
+	breaks := []models.WorkBreak{}
+	for i := 0; i < len(breakStarts) || i < len(breakEnds) || i < len(breakMinutes); i++ {
+		b, errMsg := parseWorkBreak(field(breakStarts, i), field(breakEnds, i), field(breakMinutes, i))
+		if errMsg != "" {
+			return models.WorkEntry{}, errMsg
+		}
+		if b != (models.WorkBreak{}) {
+			breaks = append(breaks, b)
+		}
 	}
 
-	c.JSON(http.StatusOK, summary)
+	entry := models.WorkEntry{}
+	entry.SetSessions(sessions)
+	if len(breaks) > 0 {
+		entry.Breaks = breaks
+	}
+	if err := storage.CheckWorkEntry(entry); err != nil {
+		return models.WorkEntry{}, workEntryMessage(err)
+	}
+	return entry, ""
 }
 
+// parseWorkBreak разбирает перерыв: время начала и окончания или только
+// длительность в минутах. Для пустой строки возвращается пустой перерыв.
+func parseWorkBreak(start, end, minutes string) (models.WorkBreak, string) {
+	if start == "" && end == "" {
+		if minutes == "" {
+			return models.WorkBreak{}, ""
+		}
+		value, err := strconv.Atoi(minutes)
+		if err != nil || value <= 0 {
+			return models.WorkBreak{}, "Длительность перерыва должна быть целым числом минут больше нуля"
+		}
+		return models.WorkBreak{Minutes: value}, ""
+	}
+	if start == "" || end == "" {
+		return models.WorkBreak{}, "Укажите начало и окончание перерыва или только его длительность"
+	}
+	if _, err := time.Parse("15:04", start); err != nil {
+		return models.WorkBreak{}, "Неверный формат времени начала перерыва"
+	}
+	if _, err := time.Parse("15:04", end); err != nil {
+		return models.WorkBreak{}, "Неверный формат времени окончания перерыва"
+	}
+	breakStart, breakEnd := models.WorkSession{StartTime: start, EndTime: end}.Minutes()
+	return models.WorkBreak{StartTime: start, EndTime: end, Minutes: breakEnd - breakStart}, ""
+}
+
+// workEntryMessage возвращает текст ошибки о пересечении отрезков работы или
+// о перерыве вне времени работы
+func workEntryMessage(err error) string {
+	return capitalize(err.Error())
+}
+
+// AddWork добавляет запись за указанную дату, по умолчанию — за сегодня.
+// Задним числом можно добавить любую запись, на будущие даты — только
+// выходной. Если за день уже есть работа, отрезки из формы дописываются к
+// ней, если не пересекаются с записанными.
 func (h *WorkLogHandler) AddWork(c *gin.Context) {
-	place := c.PostForm("place")
-	startTime := c.PostForm("start_time")
-	endTime := c.PostForm("end_time")
-	isDayOff := c.PostForm("is_day_off") == "true"
+	back := workLogReturnPath(c)
+	isDayOff := c.PostForm("is_day_off") == "true" || c.PostForm("is_day_off") == "on"
 
 	today := time.Now().Format("2006-01-02")
+	date := c.PostForm("date")
+	if date == "" {
+		date = today
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		c.Redirect(http.StatusFound, back+"?message=Ошибка: Неверный формат даты")
+		return
+	}
+	if date > today && !isDayOff {
+		c.Redirect(http.StatusFound, back+"?message=Ошибка: На будущие даты можно отметить только выходной")
+		return
+	}
 
-	data := h.workLogStore.GetData()
-	for _, entry := range data.Entries {
-		if entry.Date == today {
-			c.Redirect(http.StatusFound, "/?message=Запись за сегодня уже существует")
-			return
-		}
+	newEntry, errMsg := workEntryForm(c, isDayOff)
+	if errMsg != "" {
+		c.Redirect(http.StatusFound, back+"?message="+url.QueryEscape("Ошибка: "+errMsg))
+		return
 	}
+	newEntry.Date = date
 
-	if !isDayOff {
-		if place == "" {
-			c.Redirect(http.StatusFound, "/?message=Ошибка: Укажите место работы")
-			return
-		}
-		if startTime == "" || endTime == "" {
-			c.Redirect(http.StatusFound, "/?message=Ошибка: Укажите время работы")
-			return
-		}
-		_, err := time.Parse("15:04", startTime)
-		if err != nil {
-			c.Redirect(http.StatusFound, "/?message=Ошибка: Неверный формат времени начала")
-			return
+	var err error
+	if isDayOff {
+		err = h.workLogStore.AddEntry(requestActor(c), newEntry)
+	} else {
+		_, err = h.workLogStore.AddSessions(requestActor(c), date, newEntry.WorkSessions(), newEntry.Breaks)
+	}
+	if err != nil {
+		var message string
+		switch {
+		case errors.Is(err, storage.ErrOverlap), errors.Is(err, storage.ErrInvalidBreak):
+			message = "Ошибка: " + workEntryMessage(err)
+		case errors.Is(err, storage.ErrExists) && date == today:
+			message = "Запись за сегодня уже существует"
+		case errors.Is(err, storage.ErrExists):
+			message = fmt.Sprintf("Ошибка: Запись за %s уже существует, измените её в табеле", date)
+		default:
+			message = "Ошибка при сохранении данных"
 		}
-		_, err = time.Parse("15:04", endTime)
-		if err != nil {
-			c.Redirect(http.StatusFound, "/?message=Ошибка: Неверный формат времени окончания")
-			return
+		c.Redirect(http.StatusFound, back+"?message="+url.QueryEscape(message))
+		return
+	}
+
+	c.Redirect(http.StatusFound, back+"?message=Запись о работе добавлена")
+}
+
+// FillWork заполняет табель за период одинаковыми записями: выходными
+// (отпуск) или сменами по расписанию. Можно выбрать дни недели; даты, за
+// которые записи уже есть, пропускаются или заменяются по флажку replace.
+// Всё заполнение отменяется одним действием.
+func (h *WorkLogHandler) FillWork(c *gin.Context) {
+	fail := func(message string) {
+		c.Redirect(http.StatusFound, "/worklog?message="+url.QueryEscape("Ошибка: "+message))
+	}
+
+	from, errFrom := time.Parse("2006-01-02", c.PostForm("from"))
+	to, errTo := time.Parse("2006-01-02", c.PostForm("to"))
+	if errFrom != nil || errTo != nil {
+		fail("Укажите даты начала и окончания периода")
+		return
+	}
+	if to.Before(from) {
+		fail("Дата окончания раньше даты начала")
+		return
+	}
+	if days := int(to.Sub(from).Hours()/24) + 1; days > workLogFillDays {
+		fail(fmt.Sprintf("Период не может быть длиннее %d дней", workLogFillDays))
+		return
+	}
+
+	isDayOff := c.PostForm("is_day_off") == "on"
+	schedule, errMsg := workEntryForm(c, isDayOff)
+	if errMsg != "" {
+		fail(errMsg)
+		return
+	}
+	today := time.Now().Format("2006-01-02")
+	if !isDayOff && to.Format("2006-01-02") > today {
+		fail("На будущие даты можно отметить только выходные")
+		return
+	}
+
+	// Дни недели приходят числами 0–6, как time.Weekday; без выбора — все дни
+	weekdays := map[time.Weekday]bool{}
+	for _, value := range c.PostFormArray("weekday") {
+		if day, err := strconv.Atoi(value); err == nil && day >= 0 && day <= 6 {
+			weekdays[time.Weekday(day)] = true
 		}
-	} else {
-		place = ""
-		startTime = "08:00"
-		endTime = "17:00"
 	}
 
-	newEntry := models.WorkEntry{
-		Date:      today,
-		Place:     place,
-		StartTime: startTime,
-		EndTime:   endTime,
-		IsDayOff:  isDayOff,
+	entries := []models.WorkEntry{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if len(weekdays) > 0 && !weekdays[day.Weekday()] {
+			continue
+		}
+		entry := schedule
+		entry.Date = day.Format("2006-01-02")
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		fail("В периоде нет выбранных дней недели")
+		return
 	}
-	data.Entries = append(data.Entries, newEntry)
 
-	if err := h.workLogStore.Save(); err != nil {
-		c.Redirect(http.StatusFound, "/?message=Ошибка при сохранении данных")
+	skipped, err := h.workLogStore.AddEntries(requestActor(c), entries, c.PostForm("replace") == "on")
+	if err != nil {
+		if errors.Is(err, storage.ErrExists) {
+			fail("За все выбранные дни записи уже есть")
+			return
+		}
+		c.Redirect(http.StatusFound, "/worklog?message=Ошибка при сохранении данных")
 		return
 	}
 
-	c.Redirect(http.StatusFound, "/?message=Запись о работе добавлена")
+	message := fmt.Sprintf("Заполнено дней: %d", len(entries)-len(skipped))
+	if len(skipped) > 0 {
+		message += fmt.Sprintf(", пропущено (запись уже есть): %d", len(skipped))
+	}
+	c.Redirect(http.StatusFound, "/worklog?message="+url.QueryEscape(message))
 }
 
+// EditWork заменяет отрезки работы за день отрезками из формы
 func (h *WorkLogHandler) EditWork(c *gin.Context) {
 	date := c.Param("date")
-	place := c.PostForm("place")
-	startTime := c.PostForm("start_time")
-	endTime := c.PostForm("end_time")
 	isDayOff := c.PostForm("is_day_off") == "on"
 
-	data := h.workLogStore.GetData()
-	for i, entry := range data.Entries {
-		if entry.Date == date {
-			if !isDayOff {
-				if place == "" {
-					c.Redirect(http.StatusFound, "/worklog?message=Ошибка: Укажите место работы")
-					return
-				}
-				if startTime == "" || endTime == "" {
-					c.Redirect(http.StatusFound, "/worklog?message=Ошибка: Укажите время работы")
-					return
-				}
-				_, err := time.Parse("15:04", startTime)
-				if err != nil {
-					c.Redirect(http.StatusFound, "/worklog?message=Ошибка: Неверный формат времени начала")
-					return
-				}
-				_, err = time.Parse("15:04", endTime)
-				if err != nil {
-					c.Redirect(http.StatusFound, "/worklog?message=Ошибка: Неверный формат времени окончания")
-					return
-				}
-			} else {
-				place = ""
-				startTime = "08:00"
-				endTime = "17:00"
-			}
+	// Запланированный выходной нельзя превратить в рабочий день с часами
+	if date > time.Now().Format("2006-01-02") && !isDayOff {
+		c.Redirect(http.StatusFound, "/worklog?message=Ошибка: На будущие даты можно отметить только выходной")
+		return
+	}
 
-			data.Entries[i].Place = place
-			data.Entries[i].StartTime = startTime
-			data.Entries[i].EndTime = endTime
-			data.Entries[i].IsDayOff = isDayOff
-			break
-		}
+	edited, errMsg := workEntryForm(c, isDayOff)
+	if errMsg != "" {
+		c.Redirect(http.StatusFound, "/worklog?message="+url.QueryEscape("Ошибка: "+errMsg))
+		return
 	}
 
-	if err := h.workLogStore.Save(); err != nil {
+	_, err := h.workLogStore.UpdateEntry(requestActor(c), date, func(entry *models.WorkEntry) error {
+		*entry = edited
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.Redirect(http.StatusFound, "/worklog?message=Ошибка: Запись не найдена")
+			return
+		}
 		c.Redirect(http.StatusFound, "/worklog?message=Ошибка при сохранении данных")
 		return
 	}
 
 	c.Redirect(http.StatusFound, "/worklog?message=Запись о работе обновлена")
 }
+
+// DeleteWork переносит запись о работе в корзину
+func (h *WorkLogHandler) DeleteWork(c *gin.Context) {
+	if err := h.workLogStore.DeleteEntry(requestActor(c), c.Param("date")); err != nil {
+		c.Redirect(http.StatusFound, "/worklog?message="+errorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/worklog?message=Запись о работе перенесена в корзину")
+}
+
+// DeleteWorkRange переносит в корзину записи за период с from по to. Форма
+// отправляется со страницы подтверждения, перед удалением создаётся
+// страховочная копия табеля.
+func (h *WorkLogHandler) DeleteWorkRange(c *gin.Context) {
+	from, to := c.PostForm("from"), c.PostForm("to")
+	_, okFrom := parseAPIDate(from)
+	_, okTo := parseAPIDate(to)
+	if !okFrom || !okTo || to < from {
+		c.Redirect(http.StatusFound, "/worklog?message=Ошибка: Неверный период")
+		return
+	}
+
+	deleted, safety, err := h.workLogStore.DeleteEntries(requestActor(c), from, to)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.Redirect(http.StatusFound, "/worklog?message=Ошибка: За период нет записей")
+			return
+		}
+		c.Redirect(http.StatusFound, "/worklog?message="+url.QueryEscape("Ошибка: "+err.Error()))
+		return
+	}
+
+	message := fmt.Sprintf("Перенесено в корзину записей: %d", len(deleted))
+	if safety.Name != "" {
+		message += ". Копия табеля до удаления: " + safety.Created.Local().Format("02.01.2006 15:04:05")
+	}
+	c.Redirect(http.StatusFound, "/worklog?message="+url.QueryEscape(message))
+}
+
+// UndoWork отменяет последнее добавление, изменение или удаление записей
+func (h *WorkLogHandler) UndoWork(c *gin.Context) {
+	if _, err := h.workLogStore.Undo(requestActor(c)); err != nil {
+		c.Redirect(http.StatusFound, "/worklog?message="+undoErrorMessage(err))
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/worklog?message=Действие отменено")
+}
@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"errors"
+	"finance-tracker/models"
+	"finance-tracker/storage"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiWorkEntry — представление записи табеля в API
+type apiWorkEntry struct {
+	Date      string  `json:"date"` // YYYY-MM-DD
+	Place     string  `json:"place"`
+	StartTime string  `json:"start_time"` // HH:MM
+	EndTime   string  `json:"end_time"`   // HH:MM
+	IsDayOff  bool    `json:"is_day_off"`
+	Hours     float64 `json:"hours"` // Отработано часов с учётом обеда
+	// Отрезки работы за день. У записи с одним отрезком он повторяет place,
+	// start_time и end_time, у выходного список пуст.
+	Sessions []apiWorkSession `json:"sessions"`
+	// Записанные перерывы. Если список пуст, вычитается обед по правилам.
+	Breaks       []apiWorkBreak `json:"breaks"`
+	BreakMinutes int            `json:"break_minutes"` // Вычтено минут: перерывы или обед
+}
+
+// apiWorkBreak — перерыв в API: со временем начала и окончания или только
+// с длительностью
+type apiWorkBreak struct {
+	StartTime string `json:"start_time,omitempty"` // HH:MM
+	EndTime   string `json:"end_time,omitempty"`   // HH:MM
+	Minutes   int    `json:"minutes"`              // Для перерыва со временем считается сама
+}
+
+// apiWorkSession — отрезок работы в API
+type apiWorkSession struct {
+	Place     string  `json:"place"`
+	StartTime string  `json:"start_time"` // HH:MM
+	EndTime   string  `json:"end_time"`   // HH:MM
+	Hours     float64 `json:"hours"`      // Длительность без вычета обеда
+}
+
+// apiWorkEntryInput — тело запроса на создание или изменение записи табеля
+type apiWorkEntryInput struct {
+	Date      *string `json:"date"`
+	Place     *string `json:"place"`
+	StartTime *string `json:"start_time"`
+	EndTime   *string `json:"end_time"`
+	IsDayOff  *bool   `json:"is_day_off"`
+	// Sessions заменяет все отрезки работы за день, поля place, start_time и
+	// end_time при этом не учитываются
+	Sessions *[]apiWorkSession `json:"sessions"`
+	// Breaks заменяет все перерывы за день, пустой список возвращает обед по
+	// правилам
+	Breaks *[]apiWorkBreak `json:"breaks"`
+}
+
+// apiWorkLogSummary — сводка за месяц или год
+type apiWorkLogSummary struct {
+	Period string `json:"period"` // YYYY-MM или YYYY
+	storage.WorkTimeSummary
+	DaysOff int                 `json:"days_off"`
+	Months  []apiWorkLogSummary `json:"months,omitempty"`
+}
+
+func toAPIWorkEntry(entry models.WorkEntry, workTime storage.WorkTime) apiWorkEntry {
+	sessions := []apiWorkSession{}
+	for _, session := range entry.WorkSessions() {
+		sessions = append(sessions, apiWorkSession{
+			Place:     session.Place,
+			StartTime: session.StartTime,
+			EndTime:   session.EndTime,
+			Hours:     session.Hours(),
+		})
+	}
+	breaks := []apiWorkBreak{}
+	for _, b := range entry.Breaks {
+		breaks = append(breaks, apiWorkBreak{StartTime: b.StartTime, EndTime: b.EndTime, Minutes: b.Minutes})
+	}
+	day := workTime.Day(entry)
+	return apiWorkEntry{
+		Date:         entry.Date,
+		Place:        entry.Place,
+		StartTime:    entry.StartTime,
+		EndTime:      entry.EndTime,
+		IsDayOff:     entry.IsDayOff,
+		Hours:        day.Hours,
+		Sessions:     sessions,
+		Breaks:       breaks,
+		BreakMinutes: int(math.Round(day.Lunch * 60)),
+	}
+}
+
+// applyWorkEntryInput применяет поля запроса к записи: отсутствующие поля
+// сохраняют прежние значения, итоговая запись проверяется целиком. Выходной
+// день, как и в формах, хранится без места и со стандартным временем. Поля
+// place, start_time и end_time относятся к записи с одним отрезком: если их
+// передать, отрезки за день заменяются одним. Перерывы сохраняются, пока
+// не передан breaks, и должны укладываться в новые отрезки.
+func applyWorkEntryInput(entry models.WorkEntry, in apiWorkEntryInput) (models.WorkEntry, []apiFieldError) {
+	var errs []apiFieldError
+	fail := func(field, message string) {
+		errs = append(errs, apiFieldError{Field: field, Message: message})
+	}
+
+	if in.IsDayOff != nil {
+		entry.IsDayOff = *in.IsDayOff
+	}
+	if entry.IsDayOff {
+		entry.Place = ""
+		entry.StartTime = "08:00"
+		entry.EndTime = "17:00"
+		entry.Sessions = nil
+		entry.Breaks = nil
+		return entry, nil
+	}
+
+	var sessions []models.WorkSession
+	prefix := ""
+	if in.Sessions != nil {
+		for _, s := range *in.Sessions {
+			sessions = append(sessions, models.WorkSession{Place: strings.TrimSpace(s.Place), StartTime: s.StartTime, EndTime: s.EndTime})
+		}
+		if len(sessions) == 0 {
+			fail("sessions", "Укажите хотя бы один отрезок работы")
+		}
+	} else {
+		sessions = entry.WorkSessions()
+		if in.Place != nil || in.StartTime != nil || in.EndTime != nil {
+			// Одиночные поля заменяют отрезки за день одним
+			if len(entry.Sessions) > 0 {
+				sessions = []models.WorkSession{{}}
+			}
+			if in.Place != nil {
+				sessions[0].Place = strings.TrimSpace(*in.Place)
+			}
+			if in.StartTime != nil {
+				sessions[0].StartTime = *in.StartTime
+			}
+			if in.EndTime != nil {
+				sessions[0].EndTime = *in.EndTime
+			}
+		}
+	}
+
+	for i, session := range sessions {
+		if in.Sessions != nil {
+			prefix = fmt.Sprintf("sessions[%d].", i)
+		}
+		if session.Place == "" {
+			fail(prefix+"place", "Укажите место работы")
+		}
+		if session.StartTime == "" {
+			fail(prefix+"start_time", "Обязательное поле")
+		} else if _, err := time.Parse("15:04", session.StartTime); err != nil {
+			fail(prefix+"start_time", "Ожидается время в формате HH:MM")
+		}
+		if session.EndTime == "" {
+			fail(prefix+"end_time", "Обязательное поле")
+		} else if _, err := time.Parse("15:04", session.EndTime); err != nil {
+			fail(prefix+"end_time", "Ожидается время в формате HH:MM")
+		}
+	}
+
+	if in.Breaks != nil {
+		entry.Breaks = nil
+		for i, b := range *in.Breaks {
+			prefix = fmt.Sprintf("breaks[%d].", i)
+			if b.StartTime == "" && b.EndTime == "" {
+				if b.Minutes <= 0 {
+					fail(prefix+"minutes", "Ожидается длительность в минутах больше нуля")
+				}
+				entry.Breaks = append(entry.Breaks, models.WorkBreak{Minutes: b.Minutes})
+				continue
+			}
+			_, errStart := time.Parse("15:04", b.StartTime)
+			if errStart != nil {
+				fail(prefix+"start_time", "Ожидается время в формате HH:MM")
+			}
+			_, errEnd := time.Parse("15:04", b.EndTime)
+			if errEnd != nil {
+				fail(prefix+"end_time", "Ожидается время в формате HH:MM")
+			}
+			if errStart == nil && errEnd == nil {
+				start, end := models.WorkSession{StartTime: b.StartTime, EndTime: b.EndTime}.Minutes()
+				entry.Breaks = append(entry.Breaks, models.WorkBreak{StartTime: b.StartTime, EndTime: b.EndTime, Minutes: end - start})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return entry, errs
+	}
+
+	entry.SetSessions(sessions)
+	if err := storage.CheckWorkEntry(entry); err != nil {
+		field := "sessions"
+		if errors.Is(err, storage.ErrInvalidBreak) {
+			field = "breaks"
+		}
+		fail(field, workEntryMessage(err))
+		return entry, errs
+	}
+	return entry, nil
+}
+
+// parseAPIDate разбирает дату YYYY-MM-DD для параметров API
+func parseAPIDate(value string) (time.Time, bool) {
+	date, err := time.Parse("2006-01-02", value)
+	return date, err == nil
+}
+
+// APIListWorkEntries — GET /api/v1/worklog?from=YYYY-MM-DD&to=YYYY-MM-DD.
+// Обе границы включаются, записи сортируются по дате.
+func (h *WorkLogHandler) APIListWorkEntries(c *gin.Context) {
+	var errs []apiFieldError
+	from, to := c.Query("from"), c.Query("to")
+	if from != "" {
+		if _, ok := parseAPIDate(from); !ok {
+			errs = append(errs, apiFieldError{Field: "from", Message: "Ожидается дата YYYY-MM-DD"})
+		}
+	}
+	if to != "" {
+		if _, ok := parseAPIDate(to); !ok {
+			errs = append(errs, apiFieldError{Field: "to", Message: "Ожидается дата YYYY-MM-DD"})
+		}
+	}
+	if len(errs) > 0 {
+		apiAbort(c, http.StatusBadRequest, "invalid_query", "Некорректные параметры запроса", errs...)
+		return
+	}
+
+	// Даты в формате YYYY-MM-DD сравниваются как строки
+	workTime := h.workLogStore.WorkTime()
+	items := []apiWorkEntry{}
+	for _, entry := range h.workLogStore.QueryEntries(func(entry models.WorkEntry) bool {
+		return (from == "" || entry.Date >= from) && (to == "" || entry.Date <= to)
+	}) {
+		items = append(items, toAPIWorkEntry(entry, workTime))
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Date < items[j].Date })
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": items,
+		"total": len(items),
+	})
+}
+
+// apiWorkEntryDate проверяет дату записи из пути запроса и отвечает 400, если
+// она некорректна
+func apiWorkEntryDate(c *gin.Context) (string, bool) {
+	date := c.Param("date")
+	if _, ok := parseAPIDate(date); !ok {
+		apiAbort(c, http.StatusBadRequest, "invalid_date", "Ожидается дата YYYY-MM-DD")
+		return "", false
+	}
+	return date, true
+}
+
+func (h *WorkLogHandler) APIGetWorkEntry(c *gin.Context) {
+	date, ok := apiWorkEntryDate(c)
+	if !ok {
+		return
+	}
+	found := h.workLogStore.QueryEntries(func(entry models.WorkEntry) bool { return entry.Date == date })
+	if len(found) == 0 {
+		apiAbort(c, http.StatusNotFound, "not_found", "Запись не найдена")
+		return
+	}
+	c.JSON(http.StatusOK, toAPIWorkEntry(found[0], h.workLogStore.WorkTime()))
+}
+
+// APICreateWorkEntry создаёт запись за любую дату. На одну дату допускается
+// одна запись.
+func (h *WorkLogHandler) APICreateWorkEntry(c *gin.Context) {
+	var in apiWorkEntryInput
+	if err := decodeJSON(c, &in); err != nil {
+		apiAbort(c, http.StatusBadRequest, "invalid_json", "Некорректный JSON: "+err.Error())
+		return
+	}
+
+	var errs []apiFieldError
+	entry := models.WorkEntry{}
+	if in.Date == nil {
+		errs = append(errs, apiFieldError{Field: "date", Message: "Обязательное поле"})
+	} else if _, ok := parseAPIDate(*in.Date); !ok {
+		errs = append(errs, apiFieldError{Field: "date", Message: "Ожидается дата YYYY-MM-DD"})
+	} else {
+		entry.Date = *in.Date
+	}
+	entry, fieldErrs := applyWorkEntryInput(entry, in)
+	errs = append(errs, fieldErrs...)
+	if len(errs) > 0 {
+		apiValidationFailed(c, errs)
+		return
+	}
+
+	if err := h.workLogStore.AddEntry(requestActor(c), entry); err != nil {
+		if errors.Is(err, storage.ErrExists) {
+			apiAbort(c, http.StatusConflict, "already_exists", fmt.Sprintf("Запись за %s уже существует", entry.Date))
+			return
+		}
+		apiStoreFailed(c, err, "Запись не найдена")
+		return
+	}
+
+	c.Header("Location", "/api/v1/worklog/"+entry.Date)
+	c.JSON(http.StatusCreated, toAPIWorkEntry(entry, h.workLogStore.WorkTime()))
+}
+
+// APIUpdateWorkEntry обрабатывает PUT и PATCH: переданные поля заменяют
+// прежние. Дата записи не меняется: чтобы перенести запись, удалите её и
+// создайте заново.
+func (h *WorkLogHandler) APIUpdateWorkEntry(c *gin.Context) {
+	date, ok := apiWorkEntryDate(c)
+	if !ok {
+		return
+	}
+
+	var in apiWorkEntryInput
+	if err := decodeJSON(c, &in); err != nil {
+		apiAbort(c, http.StatusBadRequest, "invalid_json", "Некорректный JSON: "+err.Error())
+		return
+	}
+
+	entry, err := h.workLogStore.UpdateEntry(requestActor(c), date, func(entry *models.WorkEntry) error {
+		if in.Date != nil && *in.Date != entry.Date {
+			return apiValidationError([]apiFieldError{{Field: "date", Message: "Дату записи изменить нельзя"}})
+		}
+		updated, errs := applyWorkEntryInput(*entry, in)
+		if len(errs) > 0 {
+			return apiValidationError(errs)
+		}
+		*entry = updated
+		return nil
+	})
+	if err != nil {
+		apiStoreFailed(c, err, "Запись не найдена")
+		return
+	}
+
+	c.JSON(http.StatusOK, toAPIWorkEntry(entry, h.workLogStore.WorkTime()))
+}
+
+func (h *WorkLogHandler) APIDeleteWorkEntry(c *gin.Context) {
+	date, ok := apiWorkEntryDate(c)
+	if !ok {
+		return
+	}
+
+	if err := h.workLogStore.DeleteEntry(requestActor(c), date); err != nil {
+		apiStoreFailed(c, err, "Запись не найдена")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// APIDeleteWorkEntries — DELETE /api/v1/worklog?from=YYYY-MM-DD&to=YYYY-MM-DD.
+// Переносит в корзину все записи за период, обе границы включаются. Удаление
+// выполняется только с confirm=true; с dry_run=true возвращаются даты, которые
+// будут удалены, без изменений. Перед удалением создаётся страховочная копия
+// табеля, она возвращается в поле backup.
+func (h *WorkLogHandler) APIDeleteWorkEntries(c *gin.Context) {
+	var errs []apiFieldError
+	from, to := c.Query("from"), c.Query("to")
+	if _, ok := parseAPIDate(from); !ok {
+		errs = append(errs, apiFieldError{Field: "from", Message: "Ожидается дата YYYY-MM-DD"})
+	}
+	if _, ok := parseAPIDate(to); !ok {
+		errs = append(errs, apiFieldError{Field: "to", Message: "Ожидается дата YYYY-MM-DD"})
+	} else if len(errs) == 0 && to < from {
+		errs = append(errs, apiFieldError{Field: "to", Message: "Дата окончания раньше даты начала"})
+	}
+	if len(errs) > 0 {
+		apiAbort(c, http.StatusBadRequest, "invalid_query", "Некорректные параметры запроса", errs...)
+		return
+	}
+
+	if c.Query("dry_run") == "true" {
+		dates := []string{}
+		for _, entry := range h.workLogStore.QueryEntries(func(entry models.WorkEntry) bool {
+			return entry.Date >= from && entry.Date <= to
+		}) {
+			dates = append(dates, entry.Date)
+		}
+		sort.Strings(dates)
+		c.JSON(http.StatusOK, gin.H{"dates": dates, "total": len(dates), "dry_run": true})
+		return
+	}
+	if c.Query("confirm") != "true" {
+		apiAbort(c, http.StatusBadRequest, "confirmation_required",
+			"Удаление за период нужно подтвердить параметром confirm=true; dry_run=true покажет, что будет удалено")
+		return
+	}
+
+	dates, safety, err := h.workLogStore.DeleteEntries(requestActor(c), from, to)
+	if err != nil {
+		apiStoreFailed(c, err, "За период нет записей")
+		return
+	}
+
+	response := gin.H{"dates": dates, "total": len(dates)}
+	if safety.Name != "" {
+		response["backup"] = toAPIBackup(safety)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// APIUndo — POST /api/v1/worklog/undo: отменяет последнее добавление,
+// изменение или удаление записи табеля
+func (h *WorkLogHandler) APIUndo(c *gin.Context) {
+	action, err := h.workLogStore.Undo(requestActor(c))
+	if err != nil {
+		apiUndoFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"undone": toAPIUndoAction(action)})
+}
+
+// APIWorkLogSummary — GET /api/v1/worklog/summary?month=YYYY-MM или ?year=YYYY.
+// Годовая сводка содержит разбивку по месяцам.
+func (h *WorkLogHandler) APIWorkLogSummary(c *gin.Context) {
+	month, year := c.Query("month"), c.Query("year")
+	if (month == "") == (year == "") {
+		apiAbort(c, http.StatusBadRequest, "invalid_query", "Укажите month=YYYY-MM или year=YYYY")
+		return
+	}
+
+	workTime := h.workLogStore.WorkTime()
+	days := workTime.Days(h.workLogStore.QueryEntries(nil))
+	if month != "" {
+		if _, err := time.Parse("2006-01", month); err != nil {
+			apiAbort(c, http.StatusBadRequest, "invalid_query", "Некорректные параметры запроса",
+				apiFieldError{Field: "month", Message: "Ожидается месяц YYYY-MM"})
+			return
+		}
+		c.JSON(http.StatusOK, periodSummary(workTime, days, month))
+		return
+	}
+
+	if _, err := time.Parse("2006", year); err != nil {
+		apiAbort(c, http.StatusBadRequest, "invalid_query", "Некорректные параметры запроса",
+			apiFieldError{Field: "year", Message: "Ожидается год YYYY"})
+		return
+	}
+	summary := periodSummary(workTime, days, year)
+	for m := 1; m <= 12; m++ {
+		summary.Months = append(summary.Months, periodSummary(workTime, days, fmt.Sprintf("%s-%02d", year, m)))
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// periodSummary считает сводку по дням, дата которых начинается с period
+func periodSummary(workTime storage.WorkTime, days []storage.WorkDay, period string) apiWorkLogSummary {
+	daysOff := 0
+	for _, day := range days {
+		if day.IsDayOff && strings.HasPrefix(day.Date, period) {
+			daysOff++
+		}
+	}
+	return apiWorkLogSummary{
+		Period:          period,
+		WorkTimeSummary: workPeriodSummary(workTime, days, period),
+		DaysOff:         daysOff,
+	}
+}
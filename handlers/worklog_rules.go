@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"finance-tracker/models"
+	"finance-tracker/storage"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Правила учёта рабочего времени: форма в табеле и API. Сам расчёт — в
+// storage.WorkTime.
+
+// formatHours выводит число часов без лишних нулей: 8, 7.5
+func formatHours(hours float64) string {
+	return strconv.FormatFloat(hours, 'f', -1, 64)
+}
+
+// workRulesView возвращает правила для формы в табеле
+func workRulesView(rules models.WorkRules) gin.H {
+	return gin.H{
+		"LunchMinutes":       rules.LunchMinutes,
+		"LunchAfterHours":    formatHours(rules.LunchAfterHours),
+		"DailyNormHours":     formatHours(rules.DailyNormHours),
+		"WeeklyNormHours":    formatHours(rules.WeeklyNormHours),
+		"OvertimeMultiplier": formatHours(rules.OvertimeMultiplier),
+		"NightStart":         rules.NightStart,
+		"NightEnd":           rules.NightEnd,
+		"NightMultiplier":    formatHours(rules.NightMultiplier),
+		"WeekendMultiplier":  formatHours(rules.WeekendMultiplier),
+		"Description":        workRulesDescription(rules),
+	}
+}
+
+// workRulesDescription описывает правила по строкам для страницы и отчёта
+func workRulesDescription(rules models.WorkRules) []string {
+	lines := []string{}
+	if rules.LunchMinutes > 0 {
+		lines = append(lines, fmt.Sprintf("Если за день отработано больше %s ч (по всем отрезкам), вычитается обед %d мин, если перерывы не записаны",
+			formatHours(rules.LunchAfterHours), rules.LunchMinutes))
+	}
+	if rules.DailyNormHours > 0 {
+		lines = append(lines, fmt.Sprintf("Норма %s ч в день", formatHours(rules.DailyNormHours)))
+	}
+	if rules.WeeklyNormHours > 0 {
+		lines = append(lines, fmt.Sprintf("Норма %s ч в неделю (с понедельника)", formatHours(rules.WeeklyNormHours)))
+	}
+	if rules.DailyNormHours > 0 || rules.WeeklyNormHours > 0 {
+		lines = append(lines, fmt.Sprintf("Часы сверх нормы оплачиваются с коэффициентом %s", formatHours(rules.OvertimeMultiplier)))
+	}
+	if rules.NightMultiplier > 1 {
+		lines = append(lines, fmt.Sprintf("Ночные часы (%s–%s) оплачиваются с коэффициентом %s",
+			rules.NightStart, rules.NightEnd, formatHours(rules.NightMultiplier)))
+	}
+	if rules.WeekendMultiplier > 1 {
+		lines = append(lines, fmt.Sprintf("Часы в субботу и воскресенье оплачиваются с коэффициентом %s", formatHours(rules.WeekendMultiplier)))
+	}
+	return lines
+}
+
+// SaveWorkRules — POST /worklog/rules: сохраняет правила из формы или, с
+// reset=true, возвращает правила по умолчанию
+func (h *WorkLogHandler) SaveWorkRules(c *gin.Context) {
+	rules := storage.DefaultWorkRules
+	if c.PostForm("reset") != "true" {
+		var errMsg string
+		rules, errMsg = workRulesForm(c)
+		if errMsg != "" {
+			c.Redirect(http.StatusFound, "/worklog?message="+url.QueryEscape("Ошибка: "+errMsg))
+			return
+		}
+	}
+	if err := storage.ValidateWorkRules(rules); err != nil {
+		c.Redirect(http.StatusFound, "/worklog?message="+url.QueryEscape("Ошибка: "+capitalize(err.Error())))
+		return
+	}
+
+	if err := h.workLogStore.SetRules(requestActor(c), rules); err != nil {
+		c.Redirect(http.StatusFound, "/worklog?message=Ошибка при сохранении данных")
+		return
+	}
+	c.Redirect(http.StatusFound, "/worklog?message=Правила учёта времени сохранены")
+}
+
+// workRulesForm читает правила из формы. Вторым значением возвращается текст
+// ошибки.
+func workRulesForm(c *gin.Context) (models.WorkRules, string) {
+	number := func(name, label string) (float64, string) {
+		value, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(c.PostForm(name)), ",", "."), 64)
+		if err != nil {
+			return 0, "Неверное значение поля «" + label + "»"
+		}
+		return value, ""
+	}
+
+	rules := models.WorkRules{
+		NightStart: c.PostForm("night_start"),
+		NightEnd:   c.PostForm("night_end"),
+	}
+	lunch, errMsg := number("lunch_minutes", "Обед, мин")
+	if errMsg != "" {
+		return rules, errMsg
+	}
+	rules.LunchMinutes = int(lunch)
+
+	fields := []struct {
+		name, label string
+		value       *float64
+	}{
+		{"lunch_after_hours", "Обед, если больше часов", &rules.LunchAfterHours},
+		{"daily_norm_hours", "Норма в день", &rules.DailyNormHours},
+		{"weekly_norm_hours", "Норма в неделю", &rules.WeeklyNormHours},
+		{"overtime_multiplier", "Коэффициент переработки", &rules.OvertimeMultiplier},
+		{"night_multiplier", "Коэффициент ночных часов", &rules.NightMultiplier},
+		{"weekend_multiplier", "Коэффициент выходных", &rules.WeekendMultiplier},
+	}
+	for _, f := range fields {
+		value, errMsg := number(f.name, f.label)
+		if errMsg != "" {
+			return rules, errMsg
+		}
+		*f.value = value
+	}
+	return rules, ""
+}
+
+// capitalize делает заглавной первую букву сообщения об ошибке хранилища
+func capitalize(message string) string {
+	for i := range message {
+		if i > 0 {
+			return strings.ToUpper(message[:i]) + message[i:]
+		}
+	}
+	return strings.ToUpper(message)
+}
+
+// apiWorkRules — правила учёта рабочего времени в API
+type apiWorkRules struct {
+	LunchMinutes       int     `json:"lunch_minutes"`
+	LunchAfterHours    float64 `json:"lunch_after_hours"`
+	DailyNormHours     float64 `json:"daily_norm_hours"`  // 0 — без дневной переработки
+	WeeklyNormHours    float64 `json:"weekly_norm_hours"` // 0 — без недельной переработки
+	OvertimeMultiplier float64 `json:"overtime_multiplier"`
+	NightStart         string  `json:"night_start"` // HH:MM
+	NightEnd           string  `json:"night_end"`   // HH:MM
+	NightMultiplier    float64 `json:"night_multiplier"`
+	WeekendMultiplier  float64 `json:"weekend_multiplier"`
+}
+
+func toAPIWorkRules(rules models.WorkRules) apiWorkRules {
+	return apiWorkRules(rules)
+}
+
+// APIGetWorkRules — GET /api/v1/worklog/rules
+func (h *WorkLogHandler) APIGetWorkRules(c *gin.Context) {
+	c.JSON(http.StatusOK, toAPIWorkRules(h.workLogStore.WorkTime().Rules()))
+}
+
+// APIUpdateWorkRules — PUT /api/v1/worklog/rules: заменяет правила целиком.
+// Поля, которых нет в запросе, берутся из правил по умолчанию.
+func (h *WorkLogHandler) APIUpdateWorkRules(c *gin.Context) {
+	in := toAPIWorkRules(storage.DefaultWorkRules)
+	if err := decodeJSON(c, &in); err != nil {
+		apiAbort(c, http.StatusBadRequest, "invalid_json", "Некорректный JSON: "+err.Error())
+		return
+	}
+
+	rules := models.WorkRules(in)
+	if err := storage.ValidateWorkRules(rules); err != nil {
+		var ruleErr *storage.WorkRuleError
+		if errors.As(err, &ruleErr) {
+			// Имя поля в API берётся из JSON-тега apiWorkRules
+			field, _ := reflect.TypeOf(apiWorkRules{}).FieldByName(ruleErr.Field)
+			apiValidationFailed(c, []apiFieldError{{Field: field.Tag.Get("json"), Message: capitalize(ruleErr.Message)}})
+			return
+		}
+		apiAbort(c, http.StatusUnprocessableEntity, "validation_failed", capitalize(err.Error()))
+		return
+	}
+	if err := h.workLogStore.SetRules(requestActor(c), rules); err != nil {
+		apiStoreFailed(c, err, "")
+		return
+	}
+	c.JSON(http.StatusOK, toAPIWorkRules(rules))
+}
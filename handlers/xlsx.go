@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Минимальная запись книги XLSX (Office Open XML) без сторонних библиотек:
+// строки хранятся прямо в ячейках, стили — жирный заголовок, число с двумя
+// знаками и дата со временем.
+
+// Стили ячеек, индексы в cellXfs из xlsxStyles
+const (
+	xlsxStyleText   = 0
+	xlsxStyleHeader = 1
+	xlsxStyleNumber = 2
+	xlsxStyleDate   = 3
+)
+
+// xlsxCell — ячейка листа: строка, число или дата
+type xlsxCell struct {
+	text   string
+	number float64
+	date   time.Time
+	kind   byte // 's' — строка, 'n' — число, 'd' — дата
+	style  int
+}
+
+func xlsxText(value string) xlsxCell {
+	return xlsxCell{text: value, kind: 's', style: xlsxStyleText}
+}
+
+func xlsxHeader(value string) xlsxCell {
+	return xlsxCell{text: value, kind: 's', style: xlsxStyleHeader}
+}
+
+func xlsxNumber(value float64) xlsxCell {
+	return xlsxCell{number: value, kind: 'n', style: xlsxStyleNumber}
+}
+
+func xlsxInt(value int) xlsxCell {
+	return xlsxCell{number: float64(value), kind: 'n', style: xlsxStyleText}
+}
+
+func xlsxDate(value time.Time) xlsxCell {
+	return xlsxCell{date: value, kind: 'd', style: xlsxStyleDate}
+}
+
+// xlsxSheet — лист книги. widths — ширина колонок в символах.
+type xlsxSheet struct {
+	name   string
+	widths []float64
+	rows   [][]xlsxCell
+}
+
+func (s *xlsxSheet) addRow(cells ...xlsxCell) {
+	s.rows = append(s.rows, cells)
+}
+
+// xlsxColumn возвращает буквенное имя колонки: 0 — A, 26 — AA
+func xlsxColumn(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// xlsxSerial переводит время в число дней Excel (от 30.12.1899) без учёта
+// часового пояса: в ячейке оказывается местное время операции
+func xlsxSerial(t time.Time) float64 {
+	t = t.Local()
+	local := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return local.Sub(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)).Hours() / 24
+}
+
+func xlsxEscape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+func (s *xlsxSheet) xml() string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(s.widths) > 0 {
+		b.WriteString("<cols>")
+		for i, w := range s.widths {
+			fmt.Fprintf(&b, `<col min="%d" max="%d" width="%g" customWidth="1"/>`, i+1, i+1, w)
+		}
+		b.WriteString("</cols>")
+	}
+	b.WriteString("<sheetData>")
+	for r, row := range s.rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			ref := xlsxColumn(c) + strconv.Itoa(r+1)
+			switch cell.kind {
+			case 'n':
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, cell.style, strconv.FormatFloat(cell.number, 'f', -1, 64))
+			case 'd':
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, cell.style, strconv.FormatFloat(xlsxSerial(cell.date), 'f', -1, 64))
+			default:
+				if cell.text == "" {
+					continue
+				}
+				fmt.Fprintf(&b, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, cell.style, xlsxEscape(cell.text))
+			}
+		}
+		b.WriteString("</row>")
+	}
+	b.WriteString("</sheetData></worksheet>")
+	return b.String()
+}
+
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="dd.mm.yyyy hh:mm"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="4">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="2" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs></styleSheet>`
+
+// xlsxPart — файл внутри архива книги
+type xlsxPart struct {
+	name, body string
+}
+
+// writeXLSX записывает книгу из листов sheets
+func writeXLSX(w io.Writer, sheets []*xlsxSheet) error {
+	files := []xlsxPart{}
+
+	var types, workbook, rels strings.Builder
+	types.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i, sheet := range sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xlsxEscape(sheet.name), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+		files = append(files, xlsxPart{fmt.Sprintf("xl/worksheets/sheet%d.xml", n), sheet.xml()})
+	}
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+	types.WriteString("</Types>")
+	workbook.WriteString("</sheets></workbook>")
+	rels.WriteString("</Relationships>")
+
+	files = append(files,
+		xlsxPart{"[Content_Types].xml", types.String()},
+		xlsxPart{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		xlsxPart{"xl/workbook.xml", workbook.String()},
+		xlsxPart{"xl/_rels/workbook.xml.rels", rels.String()},
+		xlsxPart{"xl/styles.xml", xlsxStyles},
+	)
+
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
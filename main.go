@@ -4,31 +4,108 @@ package main
 import (
 	"finance-tracker/auth"
 	"finance-tracker/handlers"
+	"finance-tracker/scheduler"
 	"finance-tracker/storage"
+	"flag"
 	"fmt"
 	"html/template"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	storageKind := flag.String("storage", "json", "хранилище данных: json или sqlite")
+	dbPath := flag.String("db", "finance.db", "путь к базе SQLite")
+	backupDir := flag.String("backup-dir", "backups", "каталог резервных копий")
+	policy := storage.DefaultBackupPolicy
+	flag.IntVar(&policy.Hourly, "backup-hourly", policy.Hourly, "сколько последних часов хранить почасовые копии")
+	flag.IntVar(&policy.Daily, "backup-daily", policy.Daily, "сколько последних дней хранить ежедневные копии")
+	flag.IntVar(&policy.Weekly, "backup-weekly", policy.Weekly, "сколько последних недель хранить еженедельные копии")
+	auditPath := flag.String("audit-log", "audit_log.jsonl", "файл журнала изменений")
+	trashDays := flag.Int("trash-days", storage.DefaultTrashDays, "через сколько дней удалённые записи стираются из корзины")
+	flag.Parse()
+
 	fmt.Println("Запуск приложения...")
 
 	// Инициализация хранилищ
-	financeStore := storage.NewFinanceStorage("finance_data.json")
-	workLogStore := storage.NewWorkLogStorage("worklog_data.json")
+	var financeStore *storage.FinanceStorage
+	var workLogStore *storage.WorkLogStorage
+	var closeDB func() error
+	switch *storageKind {
+	case "json":
+		financeStore = storage.NewFinanceStorage("finance_data.json")
+		workLogStore = storage.NewWorkLogStorage("worklog_data.json")
+	case "sqlite":
+		db, err := storage.OpenSQLite(*dbPath)
+		if err != nil {
+			fmt.Println("Ошибка открытия базы:", err)
+			os.Exit(1)
+		}
+		closeDB = db.Close
+		financeStore = storage.NewFinanceStorageWithBackend(db)
+		workLogStore = storage.NewWorkLogStorageWithBackend(db)
+	default:
+		fmt.Println("Неизвестное хранилище:", *storageKind)
+		os.Exit(1)
+	}
+
+	// Резервные копии подключаются до загрузки: из них восстанавливается
+	// повреждённый файл данных
+	backups := storage.NewBackups(*backupDir, policy)
+	financeStore.SetBackups(backups)
+	workLogStore.SetBackups(backups)
+
+	audit, err := storage.OpenAuditLog(*auditPath)
+	if err != nil {
+		fmt.Println("Ошибка открытия журнала изменений:", err)
+		os.Exit(1)
+	}
+	financeStore.SetAuditLog(audit)
+	workLogStore.SetAuditLog(audit)
 
-	// Загружаем данные
+	// Загружаем данные. Без данных сервер не запускается: иначе первое же
+	// сохранение перезаписало бы их пустыми.
 	if err := financeStore.Load(); err != nil {
 		fmt.Println("Ошибка загрузки финансовых данных:", err)
+		os.Exit(1)
 	}
 	if err := workLogStore.Load(); err != nil {
 		fmt.Println("Ошибка загрузки данных табеля:", err)
+		os.Exit(1)
 	}
 
+	// При штатной остановке сворачиваем журналы в файлы данных
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		fmt.Println("Остановка сервера...")
+		if err := financeStore.Close(); err != nil {
+			fmt.Println("Ошибка сохранения финансовых данных:", err)
+		}
+		if err := workLogStore.Close(); err != nil {
+			fmt.Println("Ошибка сохранения данных табеля:", err)
+		}
+		if closeDB != nil {
+			closeDB()
+		}
+		audit.Close()
+		os.Exit(0)
+	}()
+
 	// Пересчитываем баланс
 	financeStore.RecalculateBalances()
 
+	// Регулярные операции: догоняем пропущенные и проверяем раз в час
+	scheduler.StartRecurring(financeStore, time.Hour)
+
+	// Корзина: удалённые записи хранятся trash-days дней
+	scheduler.StartTrashPurge(financeStore, workLogStore, *trashDays, time.Hour)
+
 	// Настройка Gin
 	r := gin.Default()
 
@@ -51,7 +128,7 @@ func main() {
 	r.LoadHTMLGlob("templates/*")
 
 	// Регистрация маршрутов
-	handlers.RegisterRoutes(r, financeStore, workLogStore)
+	handlers.RegisterRoutes(r, financeStore, workLogStore, audit)
 
 	// Запуск сервера
 	fmt.Println("Сервер запущен на http://localhost:8088")
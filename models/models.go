@@ -1,7 +1,35 @@
 // models/models.go
 package models
 
-import "time"
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Виды операций. Обычные доходы и расходы имеют пустой Kind.
+const (
+	KindTransfer = "transfer" // Половина перевода между счетами
+	KindExchange = "exchange" // Половина обмена валюты между кошельками
+)
+
+// Account — счёт или кошелёк: наличные, карта, вклад
+type Account struct {
+	ID             int
+	Name           string
+	Currency       string
+	Type           string // "cash", "card", "deposit", "other"
+	OpeningBalance float64
+	Archived       bool
+	BankAccount    string `json:",omitempty"` // Номер счёта в банке или IBAN: по нему выписки сопоставляются со счётом
+}
+
+type Category struct {
+	ID       int
+	Name     string
+	Icon     string // Эмодзи или короткий символ для отображения
+	IsIncome bool   // Категория доходов (true) или расходов (false)
+}
 
 type Transaction struct {
 	ID          int
@@ -11,11 +39,91 @@ type Transaction struct {
 	IsPositive  bool
 	Currency    string
 	Notes       string
+	CategoryID  int // 0 — без категории
+	RecurringID int // ID регулярного шаблона, из которого создана операция (0 — ручной ввод)
+	AccountID   int
+	Kind        string  // Пусто для обычных операций, KindTransfer или KindExchange
+	LinkedID    int     // ID парной операции перевода или обмена
+	Rate        float64 // Курс обмена: единиц целевой валюты за единицу исходной
+	ExternalID  string  `json:",omitempty"` // ID операции в банке, если она импортирована из выписки
+}
+
+// IsTransfer сообщает, что операция — часть перевода между счетами и не
+// является доходом или расходом
+func (t Transaction) IsTransfer() bool {
+	return t.Kind == KindTransfer
+}
+
+// IsExchange сообщает, что операция — часть обмена валюты. Расходная половина
+// хранит исходную сумму и валюту, доходная — полученные сумму и валюту.
+func (t Transaction) IsExchange() bool {
+	return t.Kind == KindExchange
+}
+
+// IsInternal сообщает, что операция перемещает деньги между своими счетами
+// (перевод или обмен) и не входит в доходы и расходы
+func (t Transaction) IsInternal() bool {
+	return t.IsTransfer() || t.IsExchange()
+}
+
+// IsPlanned сообщает, что операция датирована будущим и ещё не должна
+// учитываться в балансе
+func (t Transaction) IsPlanned(now time.Time) bool {
+	return t.DateTime.After(now)
+}
+
+// RecurringTransaction — шаблон регулярной операции (аренда, подписки, зарплата)
+type RecurringTransaction struct {
+	ID          int
+	Amount      float64
+	Description string
+	IsPositive  bool
+	Currency    string
+	Notes       string
+	CategoryID  int
+	AccountID   int
+	Frequency   string    // "daily", "weekly", "monthly", "yearly"
+	StartDate   time.Time // Дата и время первого повторения
+	EndDate     time.Time // Нулевое значение — без даты окончания
+	Count       int       // Максимальное число повторений, 0 — без ограничения
+	Generated   int       // Сколько повторений уже создано
+	Paused      bool
+}
+
+// Budget задаёт месячный лимит расходов по категории в одной валюте
+type Budget struct {
+	ID         int
+	CategoryID int
+	Currency   string
+	Limit      float64
+}
+
+// ExchangeRate — официальный курс валюты на дату: стоимость одной единицы
+// валюты в белорусских рублях (как в данных Нацбанка)
+type ExchangeRate struct {
+	Date     string // Формат: "2006-01-02"
+	Currency string
+	Rate     float64
+}
+
+// DeletedTransaction — операция в корзине. Из корзины её можно вернуть, пока
+// она не удалена окончательно.
+type DeletedTransaction struct {
+	Transaction
+	DeletedAt time.Time
 }
 
 type FinanceData struct {
-	Transactions []Transaction
-	Balances     map[string]float64
+	Transactions    []Transaction
+	Trash           []DeletedTransaction `json:",omitempty"`
+	Balances        map[string]float64
+	Accounts        []Account
+	AccountBalances map[int]float64
+	Categories      []Category
+	Budgets         []Budget
+	Recurring       []RecurringTransaction
+	Rates           []ExchangeRate
+	BaseCurrency    string // Валюта, в которую пересчитываются итоги
 }
 
 type WorkEntry struct {
@@ -24,8 +132,150 @@ type WorkEntry struct {
 	StartTime string // Формат: "15:04"
 	EndTime   string // Формат: "15:04"
 	IsDayOff  bool
+	// Sessions — отрезки работы, если за день их несколько. Place, StartTime
+	// и EndTime тогда описывают день целиком: места через запятую, начало
+	// первого и конец последнего отрезка.
+	Sessions []WorkSession `json:",omitempty"`
+	// Breaks — записанные перерывы. Если они есть, из дня вычитаются они,
+	// а не обед по правилам.
+	Breaks []WorkBreak `json:",omitempty"`
+}
+
+// WorkBreak — перерыв в работе: с временем начала и окончания или только
+// длительностью
+type WorkBreak struct {
+	StartTime string `json:",omitempty"` // Формат: "15:04", пусто — указана только длительность
+	EndTime   string `json:",omitempty"`
+	Minutes   int    // Для перерыва со временем считается по StartTime и EndTime
+}
+
+// HasTime сообщает, что у перерыва указано время начала и окончания
+func (b WorkBreak) HasTime() bool {
+	return b.StartTime != "" && b.EndTime != ""
+}
+
+// BreakMinutes возвращает общую длительность записанных перерывов
+func (e WorkEntry) BreakMinutes() int {
+	total := 0
+	for _, b := range e.Breaks {
+		total += b.Minutes
+	}
+	return total
+}
+
+// WorkSession — отрезок работы в одном месте. Если конец раньше начала,
+// отрезок заканчивается на следующий день.
+type WorkSession struct {
+	Place     string
+	StartTime string // Формат: "15:04"
+	EndTime   string // Формат: "15:04"
+}
+
+// Minutes возвращает начало и конец отрезка в минутах от начала дня. Конец
+// отрезка через полночь больше 24 часов.
+func (s WorkSession) Minutes() (start, end int) {
+	startTime, _ := time.Parse("15:04", s.StartTime)
+	endTime, _ := time.Parse("15:04", s.EndTime)
+	start = startTime.Hour()*60 + startTime.Minute()
+	end = endTime.Hour()*60 + endTime.Minute()
+	if end < start {
+		end += 24 * 60
+	}
+	return start, end
+}
+
+// Hours возвращает длительность отрезка в часах, без вычета обеда
+func (s WorkSession) Hours() float64 {
+	start, end := s.Minutes()
+	return float64(end-start) / 60
+}
+
+// WorkSessions возвращает отрезки работы за день. У записи с одним отрезком
+// он составляется из Place, StartTime и EndTime, у выходного отрезков нет.
+func (e WorkEntry) WorkSessions() []WorkSession {
+	if e.IsDayOff {
+		return nil
+	}
+	if len(e.Sessions) > 0 {
+		return append([]WorkSession(nil), e.Sessions...)
+	}
+	return []WorkSession{{Place: e.Place, StartTime: e.StartTime, EndTime: e.EndTime}}
+}
+
+// OrderSessions упорядочивает отрезки работы по времени. Отрезки, начатые
+// после полуночи, могут продолжать ночную смену, поэтому день начинается
+// после самого длинного перерыва между отрезками по кругу суток: 22:00–00:00
+// и 00:30–02:00 идут в этом порядке, 08:00–12:00 и 13:00–17:00 — как обычно.
+func OrderSessions(sessions []WorkSession) []WorkSession {
+	sorted := append([]WorkSession(nil), sessions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, _ := sorted[i].Minutes()
+		b, _ := sorted[j].Minutes()
+		return a < b
+	})
+
+	first, longestGap := 0, -1
+	for i := range sorted {
+		_, prevEnd := sorted[(i+len(sorted)-1)%len(sorted)].Minutes()
+		start, _ := sorted[i].Minutes()
+		if i == 0 {
+			start += 24 * 60
+		}
+		if gap := start - prevEnd; gap > longestGap {
+			first, longestGap = i, gap
+		}
+	}
+	return append(sorted[first:], sorted[:first]...)
+}
+
+// SetSessions записывает отрезки работы в порядке OrderSessions. Один
+// отрезок хранится прямо в Place, StartTime и EndTime, как в записях до
+// появления отрезков.
+func (e *WorkEntry) SetSessions(sessions []WorkSession) {
+	sessions = OrderSessions(sessions)
+
+	e.Sessions = nil
+	if len(sessions) == 0 {
+		return
+	}
+	if len(sessions) > 1 {
+		e.Sessions = sessions
+	}
+	places := []string{}
+	seen := map[string]bool{}
+	for _, s := range sessions {
+		if !seen[s.Place] {
+			seen[s.Place] = true
+			places = append(places, s.Place)
+		}
+	}
+	e.Place = strings.Join(places, ", ")
+	e.StartTime = sessions[0].StartTime
+	e.EndTime = sessions[len(sessions)-1].EndTime
+}
+
+// DeletedWorkEntry — запись табеля в корзине
+type DeletedWorkEntry struct {
+	WorkEntry
+	DeletedAt time.Time
+}
+
+// WorkRules — правила учёта рабочего времени: обед, нормы и коэффициенты
+// оплаты. Коэффициент 1 означает отсутствие надбавки.
+type WorkRules struct {
+	LunchMinutes       int     // Длительность обеда, вычитается из рабочего дня
+	LunchAfterHours    float64 // Обед вычитается, если за день отработано больше
+	DailyNormHours     float64 // Норма часов в день, 0 — без дневной переработки
+	WeeklyNormHours    float64 // Норма часов в неделю, 0 — без недельной переработки
+	OvertimeMultiplier float64 // Коэффициент оплаты часов сверх нормы
+	NightStart         string  // Начало ночных часов, формат "15:04"
+	NightEnd           string  // Окончание ночных часов, формат "15:04"
+	NightMultiplier    float64 // Коэффициент оплаты ночных часов
+	WeekendMultiplier  float64 // Коэффициент оплаты часов в субботу и воскресенье
 }
 
 type WorkLogData struct {
 	Entries []WorkEntry
+	Trash   []DeletedWorkEntry `json:",omitempty"`
+	Rules   *WorkRules         `json:",omitempty"` // nil — правила по умолчанию
 }
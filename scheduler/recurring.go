@@ -0,0 +1,34 @@
+// scheduler/recurring.go
+package scheduler
+
+import (
+	"finance-tracker/storage"
+	"fmt"
+	"time"
+)
+
+// StartRecurring запускает фоновую обработку регулярных операций: сразу при
+// старте (чтобы догнать пропущенные за время простоя повторения) и далее
+// с заданным интервалом. На каждом шаге баланс пересчитывается, чтобы в него
+// попали запланированные операции, чья дата уже наступила.
+func StartRecurring(financeStore *storage.FinanceStorage, interval time.Duration) {
+	RunRecurring(financeStore)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			RunRecurring(financeStore)
+			// Запланированные операции, дата которых наступила, входят в баланс
+			financeStore.RecalculateBalances()
+		}
+	}()
+}
+
+// RunRecurring создаёт наступившие регулярные операции и сохраняет данные,
+// если что-то изменилось
+func RunRecurring(financeStore *storage.FinanceStorage) {
+	if _, err := financeStore.ApplyRecurring(time.Now()); err != nil {
+		fmt.Println("Ошибка сохранения регулярных операций:", err)
+	}
+}
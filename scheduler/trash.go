@@ -0,0 +1,38 @@
+// scheduler/trash.go
+package scheduler
+
+import (
+	"finance-tracker/storage"
+	"fmt"
+	"time"
+)
+
+// StartTrashPurge раз в interval окончательно удаляет из корзин операции и
+// записи табеля, удалённые больше days дней назад. Первая очистка выполняется
+// сразу при старте.
+func StartTrashPurge(financeStore *storage.FinanceStorage, workLogStore *storage.WorkLogStorage, days int, interval time.Duration) {
+	RunTrashPurge(financeStore, workLogStore, days)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			RunTrashPurge(financeStore, workLogStore, days)
+		}
+	}()
+}
+
+// RunTrashPurge очищает корзины от записей старше days дней
+func RunTrashPurge(financeStore *storage.FinanceStorage, workLogStore *storage.WorkLogStorage, days int) {
+	now := time.Now()
+	if n, err := financeStore.PurgeExpiredTrash(days, now); err != nil {
+		fmt.Println("Ошибка очистки корзины операций:", err)
+	} else if n > 0 {
+		fmt.Printf("Из корзины удалено операций: %d\n", n)
+	}
+	if n, err := workLogStore.PurgeExpiredTrash(days, now); err != nil {
+		fmt.Println("Ошибка очистки корзины табеля:", err)
+	} else if n > 0 {
+		fmt.Printf("Из корзины удалено записей табеля: %d\n", n)
+	}
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"finance-tracker/models"
+	"fmt"
+)
+
+// Типы счетов
+const (
+	AccountCash    = "cash"
+	AccountCard    = "card"
+	AccountDeposit = "deposit"
+	AccountOther   = "other"
+)
+
+// ValidAccountType сообщает, поддерживается ли тип счёта
+func ValidAccountType(accountType string) bool {
+	switch accountType {
+	case AccountCash, AccountCard, AccountDeposit, AccountOther:
+		return true
+	}
+	return false
+}
+
+// DefaultAccountID возвращает счёт по умолчанию для валюты, создавая его при
+// необходимости. Используется для операций, у которых счёт не указан.
+// Вызывается внутри Update.
+func DefaultAccountID(data *models.FinanceData, currency string) int {
+	for _, a := range data.Accounts {
+		if a.Currency == currency && !a.Archived {
+			return a.ID
+		}
+	}
+
+	newID := 1
+	for _, a := range data.Accounts {
+		if a.ID >= newID {
+			newID = a.ID + 1
+		}
+	}
+	data.Accounts = append(data.Accounts, models.Account{
+		ID:       newID,
+		Name:     fmt.Sprintf("Кошелёк %s", currency),
+		Currency: currency,
+		Type:     AccountCash,
+	})
+	fmt.Printf("Создан счёт по умолчанию для валюты %s\n", currency)
+	return newID
+}
+
+// assignDefaultAccounts привязывает операции без счёта к счёту по умолчанию
+// их валюты. Нужна для данных, созданных до появления счетов.
+func (s *FinanceStorage) assignDefaultAccounts() {
+	for i, t := range s.data.Transactions {
+		if t.AccountID == 0 {
+			s.data.Transactions[i].AccountID = DefaultAccountID(&s.data, t.Currency)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"finance-tracker/models"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Журнал изменений общий для финансов и табеля и не зависит от бэкенда. Каждое
+// изменение операции, записи табеля, справочника или настройки дописывается
+// строкой JSON в файл журнала: кто, когда, откуда, что было и что стало. Записи журнала не
+// меняются и не удаляются. Хранилища сами сравнивают данные до и после
+// изменения, поэтому в журнал попадает любое изменение, через какой бы метод
+// оно ни прошло.
+
+// Источники изменений
+const (
+	SourceWeb       = "web"
+	SourceAPI       = "api"
+	SourceImport    = "import"
+	SourceScheduler = "scheduler"
+)
+
+// Действия с записью
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"  // Перенос в корзину
+	AuditRestore = "restore" // Возврат из корзины
+	AuditPurge   = "purge"   // Окончательное удаление
+)
+
+// Actor — кто и откуда вносит изменение
+type Actor struct {
+	User   string
+	Source string
+}
+
+// SchedulerActor — фоновые задачи: регулярные операции, очистка корзины
+var SchedulerActor = Actor{User: "system", Source: SourceScheduler}
+
+// AuditEntry — запись журнала об изменении одной операции или записи табеля.
+// Old и New — запись целиком до и после изменения; у созданной записи нет
+// Old, у окончательно удалённой — New.
+type AuditEntry struct {
+	ID     int             `json:"id"`
+	Time   time.Time       `json:"time"`
+	User   string          `json:"user"`
+	Source string          `json:"source"`
+	Record string          `json:"record"` // Ключ записи: TransactionRecord, WorkEntryRecord, AccountRecord...
+	Action string          `json:"action"`
+	Old    json.RawMessage `json:"old,omitempty"`
+	New    json.RawMessage `json:"new,omitempty"`
+}
+
+// TransactionRecord возвращает ключ операции в журнале
+func TransactionRecord(id int) string {
+	return fmt.Sprintf("transaction/%d", id)
+}
+
+// WorkEntryRecord возвращает ключ записи табеля в журнале
+func WorkEntryRecord(date string) string {
+	return "work/" + date
+}
+
+// Ключи справочников и настроек в журнале
+const (
+	BaseCurrencyRecord = "base_currency"
+	WorkRulesRecord    = "rules"
+)
+
+// AccountRecord возвращает ключ счёта в журнале
+func AccountRecord(id int) string {
+	return fmt.Sprintf("account/%d", id)
+}
+
+// CategoryRecord возвращает ключ категории в журнале
+func CategoryRecord(id int) string {
+	return fmt.Sprintf("category/%d", id)
+}
+
+// BudgetRecord возвращает ключ бюджета в журнале
+func BudgetRecord(id int) string {
+	return fmt.Sprintf("budget/%d", id)
+}
+
+// RecurringRecord возвращает ключ регулярной операции в журнале
+func RecurringRecord(id int) string {
+	return fmt.Sprintf("recurring/%d", id)
+}
+
+// RateRecord возвращает ключ курса валюты на дату в журнале
+func RateRecord(currency, date string) string {
+	return fmt.Sprintf("rate/%s/%s", currency, date)
+}
+
+// AuditKinds — виды записей журнала: первая часть ключа
+var AuditKinds = []string{"transaction", "work", "account", "category", "budget", "recurring", "rate", BaseCurrencyRecord, WorkRulesRecord}
+
+// AuditFilter отбирает записи журнала. Пустые поля не ограничивают выборку.
+type AuditFilter struct {
+	Record string // Ключ записи целиком
+	Kind   string // Один из AuditKinds
+	Source string
+	Action string
+}
+
+func (f AuditFilter) match(e AuditEntry) bool {
+	return (f.Record == "" || e.Record == f.Record) &&
+		(f.Kind == "" || e.Record == f.Kind || strings.HasPrefix(e.Record, f.Kind+"/")) &&
+		(f.Source == "" || e.Source == f.Source) &&
+		(f.Action == "" || e.Action == f.Action)
+}
+
+// AuditLog — файл журнала изменений. Записи держатся в памяти для выборок.
+type AuditLog struct {
+	path    string
+	file    *os.File
+	entries []AuditEntry
+	mutex   sync.Mutex
+}
+
+// OpenAuditLog читает существующий журнал и открывает его для дописывания.
+// Оборванная последняя строка после сбоя отрезается, чтобы следующая запись
+// не склеилась с ней.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	a := &AuditLog{path: path, entries: []AuditEntry{}}
+
+	if fileData, err := os.ReadFile(path); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(fileData))
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		line := 0
+		for scanner.Scan() {
+			line++
+			var entry AuditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				// Оборванная последняя строка после сбоя не мешает работе
+				fmt.Printf("!!! ВНИМАНИЕ: Журнал изменений %s, строка %d повреждена: %v\n", path, line, err)
+				continue
+			}
+			a.entries = append(a.entries, entry)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("ошибка чтения журнала изменений: %v", err)
+		}
+		if len(fileData) > 0 && fileData[len(fileData)-1] != '\n' {
+			if err := os.Truncate(path, int64(bytes.LastIndexByte(fileData, '\n')+1)); err != nil {
+				return nil, fmt.Errorf("ошибка при отрезании оборванной строки журнала изменений: %v", err)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("ошибка чтения журнала изменений: %v", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия журнала изменений: %v", err)
+	}
+	a.file = file
+	fmt.Printf("Загружен журнал изменений: %d записей\n", len(a.entries))
+	return a, nil
+}
+
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.file.Close()
+}
+
+// append дописывает записи в журнал одним блоком. Ошибки только выводятся:
+// данные к этому моменту уже сохранены.
+func (a *AuditLog) append(actor Actor, entries []AuditEntry) {
+	if a == nil || len(entries) == 0 {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now()
+	nextID := 1
+	if len(a.entries) > 0 {
+		nextID = a.entries[len(a.entries)-1].ID + 1
+	}
+	var buf []byte
+	for i := range entries {
+		entries[i].ID = nextID + i
+		entries[i].Time = now
+		entries[i].User = actor.User
+		entries[i].Source = actor.Source
+		line, err := json.Marshal(entries[i])
+		if err != nil {
+			fmt.Println("Ошибка записи журнала изменений:", err)
+			return
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	if _, err := a.file.Write(buf); err != nil {
+		fmt.Println("!!! ВНИМАНИЕ: Ошибка записи журнала изменений:", err)
+		return
+	}
+	if err := a.file.Sync(); err != nil {
+		fmt.Println("!!! ВНИМАНИЕ: Ошибка записи журнала изменений:", err)
+	}
+	a.entries = append(a.entries, entries...)
+}
+
+// Query возвращает записи журнала, подходящие под filter, от новых к старым,
+// начиная с offset, не больше limit (0 — без ограничения), и общее число
+// подходящих записей
+func (a *AuditLog) Query(filter AuditFilter, offset, limit int) ([]AuditEntry, int) {
+	if a == nil {
+		return []AuditEntry{}, 0
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	result := []AuditEntry{}
+	total := 0
+	for i := len(a.entries) - 1; i >= 0; i-- {
+		if !filter.match(a.entries[i]) {
+			continue
+		}
+		if total >= offset && (limit == 0 || len(result) < limit) {
+			result = append(result, a.entries[i])
+		}
+		total++
+	}
+	return result, total
+}
+
+// auditState — состояние записи: активна или в корзине
+type auditState struct {
+	value   interface{}
+	trashed bool
+}
+
+// auditChanges сравнивает состояния записей до и после изменения и
+// возвращает записи журнала. same сообщает, что значение записи не изменилось.
+func auditChanges(keys []string, before, after map[string]auditState, same func(a, b interface{}) bool) []AuditEntry {
+	result := []AuditEntry{}
+	for _, key := range keys {
+		old, hadOld := before[key]
+		cur, hasCur := after[key]
+
+		var action string
+		switch {
+		case !hadOld && hasCur && !cur.trashed:
+			action = AuditCreate
+		case !hadOld && hasCur:
+			action = AuditDelete
+		case hadOld && !hasCur:
+			action = AuditPurge
+		case old.trashed && !cur.trashed:
+			action = AuditRestore
+		case !old.trashed && cur.trashed:
+			action = AuditDelete
+		case !same(old.value, cur.value):
+			action = AuditUpdate
+		default:
+			continue
+		}
+
+		entry := AuditEntry{Record: key, Action: action}
+		if hadOld {
+			entry.Old, _ = json.Marshal(old.value)
+		}
+		if hasCur {
+			entry.New, _ = json.Marshal(cur.value)
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// auditKeys возвращает ключи обоих состояний: сначала в порядке before, затем
+// новые в порядке after
+func auditKeys(beforeOrder, afterOrder []string) []string {
+	seen := make(map[string]bool, len(beforeOrder))
+	keys := make([]string, 0, len(beforeOrder))
+	for _, list := range [][]string{beforeOrder, afterOrder} {
+		for _, key := range list {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+func transactionStates(data *models.FinanceData) (map[string]auditState, []string) {
+	states := make(map[string]auditState, len(data.Transactions)+len(data.Trash))
+	order := make([]string, 0, len(data.Transactions)+len(data.Trash))
+	for _, d := range data.Trash {
+		key := TransactionRecord(d.ID)
+		states[key] = auditState{value: d.Transaction, trashed: true}
+		order = append(order, key)
+	}
+	for _, t := range data.Transactions {
+		key := TransactionRecord(t.ID)
+		states[key] = auditState{value: t}
+		order = append(order, key)
+	}
+	return states, order
+}
+
+func workEntryStates(data *models.WorkLogData) (map[string]auditState, []string) {
+	states := make(map[string]auditState, len(data.Entries)+len(data.Trash))
+	order := make([]string, 0, len(data.Entries)+len(data.Trash))
+	// Удалённых записей за одну дату может быть несколько: учитывается
+	// последняя, а активная запись важнее удалённых
+	deletedAt := make(map[string]time.Time, len(data.Trash))
+	for _, d := range data.Trash {
+		key := WorkEntryRecord(d.Date)
+		if last, ok := deletedAt[key]; ok && last.After(d.DeletedAt) {
+			continue
+		}
+		deletedAt[key] = d.DeletedAt
+		states[key] = auditState{value: d.WorkEntry, trashed: true}
+		order = append(order, key)
+	}
+	for _, entry := range data.Entries {
+		key := WorkEntryRecord(entry.Date)
+		states[key] = auditState{value: entry}
+		order = append(order, key)
+	}
+	return states, order
+}
+
+// financeSettingStates возвращает состояния справочников и настроек финансов.
+// Базовая валюта есть всегда, поэтому её смена записывается как изменение.
+func financeSettingStates(data *models.FinanceData) (map[string]auditState, []string) {
+	states := make(map[string]auditState)
+	order := []string{}
+	add := func(key string, value interface{}) {
+		states[key] = auditState{value: value}
+		order = append(order, key)
+	}
+	for _, a := range data.Accounts {
+		add(AccountRecord(a.ID), a)
+	}
+	for _, c := range data.Categories {
+		add(CategoryRecord(c.ID), c)
+	}
+	for _, b := range data.Budgets {
+		add(BudgetRecord(b.ID), b)
+	}
+	for _, r := range data.Recurring {
+		add(RecurringRecord(r.ID), r)
+	}
+	for _, r := range data.Rates {
+		add(RateRecord(r.Currency, r.Date), r)
+	}
+	add(BaseCurrencyRecord, struct{ BaseCurrency string }{data.BaseCurrency})
+	return states, order
+}
+
+// workRulesState возвращает состояние правил учёта времени. Пока правила не
+// заданы, действуют DefaultWorkRules: так переход к своим правилам и сброс
+// записываются как изменения.
+func workRulesState(data *models.WorkLogData) (map[string]auditState, []string) {
+	rules := DefaultWorkRules
+	if data.Rules != nil {
+		rules = *data.Rules
+	}
+	return map[string]auditState{WorkRulesRecord: {value: rules}}, []string{WorkRulesRecord}
+}
+
+// recordFinance записывает в журнал изменения операций, справочников и настроек
+func (a *AuditLog) recordFinance(actor Actor, before, after *models.FinanceData) {
+	if a == nil {
+		return
+	}
+	oldStates, oldOrder := transactionStates(before)
+	newStates, newOrder := transactionStates(after)
+	entries := auditChanges(auditKeys(oldOrder, newOrder), oldStates, newStates, func(x, y interface{}) bool {
+		return x.(models.Transaction) == y.(models.Transaction)
+	})
+	oldStates, oldOrder = financeSettingStates(before)
+	newStates, newOrder = financeSettingStates(after)
+	entries = append(entries, auditChanges(auditKeys(oldOrder, newOrder), oldStates, newStates, sameJSON)...)
+	a.append(actor, entries)
+}
+
+// recordWorkLog записывает в журнал изменения записей табеля и правил
+func (a *AuditLog) recordWorkLog(actor Actor, before, after *models.WorkLogData) {
+	if a == nil {
+		return
+	}
+	oldStates, oldOrder := workEntryStates(before)
+	newStates, newOrder := workEntryStates(after)
+	entries := auditChanges(auditKeys(oldOrder, newOrder), oldStates, newStates, sameJSON)
+	oldStates, oldOrder = workRulesState(before)
+	newStates, newOrder = workRulesState(after)
+	entries = append(entries, auditChanges(auditKeys(oldOrder, newOrder), oldStates, newStates, sameJSON)...)
+	a.append(actor, entries)
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"finance-tracker/models"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Изменения справочников и настроек попадают в журнал так же, как изменения
+// операций и записей табеля
+func TestAuditRecordsSettings(t *testing.T) {
+	dir := t.TempDir()
+	audit, err := OpenAuditLog(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatalf("OpenAuditLog: %v", err)
+	}
+	defer audit.Close()
+
+	finance := loadTestStorage(t, filepath.Join(dir, "finance_data.json"))
+	finance.SetAuditLog(audit)
+	actor := Actor{User: "test", Source: SourceWeb}
+	err = finance.Update(actor, func(data *models.FinanceData) error {
+		data.Accounts = append(data.Accounts, models.Account{ID: 7, Name: "Карта", Currency: NationalCurrency, Type: "card"})
+		data.BaseCurrency = "USD"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	err = finance.Update(actor, func(data *models.FinanceData) error {
+		for i := range data.Accounts {
+			if data.Accounts[i].ID == 7 {
+				data.Accounts[i].Archived = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	worklog := NewWorkLogStorage(filepath.Join(dir, "worklog_data.json"))
+	if err := worklog.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	worklog.SetAuditLog(audit)
+	rules := DefaultWorkRules
+	rules.LunchMinutes = 30
+	if err := worklog.SetRules(actor, rules); err != nil {
+		t.Fatalf("SetRules: %v", err)
+	}
+
+	tests := []struct {
+		record  string
+		actions []string // От новых к старым
+	}{
+		{AccountRecord(7), []string{AuditUpdate, AuditCreate}},
+		{BaseCurrencyRecord, []string{AuditUpdate}},
+		{WorkRulesRecord, []string{AuditUpdate}},
+	}
+	for _, tt := range tests {
+		entries, _ := audit.Query(AuditFilter{Record: tt.record}, 0, 0)
+		if len(entries) != len(tt.actions) {
+			t.Errorf("%s: записей в журнале %d, ожидалось %d", tt.record, len(entries), len(tt.actions))
+			continue
+		}
+		for i, action := range tt.actions {
+			if entries[i].Action != action {
+				t.Errorf("%s: действие %q, ожидалось %q", tt.record, entries[i].Action, action)
+			}
+		}
+	}
+	if _, total := audit.Query(AuditFilter{Kind: WorkRulesRecord}, 0, 0); total != 1 {
+		t.Errorf("фильтр по виду %q: записей %d, ожидалась 1", WorkRulesRecord, total)
+	}
+}
+
+// Сбой посреди записи оставляет в журнале изменений оборванную строку. Записи,
+// сделанные после перезапуска, не должны склеиваться с ней и теряться при
+// следующей загрузке.
+func TestAuditTornLineSurvivesSecondCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("OpenAuditLog: %v", err)
+	}
+	audit.append(Actor{User: "test"}, []AuditEntry{{Record: TransactionRecord(1), Action: AuditCreate}})
+	audit.Close()
+
+	// Первый сбой: от записи на диск попала только часть строки
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"id":2,"time":"2025-01-01T00:00:00Z","rec`)
+	file.Close()
+
+	audit, err = OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("OpenAuditLog: %v", err)
+	}
+	audit.append(Actor{User: "test"}, []AuditEntry{{Record: TransactionRecord(2), Action: AuditCreate}})
+	audit.append(Actor{User: "test"}, []AuditEntry{{Record: TransactionRecord(3), Action: AuditCreate}})
+
+	// Второй сбой: журнал не закрывается
+	audit, err = OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("OpenAuditLog: %v", err)
+	}
+	defer audit.Close()
+	if _, total := audit.Query(AuditFilter{}, 0, 0); total != 3 {
+		t.Fatalf("после второго сбоя записей в журнале: %d, ожидалось 3", total)
+	}
+}
@@ -0,0 +1,392 @@
+package storage
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Резервные копии общие для финансов и табеля и не зависят от бэкенда.
+// Копия — сжатый gzip JSON с полными данными хранилища. Сведения о копии
+// (хранилище, причина, число записей) лежат в заголовке gzip, поэтому список
+// строится без распаковки архивов.
+//
+// Автоматическая копия создаётся при загрузке и при сохранении, если
+// предыдущая старше часа. Хранение автоматических копий многоуровневое: по
+// последней копии за каждый из Hourly последних часов, Daily последних дней и
+// Weekly последних недель; остальные удаляются. Ручные и страховочные копии
+// хранятся Weekly недель независимо от их числа.
+
+// Хранилища, для которых создаются копии
+const (
+	BackupFinance = "finance"
+	BackupWorkLog = "worklog"
+)
+
+// Причины создания копии
+const (
+	BackupAuto       = "auto"
+	BackupManual     = "manual"
+	BackupPreRestore = "pre-restore"
+	BackupPreDelete  = "pre-delete"
+)
+
+const (
+	backupInterval   = time.Hour
+	backupTimeLayout = "20060102T150405.000000000"
+	backupExt        = ".json.gz"
+)
+
+// ErrBackupsDisabled возвращается, если хранилищу не назначены резервные копии
+var ErrBackupsDisabled = errors.New("резервное копирование не настроено")
+
+// BackupPolicy задаёт, сколько часов, дней и недель покрывают копии
+type BackupPolicy struct {
+	Hourly int
+	Daily  int
+	Weekly int
+}
+
+// DefaultBackupPolicy — сутки почасовых копий, неделя ежедневных и два месяца
+// еженедельных
+var DefaultBackupPolicy = BackupPolicy{Hourly: 24, Daily: 7, Weekly: 8}
+
+// BackupInfo описывает одну резервную копию
+type BackupInfo struct {
+	Name    string // Имя файла в каталоге копий
+	Store   string // BackupFinance или BackupWorkLog
+	Created time.Time
+	Reason  string
+	Records int // Число операций или записей табеля
+	Size    int64
+}
+
+// backupMeta хранится в комментарии заголовка gzip
+type backupMeta struct {
+	Store   string `json:"store"`
+	Reason  string `json:"reason"`
+	Records int    `json:"records"`
+}
+
+// Backups управляет каталогом резервных копий
+type Backups struct {
+	dir    string
+	policy BackupPolicy
+	mutex  sync.Mutex
+	last   map[string]time.Time // время последней копии каждого хранилища
+}
+
+func NewBackups(dir string, policy BackupPolicy) *Backups {
+	return &Backups{dir: dir, policy: policy, last: make(map[string]time.Time)}
+}
+
+// backupFallback реализуют бэкенды, которые при повреждении данных
+// восстанавливаются из резервной копии
+type backupFallback interface {
+	setBackupFallback(fallback func(v interface{}) (BackupInfo, error))
+}
+
+// createIfDue создаёт автоматическую копию, если последняя копия хранилища
+// старше backupInterval. Ошибки только выводятся: сбой копирования не должен
+// мешать сохранению данных.
+func (b *Backups) createIfDue(store string, v interface{}, records int) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	last, ok := b.last[store]
+	if !ok {
+		if list, err := b.list(store); err == nil && len(list) > 0 {
+			last = list[0].Created
+		}
+		b.last[store] = last
+	}
+	b.mutex.Unlock()
+
+	if time.Since(last) < backupInterval {
+		return
+	}
+	if _, err := b.Create(store, v, records, BackupAuto); err != nil {
+		fmt.Println("Ошибка создания резервной копии:", err)
+	}
+}
+
+// Create сохраняет копию данных v хранилища store и удаляет копии, которые
+// больше не нужны по политике хранения
+func (b *Backups) Create(store string, v interface{}, records int, reason string) (BackupInfo, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return BackupInfo{}, fmt.Errorf("ошибка создания каталога резервных копий: %v", err)
+	}
+
+	fileData, err := json.Marshal(v)
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("ошибка при кодировании в JSON: %v", err)
+	}
+	meta, err := json.Marshal(backupMeta{Store: store, Reason: reason, Records: records})
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("ошибка при кодировании в JSON: %v", err)
+	}
+
+	created := time.Now()
+	name := fmt.Sprintf("%s_%s_%s%s", store, created.UTC().Format(backupTimeLayout), reason, backupExt)
+	path := filepath.Join(b.dir, name)
+
+	tmp, err := os.CreateTemp(b.dir, name+".tmp-*")
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("ошибка создания резервной копии: %v", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	zw := gzip.NewWriter(tmp)
+	zw.Name = store + ".json"
+	zw.Comment = string(meta)
+	zw.ModTime = created
+	if _, err := zw.Write(fileData); err != nil {
+		tmp.Close()
+		return BackupInfo{}, fmt.Errorf("ошибка записи резервной копии: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return BackupInfo{}, fmt.Errorf("ошибка записи резервной копии: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return BackupInfo{}, fmt.Errorf("ошибка записи резервной копии: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return BackupInfo{}, fmt.Errorf("ошибка записи резервной копии: %v", err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return BackupInfo{}, fmt.Errorf("ошибка записи резервной копии: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return BackupInfo{}, fmt.Errorf("ошибка записи резервной копии: %v", err)
+	}
+	syncDir(b.dir)
+
+	info, err := readBackupInfo(path)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	b.last[store] = info.Created
+	fmt.Println("Резервная копия создана:", path)
+
+	if err := b.prune(store); err != nil {
+		fmt.Println("Ошибка удаления старых резервных копий:", err)
+	}
+	return info, nil
+}
+
+// List возвращает копии хранилища store от новых к старым. Повреждённые
+// архивы пропускаются.
+func (b *Backups) List(store string) ([]BackupInfo, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.list(store)
+}
+
+func (b *Backups) list(store string) ([]BackupInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(b.dir, store+"_*"+backupExt))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения списка резервных копий: %v", err)
+	}
+	result := []BackupInfo{}
+	for _, path := range paths {
+		info, err := readBackupInfo(path)
+		if err != nil || info.Store != store {
+			fmt.Println("Резервная копия повреждена, пропускаем:", path)
+			continue
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.After(result[j].Created)
+	})
+	return result, nil
+}
+
+// Read распаковывает копию name хранилища store в v
+func (b *Backups) Read(store, name string, v interface{}) (BackupInfo, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// Имя приходит из запроса: допускаются только файлы каталога копий
+	if name != filepath.Base(name) || !strings.HasPrefix(name, store+"_") || !strings.HasSuffix(name, backupExt) {
+		return BackupInfo{}, ErrNotFound
+	}
+	path := filepath.Join(b.dir, name)
+	info, err := readBackupInfo(path)
+	if os.IsNotExist(err) {
+		return BackupInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	if info.Store != store {
+		return BackupInfo{}, ErrNotFound
+	}
+	if err := readBackupData(path, v); err != nil {
+		return BackupInfo{}, err
+	}
+	return info, nil
+}
+
+// ReadNewest распаковывает в v самую свежую целую копию хранилища store
+func (b *Backups) ReadNewest(store string, v interface{}) (BackupInfo, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	list, err := b.list(store)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	for _, info := range list {
+		if err := readBackupData(filepath.Join(b.dir, info.Name), v); err != nil {
+			fmt.Println("Резервная копия повреждена, пропускаем:", info.Name)
+			continue
+		}
+		return info, nil
+	}
+	return BackupInfo{}, fmt.Errorf("резервные копии не найдены")
+}
+
+// prune удаляет копии, не попавшие ни в один уровень политики хранения
+func (b *Backups) prune(store string) error {
+	list, err := b.list(store)
+	if err != nil {
+		return err
+	}
+	keep := retainedBackups(list, b.policy, time.Now())
+	for _, info := range list {
+		if keep[info.Name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(b.dir, info.Name)); err != nil {
+			return err
+		}
+		fmt.Println("Удалена устаревшая резервная копия:", info.Name)
+	}
+	return nil
+}
+
+// retainedBackups отбирает автоматические копии по уровням: в каждом часе,
+// дне и неделе остаётся самая свежая копия, пока не набрано нужное число
+// интервалов. Остальные копии хранятся Weekly недель. Самая свежая копия
+// сохраняется всегда. list упорядочен от новых к старым.
+func retainedBackups(list []BackupInfo, policy BackupPolicy, now time.Time) map[string]bool {
+	keep := make(map[string]bool)
+	if len(list) > 0 {
+		keep[list[0].Name] = true
+	}
+
+	expires := now.AddDate(0, 0, -7*policy.Weekly)
+	auto := []BackupInfo{}
+	for _, info := range list {
+		if info.Reason == BackupAuto {
+			auto = append(auto, info)
+		} else if info.Created.After(expires) {
+			keep[info.Name] = true
+		}
+	}
+
+	tiers := []struct {
+		limit int
+		key   func(t time.Time) string
+	}{
+		{policy.Hourly, func(t time.Time) string { return t.Format("2006-01-02 15") }},
+		{policy.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{policy.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+	}
+	for _, tier := range tiers {
+		seen := make(map[string]bool)
+		for _, info := range auto {
+			if len(seen) >= tier.limit {
+				break
+			}
+			key := tier.key(info.Created.Local())
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			keep[info.Name] = true
+		}
+	}
+	return keep
+}
+
+// readBackupInfo читает сведения о копии из заголовка gzip
+func readBackupInfo(path string) (BackupInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("ошибка чтения резервной копии: %v", err)
+	}
+	defer zr.Close()
+
+	var meta backupMeta
+	if err := json.Unmarshal([]byte(zr.Comment), &meta); err != nil {
+		return BackupInfo{}, fmt.Errorf("ошибка чтения резервной копии: %v", err)
+	}
+
+	name := filepath.Base(path)
+	parts := strings.SplitN(strings.TrimSuffix(name, backupExt), "_", 3)
+	created := zr.ModTime
+	if len(parts) == 3 {
+		if t, err := time.Parse(backupTimeLayout, parts[1]); err == nil {
+			created = t
+		}
+	}
+
+	return BackupInfo{
+		Name:    name,
+		Store:   meta.Store,
+		Created: created.Local(),
+		Reason:  meta.Reason,
+		Records: meta.Records,
+		Size:    stat.Size(),
+	}, nil
+}
+
+// readBackupData распаковывает и декодирует данные копии
+func readBackupData(path string, v interface{}) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения резервной копии: %v", err)
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения резервной копии: %v", err)
+	}
+	defer zr.Close()
+
+	if err := json.NewDecoder(zr).Decode(v); err != nil {
+		return fmt.Errorf("ошибка при декодировании резервной копии: %v", err)
+	}
+	return nil
+}
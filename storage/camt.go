@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"encoding/xml"
+	"finance-tracker/models"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CAMT.053 — выписка по счёту в формате ISO 20022. Теги без пространства
+// имён сопоставляются с элементами любой версии схемы (camt.053.001.02 и
+// новее).
+
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	IBAN     string      `xml:"Acct>Id>IBAN"`
+	Other    string      `xml:"Acct>Id>Othr>Id"`
+	Currency string      `xml:"Acct>Ccy"`
+	Entries  []camtEntry `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// camtStatus — статус записи: текст в camt.053.001.02, код Cd в .08 и новее
+type camtStatus struct {
+	Value string `xml:",chardata"`
+	Code  string `xml:"Cd"`
+}
+
+type camtEntry struct {
+	Ref        string          `xml:"NtryRef"`
+	Amount     camtAmount      `xml:"Amt"`
+	Direction  string          `xml:"CdtDbtInd"` // CRDT — поступление, DBIT — списание
+	Status     camtStatus      `xml:"Sts"`
+	BookDate   string          `xml:"BookgDt>Dt"`
+	BookTime   string          `xml:"BookgDt>DtTm"`
+	ValueDate  string          `xml:"ValDt>Dt"`
+	ServiceRef string          `xml:"AcctSvcrRef"`
+	Info       string          `xml:"AddtlNtryInf"`
+	Details    []camtTxDetails `xml:"NtryDtls>TxDtls"`
+}
+
+type camtTxDetails struct {
+	ServiceRef  string     `xml:"Refs>AcctSvcrRef"`
+	TxID        string     `xml:"Refs>TxId"`
+	EndToEndID  string     `xml:"Refs>EndToEndId"`
+	Amount      camtAmount `xml:"Amt"`
+	Creditor    string     `xml:"RltdPties>Cdtr>Nm"`
+	CreditorPty string     `xml:"RltdPties>Cdtr>Pty>Nm"`
+	Debtor      string     `xml:"RltdPties>Dbtr>Nm"`
+	DebtorPty   string     `xml:"RltdPties>Dbtr>Pty>Nm"`
+	Remittance  []string   `xml:"RmtInf>Ustrd"`
+	Info        string     `xml:"AddtlTxInf"`
+}
+
+// parseCAMTDate разбирает дату или дату со временем ISO 8601
+func parseCAMTDate(date, dateTime string) (time.Time, error) {
+	if dateTime != "" {
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05"} {
+			if t, err := time.ParseInLocation(layout, dateTime, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("неверная дата %q", dateTime)
+	}
+	t, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("неверная дата %q", date)
+	}
+	return t, nil
+}
+
+// parseCAMT разбирает выписку CAMT.053. Запись с несколькими операциями
+// (пакетный платёж) даёт по строке на каждую операцию, если у них указаны
+// суммы. Непроведённые записи не импортируются.
+func parseCAMT(body []byte) (Statement, error) {
+	var doc camtDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return Statement{}, fmt.Errorf("ошибка при разборе XML: %v", err)
+	}
+	if len(doc.Statements) == 0 {
+		return Statement{}, fmt.Errorf("в файле нет выписки CAMT.053")
+	}
+
+	first := doc.Statements[0]
+	statement := Statement{
+		Account:  first.IBAN,
+		Currency: strings.ToUpper(first.Currency),
+		Rows:     []ImportRow{},
+	}
+	if statement.Account == "" {
+		statement.Account = first.Other
+	}
+
+	number := 0
+	for _, stmt := range doc.Statements {
+		for _, entry := range stmt.Entries {
+			rows := camtEntryRows(&number, entry)
+			statement.Rows = append(statement.Rows, rows...)
+		}
+	}
+	if number == 0 {
+		return Statement{}, fmt.Errorf("в выписке нет операций")
+	}
+	return statement, nil
+}
+
+// camtEntryRows превращает запись выписки в строки импорта
+func camtEntryRows(number *int, entry camtEntry) []ImportRow {
+	status := strings.TrimSpace(entry.Status.Code)
+	if status == "" {
+		status = strings.TrimSpace(entry.Status.Value)
+	}
+
+	*number++
+	if status != "" && status != "BOOK" {
+		return []ImportRow{invalidImportRow(*number, "операция не проведена банком (статус %s)", status)}
+	}
+	dateTime, err := parseCAMTDate(entry.BookDate, entry.BookTime)
+	if entry.BookDate == "" && entry.BookTime == "" {
+		dateTime, err = parseCAMTDate(entry.ValueDate, "")
+	}
+	if err != nil {
+		return []ImportRow{invalidImportRow(*number, "%v", err)}
+	}
+
+	sign := 1.0
+	if entry.Direction == "DBIT" {
+		sign = -1
+	}
+	entryRef := entry.ServiceRef
+	if entryRef == "" {
+		entryRef = entry.Ref
+	}
+
+	// Пакетный платёж с суммами по операциям раскладывается на строки
+	split := len(entry.Details) > 1
+	for _, d := range entry.Details {
+		if strings.TrimSpace(d.Amount.Value) == "" {
+			split = false
+		}
+	}
+	if !split {
+		var details camtTxDetails
+		if len(entry.Details) > 0 {
+			details = entry.Details[0]
+		}
+		if entryRef == "" {
+			entryRef = details.ref()
+		}
+		return []ImportRow{camtRow(*number, entry.Amount, sign, dateTime, entryRef, details.description(entry.Direction, entry.Info))}
+	}
+
+	rows := []ImportRow{}
+	for i, d := range entry.Details {
+		if i > 0 {
+			*number++
+		}
+		ref := d.ref()
+		if ref == "" && entryRef != "" {
+			ref = entryRef + "/" + strconv.Itoa(i+1)
+		}
+		rows = append(rows, camtRow(*number, d.Amount, sign, dateTime, ref, d.description(entry.Direction, entry.Info)))
+	}
+	return rows
+}
+
+// ref возвращает ID операции в банке
+func (d camtTxDetails) ref() string {
+	for _, ref := range []string{d.ServiceRef, d.TxID, d.EndToEndID} {
+		if ref != "" && ref != "NOTPROVIDED" {
+			return ref
+		}
+	}
+	return ""
+}
+
+// description составляет описание операции: контрагент и назначение платежа
+func (d camtTxDetails) description(direction, entryInfo string) string {
+	party := d.Creditor + d.CreditorPty
+	if direction == "CRDT" {
+		party = d.Debtor + d.DebtorPty
+	}
+	parts := []string{}
+	if party != "" {
+		parts = append(parts, party)
+	}
+	if purpose := strings.TrimSpace(strings.Join(d.Remittance, " ")); purpose != "" {
+		parts = append(parts, purpose)
+	}
+	if len(parts) == 0 {
+		for _, info := range []string{d.Info, entryInfo} {
+			if info != "" {
+				parts = append(parts, info)
+				break
+			}
+		}
+	}
+	return strings.Join(parts, " — ")
+}
+
+func camtRow(number int, amount camtAmount, sign float64, dateTime time.Time, ref, description string) ImportRow {
+	value, err := strconv.ParseFloat(strings.TrimSpace(amount.Value), 64)
+	if err != nil {
+		return invalidImportRow(number, "неверная сумма %q", amount.Value)
+	}
+	return statementRow(number, models.Transaction{
+		Description: description,
+		DateTime:    dateTime,
+		Currency:    strings.ToUpper(amount.Currency),
+		ExternalID:  ref,
+	}, sign*value)
+}
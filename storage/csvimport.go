@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"finance-tracker/models"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Знак суммы в CSV
+const (
+	SignSigned   = "signed"   // Отрицательная сумма — расход, положительная — доход
+	SignInverted = "inverted" // Положительная сумма — расход (выписки по кредитным картам)
+	SignExpense  = "expense"  // Все строки — расходы, знак не учитывается
+)
+
+// ValidSign сообщает, поддерживается ли способ задания знака суммы
+func ValidSign(sign string) bool {
+	switch sign {
+	case SignSigned, SignInverted, SignExpense:
+		return true
+	}
+	return false
+}
+
+// CSVDateFormat — формат даты в CSV
+type CSVDateFormat struct {
+	Layout string
+	Title  string
+}
+
+// CSVDateFormats — поддерживаемые форматы дат. Пустой Layout при разборе
+// означает перебор всех форматов по порядку.
+var CSVDateFormats = []CSVDateFormat{
+	{"02.01.2006", "ДД.ММ.ГГГГ"},
+	{"02.01.2006 15:04", "ДД.ММ.ГГГГ чч:мм"},
+	{"02.01.2006 15:04:05", "ДД.ММ.ГГГГ чч:мм:сс"},
+	{"2006-01-02", "ГГГГ-ММ-ДД"},
+	{"2006-01-02 15:04", "ГГГГ-ММ-ДД чч:мм"},
+	{"2006-01-02 15:04:05", "ГГГГ-ММ-ДД чч:мм:сс"},
+	{"2006-01-02T15:04:05Z07:00", "ISO 8601 (RFC 3339)"},
+	{"02/01/2006", "ДД/ММ/ГГГГ"},
+	{"01/02/2006", "ММ/ДД/ГГГГ"},
+}
+
+// ValidCSVDateFormat сообщает, поддерживается ли формат даты. Пустой формат —
+// определение автоматически.
+func ValidCSVDateFormat(layout string) bool {
+	if layout == "" {
+		return true
+	}
+	for _, f := range CSVDateFormats {
+		if f.Layout == layout {
+			return true
+		}
+	}
+	return false
+}
+
+// CSVMapping — соответствие колонок CSV полям операции. Номера колонок
+// считаются с нуля, -1 — колонки нет.
+type CSVMapping struct {
+	Amount          int
+	Description     int
+	DateTime        int
+	Currency        int
+	Notes           int
+	HasHeader       bool   // Первая строка — заголовки колонок
+	Sign            string // SignSigned, SignInverted или SignExpense
+	DateFormat      string // Layout из CSVDateFormats или пусто для автоопределения
+	DefaultCurrency string // Валюта строк, если колонки валюты нет или ячейка пуста
+}
+
+// ReadCSV читает CSV-файл целиком. Разделитель (запятая, точка с запятой или
+// табуляция) определяется по первой строке; BOM в начале файла пропускается.
+func ReadCSV(r io.Reader) ([][]string, rune, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка при чтении файла: %v", err)
+	}
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+
+	firstLine := string(body)
+	if i := strings.IndexAny(firstLine, "\r\n"); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	comma, best := ',', 0
+	for _, candidate := range []rune{';', '\t', ','} {
+		if n := strings.Count(firstLine, string(candidate)); n > best {
+			comma, best = candidate, n
+		}
+	}
+
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка при разборе CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, 0, fmt.Errorf("файл пуст")
+	}
+	return records, comma, nil
+}
+
+// csvHeaderNames — типичные заголовки колонок в выгрузках банков и таблицах
+var csvHeaderNames = map[string][]string{
+	"amount":      {"сумма", "amount", "sum", "сумма операции", "сумма в валюте счета", "сумма в валюте счёта"},
+	"description": {"описание", "description", "назначение", "назначение платежа", "операция", "наименование", "payee", "memo"},
+	"datetime":    {"дата", "date", "дата операции", "дата и время", "datetime", "время"},
+	"currency":    {"валюта", "currency", "валюта операции", "валюта счета", "валюта счёта"},
+	"notes":       {"заметки", "notes", "примечание", "комментарий", "comment"},
+}
+
+// GuessCSVMapping подбирает соответствие колонок по заголовкам первой строки.
+// Если заголовки не распознаны, первая строка считается данными.
+func GuessCSVMapping(records [][]string) CSVMapping {
+	m := CSVMapping{Amount: -1, Description: -1, DateTime: -1, Currency: -1, Notes: -1, Sign: SignSigned}
+	if len(records) == 0 {
+		return m
+	}
+	columns := map[string]*int{
+		"amount":      &m.Amount,
+		"description": &m.Description,
+		"datetime":    &m.DateTime,
+		"currency":    &m.Currency,
+		"notes":       &m.Notes,
+	}
+	for i, header := range records[0] {
+		header = strings.ToLower(strings.TrimSpace(header))
+		for field, names := range csvHeaderNames {
+			if *columns[field] >= 0 {
+				continue
+			}
+			for _, name := range names {
+				if header == name {
+					*columns[field] = i
+					m.HasHeader = true
+				}
+			}
+		}
+	}
+	return m
+}
+
+// ParseAmount разбирает сумму в записи банков и таблиц: с пробелами между
+// разрядами, запятой или точкой в качестве десятичного разделителя, знаком
+// или в скобках для отрицательных значений
+func ParseAmount(value string) (float64, error) {
+	s := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == ',', r == '-', r == '+', r == '(', r == ')':
+			return r
+		}
+		return -1
+	}, value)
+
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = s[1 : len(s)-1]
+	}
+	// Десятичный разделитель — последний из встретившихся, остальные
+	// разделяют разряды
+	if lastComma, lastDot := strings.LastIndex(s, ","), strings.LastIndex(s, "."); lastComma > lastDot {
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.Replace(s, ",", ".", 1)
+	} else {
+		s = strings.ReplaceAll(s, ",", "")
+	}
+
+	amount, err := strconv.ParseFloat(s, 64)
+	if err != nil || s == "" {
+		return 0, fmt.Errorf("неверная сумма %q", value)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// parseCSVDate разбирает дату в формате layout или, если он пуст, в первом
+// подходящем из CSVDateFormats. Время без часового пояса считается местным.
+func parseCSVDate(value, layout string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if layout != "" {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+		return time.Time{}, fmt.Errorf("неверная дата %q", value)
+	}
+	for _, f := range CSVDateFormats {
+		if t, err := time.ParseInLocation(f.Layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("неверная дата %q", value)
+}
+
+// ParseCSVTransactions разбирает строки CSV в операции по соответствию
+// колонок. Ошибки разбора записываются в строки и не прерывают разбор.
+func ParseCSVTransactions(records [][]string, m CSVMapping) []ImportRow {
+	rows := []ImportRow{}
+	for i, record := range records {
+		if i == 0 && m.HasHeader {
+			continue
+		}
+		line := i + 1
+		if len(strings.TrimSpace(strings.Join(record, ""))) == 0 {
+			continue
+		}
+
+		cell := func(column int) string {
+			if column < 0 || column >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[column])
+		}
+
+		amount, err := ParseAmount(cell(m.Amount))
+		if err != nil {
+			rows = append(rows, invalidImportRow(line, "%v", err))
+			continue
+		}
+		if amount == 0 {
+			rows = append(rows, invalidImportRow(line, "нулевая сумма"))
+			continue
+		}
+		description := cell(m.Description)
+		if description == "" {
+			rows = append(rows, invalidImportRow(line, "пустое описание"))
+			continue
+		}
+		dateTime, err := parseCSVDate(cell(m.DateTime), m.DateFormat)
+		if err != nil {
+			rows = append(rows, invalidImportRow(line, "%v", err))
+			continue
+		}
+		currency := strings.ToUpper(cell(m.Currency))
+		if currency == "" {
+			currency = strings.ToUpper(m.DefaultCurrency)
+		}
+		if currency == "" {
+			rows = append(rows, invalidImportRow(line, "не указана валюта"))
+			continue
+		}
+
+		var isPositive bool
+		switch m.Sign {
+		case SignInverted:
+			isPositive = amount < 0
+		case SignExpense:
+			isPositive = false
+		default:
+			isPositive = amount > 0
+		}
+		if amount < 0 {
+			amount = -amount
+		}
+
+		rows = append(rows, ImportRow{
+			Line:   line,
+			Status: ImportReady,
+			Transaction: models.Transaction{
+				Amount:      amount,
+				Description: description,
+				DateTime:    dateTime,
+				IsPositive:  isPositive,
+				Currency:    currency,
+				Notes:       cell(m.Notes),
+			},
+		})
+	}
+	return rows
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"encoding/json"
+	"finance-tracker/models"
+	"sort"
+)
+
+// Сравнение резервной копии с текущими данными. Added — то, что появилось
+// после копии и пропадёт при восстановлении; Removed — то, что есть только в
+// копии и вернётся при восстановлении.
+
+// TransactionChange — операция, изменённая после создания копии
+type TransactionChange struct {
+	Backup  models.Transaction
+	Current models.Transaction
+}
+
+// FinanceDiff — различия финансовых данных
+type FinanceDiff struct {
+	Added    []models.Transaction
+	Removed  []models.Transaction
+	Changed  []TransactionChange
+	Sections []string // Справочники, которые отличаются: счета, категории и т.д.
+}
+
+// Empty сообщает, что копия совпадает с текущими данными
+func (d FinanceDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Sections) == 0
+}
+
+// WorkEntryChange — запись табеля, изменённая после создания копии
+type WorkEntryChange struct {
+	Backup  models.WorkEntry
+	Current models.WorkEntry
+}
+
+// WorkLogDiff — различия табеля
+type WorkLogDiff struct {
+	Added   []models.WorkEntry
+	Removed []models.WorkEntry
+	Changed []WorkEntryChange
+}
+
+// Empty сообщает, что копия совпадает с текущими данными
+func (d WorkLogDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffFinance сравнивает копию backup с текущими данными current. Операции
+// сопоставляются по ID.
+func DiffFinance(backup, current *models.FinanceData) FinanceDiff {
+	diff := FinanceDiff{}
+
+	old := make(map[int]models.Transaction, len(backup.Transactions))
+	for _, t := range backup.Transactions {
+		old[t.ID] = t
+	}
+	seen := make(map[int]bool, len(current.Transactions))
+	for _, t := range current.Transactions {
+		seen[t.ID] = true
+		prev, ok := old[t.ID]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, t)
+		case !sameJSON(prev, t):
+			diff.Changed = append(diff.Changed, TransactionChange{Backup: prev, Current: t})
+		}
+	}
+	for _, t := range backup.Transactions {
+		if !seen[t.ID] {
+			diff.Removed = append(diff.Removed, t)
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].DateTime.After(diff.Added[j].DateTime) })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].DateTime.After(diff.Removed[j].DateTime) })
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return diff.Changed[i].Current.DateTime.After(diff.Changed[j].Current.DateTime)
+	})
+
+	sections := []struct {
+		name           string
+		backup, actual interface{}
+	}{
+		{"Счета", backup.Accounts, current.Accounts},
+		{"Категории", backup.Categories, current.Categories},
+		{"Бюджеты", backup.Budgets, current.Budgets},
+		{"Регулярные операции", backup.Recurring, current.Recurring},
+		{"Курсы валют", backup.Rates, current.Rates},
+		{"Базовая валюта", backup.BaseCurrency, current.BaseCurrency},
+	}
+	for _, s := range sections {
+		if !sameJSON(s.backup, s.actual) {
+			diff.Sections = append(diff.Sections, s.name)
+		}
+	}
+	return diff
+}
+
+// DiffWorkLog сравнивает копию табеля с текущими данными. Записи
+// сопоставляются по дате.
+func DiffWorkLog(backup, current *models.WorkLogData) WorkLogDiff {
+	diff := WorkLogDiff{}
+
+	old := make(map[string]models.WorkEntry, len(backup.Entries))
+	for _, e := range backup.Entries {
+		old[e.Date] = e
+	}
+	seen := make(map[string]bool, len(current.Entries))
+	for _, e := range current.Entries {
+		seen[e.Date] = true
+		prev, ok := old[e.Date]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, e)
+		case !sameJSON(prev, e):
+			diff.Changed = append(diff.Changed, WorkEntryChange{Backup: prev, Current: e})
+		}
+	}
+	for _, e := range backup.Entries {
+		if !seen[e.Date] {
+			diff.Removed = append(diff.Removed, e)
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].Date > diff.Added[j].Date })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].Date > diff.Removed[j].Date })
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Current.Date > diff.Changed[j].Current.Date })
+	return diff
+}
+
+// sameJSON сравнивает значения по JSON-представлению, в котором они
+// хранятся. Пустой и отсутствующий список считаются одинаковыми.
+func sameJSON(a, b interface{}) bool {
+	rawA, errA := json.Marshal(a)
+	rawB, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	empty := func(raw []byte) bool { return string(raw) == "null" || string(raw) == "[]" }
+	return string(rawA) == string(rawB) || empty(rawA) && empty(rawB)
+}
@@ -2,141 +2,389 @@
 package storage
 
 import (
-	"encoding/json"
 	"finance-tracker/models"
 	"fmt"
-	"os"
-	"path/filepath"
-	"sort"
 	"sync"
 	"time"
 )
 
 type FinanceStorage struct {
-	data     models.FinanceData
-	filePath string
-	mutex    sync.Mutex
+	data    models.FinanceData
+	backend FinanceBackend
+	backups *Backups
+	undo    []transactionUndo
+	audit   *AuditLog
+	search  *searchIndex
+	mutex   sync.Mutex
 }
 
+// NewFinanceStorage создаёт хранилище поверх JSON-файла
 func NewFinanceStorage(filePath string) *FinanceStorage {
+	return NewFinanceStorageWithBackend(NewJSONFinanceBackend(filePath))
+}
+
+// NewFinanceStorageWithBackend создаёт хранилище поверх произвольного бэкенда
+func NewFinanceStorageWithBackend(backend FinanceBackend) *FinanceStorage {
 	return &FinanceStorage{
-		filePath: filePath,
+		backend: backend,
 		data: models.FinanceData{
-			Transactions: []models.Transaction{},
-			Balances:     make(map[string]float64),
+			Transactions:    []models.Transaction{},
+			Balances:        make(map[string]float64),
+			Accounts:        []models.Account{},
+			AccountBalances: make(map[int]float64),
+			Categories:      defaultCategories(),
+			Budgets:         []models.Budget{},
+			Recurring:       []models.RecurringTransaction{},
+			Rates:           []models.ExchangeRate{},
+			BaseCurrency:    NationalCurrency,
 		},
 	}
 }
 
+// defaultCategories возвращает стартовый набор категорий для нового хранилища
+func defaultCategories() []models.Category {
+	return []models.Category{
+		{ID: 1, Name: "Продукты", Icon: "🛒", IsIncome: false},
+		{ID: 2, Name: "Кафе и рестораны", Icon: "🍔", IsIncome: false},
+		{ID: 3, Name: "Транспорт и топливо", Icon: "⛽", IsIncome: false},
+		{ID: 4, Name: "Дом и связь", Icon: "🏠", IsIncome: false},
+		{ID: 5, Name: "Здоровье", Icon: "💊", IsIncome: false},
+		{ID: 6, Name: "Развлечения", Icon: "🎮", IsIncome: false},
+		{ID: 7, Name: "Семья", Icon: "👪", IsIncome: false},
+		{ID: 8, Name: "Зарплата", Icon: "💼", IsIncome: true},
+		{ID: 9, Name: "Подарки и переводы", Icon: "🎁", IsIncome: true},
+	}
+}
+
 func (s *FinanceStorage) Load() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	fmt.Println("Загрузка финансовых данных из файла:", s.filePath)
-
-	if _, err := os.Stat(s.filePath); os.IsNotExist(err) {
-		fmt.Println("Файл финансовых данных не существует, создаём новый")
-		return nil
-	}
+	fmt.Println("Загрузка финансовых данных:", s.backend.Describe())
 
-	fileData, err := os.ReadFile(s.filePath)
+	found, err := s.backend.LoadFinance(&s.data)
 	if err != nil {
-		return fmt.Errorf("ошибка при чтении файла: %v", err)
+		return err
 	}
-
-	if err := json.Unmarshal(fileData, &s.data); err != nil {
-		return fmt.Errorf("ошибка при декодировании JSON: %v", err)
+	if !found {
+		fmt.Println("Финансовые данные не найдены, создаём новые")
+		return nil
 	}
 
+	s.applyDefaults()
+	s.reindex()
+	s.backups.createIfDue(BackupFinance, &s.data, len(s.data.Transactions))
+
 	fmt.Printf("Загруженные транзакции: %d\n", len(s.data.Transactions))
 	return nil
 }
 
+// applyDefaults дополняет данные, сохранённые старыми версиями
+func (s *FinanceStorage) applyDefaults() {
+	// Старые файлы данных не содержат категорий
+	if s.data.Categories == nil {
+		s.data.Categories = defaultCategories()
+	}
+	if s.data.BaseCurrency == "" {
+		s.data.BaseCurrency = NationalCurrency
+	}
+	s.assignDefaultAccounts()
+}
+
 func (s *FinanceStorage) Save() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	return s.save()
+}
 
-	fmt.Println("Сохранение финансовых данных в файл:", s.filePath)
-
-	// Создаём резервную копию
-	s.createBackup()
+// save — то же, что Save, для вызова под мьютексом
+func (s *FinanceStorage) save() error {
+	fmt.Println("Сохранение финансовых данных:", s.backend.Describe())
 
-	fileData, err := json.MarshalIndent(s.data, "", "  ")
-	if err != nil {
-		return fmt.Errorf("ошибка при кодировании в JSON: %v", err)
-	}
-
-	if err := os.WriteFile(s.filePath, fileData, 0644); err != nil {
-		return fmt.Errorf("ошибка при записи в файл: %v", err)
+	if err := s.backend.SaveFinance(&s.data); err != nil {
+		return err
 	}
 
 	fmt.Println("Финансовые данные успешно сохранены")
+	s.backups.createIfDue(BackupFinance, &s.data, len(s.data.Transactions))
 	return nil
 }
 
-func (s *FinanceStorage) createBackup() {
-	const maxBackups = 10
-	backupDir := "backups"
-	if _, err := os.Stat(backupDir); os.IsNotExist(err) {
-		os.Mkdir(backupDir, 0755)
+// SetBackups включает резервное копирование. Вызывается до Load, чтобы
+// повреждённый файл данных можно было восстановить из копии.
+func (s *FinanceStorage) SetBackups(backups *Backups) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.backups = backups
+	if backend, ok := s.backend.(backupFallback); ok {
+		backend.setBackupFallback(func(v interface{}) (BackupInfo, error) {
+			return backups.ReadNewest(BackupFinance, v)
+		})
 	}
+}
 
-	if _, err := os.Stat(s.filePath); os.IsNotExist(err) {
-		return
+// ListBackups возвращает резервные копии финансовых данных от новых к старым
+func (s *FinanceStorage) ListBackups() ([]BackupInfo, error) {
+	if s.backups == nil {
+		return nil, ErrBackupsDisabled
 	}
+	return s.backups.List(BackupFinance)
+}
 
-	currentData, err := os.ReadFile(s.filePath)
-	if err != nil {
-		fmt.Println("Ошибка чтения файла для резервного копирования:", err)
-		return
+// CreateBackup создаёт резервную копию по запросу пользователя
+func (s *FinanceStorage) CreateBackup() (BackupInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.backups == nil {
+		return BackupInfo{}, ErrBackupsDisabled
 	}
+	return s.backups.Create(BackupFinance, &s.data, len(s.data.Transactions), BackupManual)
+}
 
-	backupFiles, err := filepath.Glob(filepath.Join(backupDir, "finance_data_backup_*.json"))
+// DiffBackup сравнивает резервную копию name с текущими данными
+func (s *FinanceStorage) DiffBackup(name string) (BackupInfo, FinanceDiff, error) {
+	if s.backups == nil {
+		return BackupInfo{}, FinanceDiff{}, ErrBackupsDisabled
+	}
+	var backup models.FinanceData
+	info, err := s.backups.Read(BackupFinance, name, &backup)
 	if err != nil {
-		fmt.Println("Ошибка получения списка резервных копий:", err)
-		return
+		return BackupInfo{}, FinanceDiff{}, err
 	}
+	return info, DiffFinance(&backup, s.Snapshot()), nil
+}
 
-	sort.Slice(backupFiles, func(i, j int) bool {
-		infoI, _ := os.Stat(backupFiles[i])
-		infoJ, _ := os.Stat(backupFiles[j])
-		return infoI.ModTime().Before(infoJ.ModTime())
-	})
+// RestoreBackup заменяет данные резервной копией name. Перед заменой текущие
+// данные сохраняются в страховочную копию, которая и возвращается: из неё
+// можно вернуть состояние до восстановления.
+func (s *FinanceStorage) RestoreBackup(actor Actor, name string) (BackupInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	for len(backupFiles) >= maxBackups {
-		os.Remove(backupFiles[0])
-		backupFiles = backupFiles[1:]
+	if s.backups == nil {
+		return BackupInfo{}, ErrBackupsDisabled
+	}
+	restored := models.FinanceData{
+		Balances:        make(map[string]float64),
+		AccountBalances: make(map[int]float64),
+	}
+	if _, err := s.backups.Read(BackupFinance, name, &restored); err != nil {
+		return BackupInfo{}, err
+	}
+	safety, err := s.backups.Create(BackupFinance, &s.data, len(s.data.Transactions), BackupPreRestore)
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("не удалось создать страховочную копию: %v", err)
 	}
 
-	backupFile := filepath.Join(backupDir, fmt.Sprintf("finance_data_backup_%d.json", time.Now().Unix()))
-	if err := os.WriteFile(backupFile, currentData, 0644); err != nil {
-		fmt.Println("Ошибка создания резервной копии:", err)
-	} else {
-		fmt.Println("Резервная копия создана:", backupFile)
+	previous := s.data
+	s.data = restored
+	s.undo = nil
+	s.applyDefaults()
+	s.recalculateBalances()
+	if err := s.save(); err != nil {
+		s.data = previous
+		return BackupInfo{}, err
 	}
+	s.reindex()
+	s.audit.recordFinance(actor, &previous, &s.data)
+	fmt.Println("Финансовые данные восстановлены из резервной копии", name)
+	return safety, nil
 }
 
-func (s *FinanceStorage) GetData() *models.FinanceData {
+// Close вызывается при штатной остановке и дописывает отложенные изменения
+func (s *FinanceStorage) Close() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	return &s.data
+	return s.backend.FlushFinance(&s.data)
+}
+
+// Snapshot возвращает копию данных для чтения. Копия не меняется при
+// последующих изменениях хранилища, изменения в ней не сохраняются.
+func (s *FinanceStorage) Snapshot() *models.FinanceData {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data := copyFinanceData(&s.data)
+	return &data
+}
+
+// Update выполняет изменение данных как одну транзакцию: мьютекс удерживается
+// на всё время чтения, изменения, пересчёта баланса и сохранения. Если fn или
+// сохранение вернули ошибку, данные возвращаются к исходному состоянию.
+// Указатель на данные нельзя использовать после возврата из fn. actor
+// записывается в журнал изменений как автор изменения.
+func (s *FinanceStorage) Update(actor Actor, fn func(data *models.FinanceData) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.update(actor, copyFinanceData(&s.data), fn)
+}
+
+// update — то же, что Update, для вызова под мьютексом. backup — копия
+// текущих данных, к которой они возвращаются при ошибке.
+func (s *FinanceStorage) update(actor Actor, backup models.FinanceData, fn func(data *models.FinanceData) error) error {
+	if err := fn(&s.data); err != nil {
+		s.data = backup
+		return err
+	}
+	s.recalculateBalances()
+	if err := s.save(); err != nil {
+		s.data = backup
+		return err
+	}
+	s.reindex()
+	s.audit.recordFinance(actor, &backup, &s.data)
+	return nil
+}
+
+// SetAuditLog включает журнал изменений операций
+func (s *FinanceStorage) SetAuditLog(audit *AuditLog) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.audit = audit
+}
+
+// QueryTransactions возвращает копии операций, для которых match возвращает
+// true. match == nil выбирает все операции.
+func (s *FinanceStorage) QueryTransactions(match func(t models.Transaction) bool) []models.Transaction {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := []models.Transaction{}
+	for _, t := range s.data.Transactions {
+		if match == nil || match(t) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// AddTransaction добавляет операцию, которую собирает build. build вызывается
+// под мьютексом и может проверять категории и счета по текущим данным.
+// Хранилище назначает операции новый ID и, если счёт не указан, счёт по
+// умолчанию для её валюты.
+func (s *FinanceStorage) AddTransaction(actor Actor, build func(data *models.FinanceData) (models.Transaction, error)) (models.Transaction, error) {
+	var added models.Transaction
+	err := s.Change(actor, UndoAdd, func(data *models.FinanceData) error {
+		t, err := build(data)
+		if err != nil {
+			return err
+		}
+		t.ID = NextTransactionID(data)
+		if t.AccountID == 0 {
+			t.AccountID = DefaultAccountID(data, t.Currency)
+		}
+		data.Transactions = append(data.Transactions, t)
+		added = t
+		return nil
+	})
+	return added, err
+}
+
+// UpdateTransaction изменяет операцию с указанным ID. Если операции нет,
+// возвращается ErrNotFound.
+func (s *FinanceStorage) UpdateTransaction(actor Actor, id int, fn func(data *models.FinanceData, t *models.Transaction) error) (models.Transaction, error) {
+	var updated models.Transaction
+	err := s.Change(actor, UndoEdit, func(data *models.FinanceData) error {
+		for i := range data.Transactions {
+			if data.Transactions[i].ID != id {
+				continue
+			}
+			if err := fn(data, &data.Transactions[i]); err != nil {
+				return err
+			}
+			data.Transactions[i].ID = id
+			updated = data.Transactions[i]
+			return nil
+		}
+		return ErrNotFound
+	})
+	return updated, err
+}
+
+// DeleteTransactions переносит в корзину операции с указанными ID. Переводы и
+// обмены удаляются целиком, вместе с парной операцией. Возвращает ID
+// фактически удалённых операций.
+func (s *FinanceStorage) DeleteTransactions(actor Actor, ids []int) ([]int, error) {
+	var removed []int
+	err := s.Change(actor, UndoDelete, func(data *models.FinanceData) error {
+		removed = RemoveTransactions(data, ids)
+		return nil
+	})
+	return removed, err
+}
+
+// NextTransactionID возвращает следующий свободный ID операции. ID операций
+// в корзине тоже заняты, иначе восстановленная операция столкнулась бы с новой.
+func NextTransactionID(data *models.FinanceData) int {
+	maxID := 0
+	for _, t := range data.Transactions {
+		if t.ID > maxID {
+			maxID = t.ID
+		}
+	}
+	for _, d := range data.Trash {
+		if d.ID > maxID {
+			maxID = d.ID
+		}
+	}
+	return maxID + 1
+}
+
+// copyFinanceData делает глубокую копию данных: срезы и карты не разделяются
+// с оригиналом
+func copyFinanceData(src *models.FinanceData) models.FinanceData {
+	dst := *src
+	dst.Transactions = append([]models.Transaction{}, src.Transactions...)
+	dst.Trash = append([]models.DeletedTransaction(nil), src.Trash...)
+	dst.Accounts = append([]models.Account{}, src.Accounts...)
+	dst.Categories = append([]models.Category{}, src.Categories...)
+	dst.Budgets = append([]models.Budget{}, src.Budgets...)
+	dst.Recurring = append([]models.RecurringTransaction{}, src.Recurring...)
+	dst.Rates = append([]models.ExchangeRate{}, src.Rates...)
+	dst.Balances = make(map[string]float64, len(src.Balances))
+	for k, v := range src.Balances {
+		dst.Balances[k] = v
+	}
+	dst.AccountBalances = make(map[int]float64, len(src.AccountBalances))
+	for k, v := range src.AccountBalances {
+		dst.AccountBalances[k] = v
+	}
+	return dst
 }
 
 func (s *FinanceStorage) RecalculateBalances() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	s.recalculateBalances()
+}
 
+// recalculateBalances — то же, что RecalculateBalances, для вызова под мьютексом
+func (s *FinanceStorage) recalculateBalances() {
 	fmt.Println("Пересчёт баланса...")
 
-	// Сбрасываем баланс
+	// Сбрасываем баланс, начальные остатки счетов входят в баланс валюты
 	s.data.Balances = make(map[string]float64)
+	s.data.AccountBalances = make(map[int]float64)
+	for _, a := range s.data.Accounts {
+		s.data.AccountBalances[a.ID] = a.OpeningBalance
+		s.data.Balances[a.Currency] += a.OpeningBalance
+	}
 
-	// Пересчитываем баланс для каждой валюты
+	// Пересчитываем баланс для каждой валюты, запланированные операции
+	// учитываются только после наступления их даты. Переводы и обмены хранятся
+	// парами операций, поэтому каждая половина меняет баланс своего счёта.
+	now := time.Now()
 	for _, t := range s.data.Transactions {
+		if t.IsPlanned(now) {
+			continue
+		}
 		if t.IsPositive {
 			s.data.Balances[t.Currency] += t.Amount
+			s.data.AccountBalances[t.AccountID] += t.Amount
 		} else {
 			s.data.Balances[t.Currency] -= t.Amount
+			s.data.AccountBalances[t.AccountID] -= t.Amount
 		}
 	}
 
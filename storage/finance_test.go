@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"errors"
+	"finance-tracker/models"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Одновременные изменения, отмена, регулярные операции и чтение не должны
+// мешать друг другу: запускайте с go test -race. ID операций, включая
+// операции в корзине, должны оставаться уникальными.
+func TestFinanceStorageConcurrentAccess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "finance_data.json")
+	s := loadTestStorage(t, path)
+
+	start := time.Now().AddDate(0, 0, -60)
+	err := s.Update(Actor{User: "test"}, func(data *models.FinanceData) error {
+		data.Recurring = append(data.Recurring, models.RecurringTransaction{
+			ID: 1, Amount: 5, Description: "подписка", Currency: NationalCurrency,
+			Frequency: FrequencyDaily, StartDate: start,
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	query, err := ParseSearchQuery("тест")
+	if err != nil {
+		t.Fatalf("ParseSearchQuery: %v", err)
+	}
+
+	const workers, rounds = 8, 20
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			actor := Actor{User: fmt.Sprintf("user%d", w)}
+			for i := 0; i < rounds; i++ {
+				added, err := s.AddTransaction(actor, func(data *models.FinanceData) (models.Transaction, error) {
+					return models.Transaction{Amount: float64(i + 1), Description: "тест", DateTime: time.Now(), Currency: NationalCurrency}, nil
+				})
+				if err != nil {
+					t.Errorf("AddTransaction: %v", err)
+					return
+				}
+				switch i % 4 {
+				case 1:
+					if _, err := s.DeleteTransactions(actor, []int{added.ID}); err != nil {
+						t.Errorf("DeleteTransactions: %v", err)
+					}
+				case 2:
+					if _, err := s.Undo(actor); err != nil && !errors.Is(err, ErrNothingToUndo) && !errors.Is(err, ErrUndoConflict) {
+						t.Errorf("Undo: %v", err)
+					}
+				case 3:
+					if _, err := s.ApplyRecurring(start.AddDate(0, 0, w*rounds/workers+i)); err != nil {
+						t.Errorf("ApplyRecurring: %v", err)
+					}
+				}
+				s.SearchTransactions(query, nil)
+				s.SearchSnapshot(query, nil)
+				s.Snapshot()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	data := s.Snapshot()
+	seen := make(map[int]bool)
+	check := func(id int) {
+		if seen[id] {
+			t.Errorf("ID %d встречается дважды", id)
+		}
+		seen[id] = true
+	}
+	for _, tr := range data.Transactions {
+		check(tr.ID)
+	}
+	for _, d := range data.Trash {
+		check(d.ID)
+	}
+
+	occurrences := make(map[string]bool)
+	for _, tr := range data.Transactions {
+		if tr.RecurringID == 0 {
+			continue
+		}
+		key := occurrenceKey(tr.RecurringID, tr.DateTime)
+		if occurrences[key] {
+			t.Errorf("повторение %s создано дважды", key)
+		}
+		occurrences[key] = true
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	reloaded := loadTestStorage(t, path)
+	if got, want := len(reloaded.Snapshot().Transactions), len(data.Transactions); got != want {
+		t.Fatalf("после перезагрузки операций: %d, ожидалось %d", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"finance-tracker/models"
+	"fmt"
+	"math"
+)
+
+// Импорт операций из выписок и таблиц. Разбор файла даёт строки импорта с
+// готовыми операциями или ошибками разбора. Перед сохранением строки
+// сверяются с имеющимися операциями: похожие на уже заведённые помечаются как
+// возможные дубликаты и по умолчанию пропускаются. Все строки сохраняются за
+// одно изменение, чтобы импорт можно было отменить целиком.
+
+// Состояния строки импорта
+const (
+	ImportReady     = "ready"     // Разобрана, будет импортирована
+	ImportDuplicate = "duplicate" // Похожа на имеющуюся операцию
+	ImportExists    = "exists"    // Операция с тем же ID в банке уже импортирована
+	ImportInvalid   = "invalid"   // Не разобрана
+	ImportImported  = "imported"  // Сохранена
+	ImportSkipped   = "skipped"   // Пропущена как дубликат
+)
+
+// ImportRow — строка импортируемого файла
+type ImportRow struct {
+	Line        int // Номер строки в файле или операции в выписке
+	Transaction models.Transaction
+	Status      string
+	Error       string // Причина для ImportInvalid
+	DuplicateOf int    // ID похожей или той же операции для ImportDuplicate, ImportExists и ImportSkipped
+}
+
+// ImportResult — итог импорта по состояниям строк
+type ImportResult struct {
+	Imported int
+	Skipped  int
+	Invalid  int
+}
+
+// invalidImportRow возвращает строку с ошибкой разбора
+func invalidImportRow(line int, format string, args ...interface{}) ImportRow {
+	return ImportRow{Line: line, Status: ImportInvalid, Error: fmt.Sprintf(format, args...)}
+}
+
+// duplicateKey — признаки, по которым операции считаются похожими: день,
+// направление, сумма и валюта
+func duplicateKey(t models.Transaction) string {
+	return fmt.Sprintf("%s|%t|%d|%s",
+		t.DateTime.Local().Format("2006-01-02"), t.IsPositive, int64(math.Round(t.Amount*100)), t.Currency)
+}
+
+// MarkImportDuplicates помечает разобранные строки, уже импортированные
+// раньше (по ID операции в банке, включая операции в корзине) и похожие на
+// имеющиеся операции. Каждая имеющаяся операция считается дубликатом не
+// больше одной строки: две одинаковые покупки за день в файле при одной
+// заведённой дают один дубликат.
+func MarkImportDuplicates(data *models.FinanceData, rows []ImportRow) {
+	external := make(map[string]int)
+	for _, d := range data.Trash {
+		if d.ExternalID != "" {
+			external[d.ExternalID] = d.ID
+		}
+	}
+	existing := make(map[string][]int)
+	for _, t := range data.Transactions {
+		if t.ExternalID != "" {
+			external[t.ExternalID] = t.ID
+		}
+		if t.IsInternal() {
+			continue
+		}
+		key := duplicateKey(t)
+		existing[key] = append(existing[key], t.ID)
+	}
+
+	for i := range rows {
+		if rows[i].Status != ImportReady {
+			continue
+		}
+		if id := rows[i].Transaction.ExternalID; id != "" {
+			if prev, ok := external[id]; ok {
+				rows[i].Status = ImportExists
+				rows[i].DuplicateOf = prev
+				continue
+			}
+			// Повтор строки внутри файла
+			external[id] = 0
+		}
+		key := duplicateKey(rows[i].Transaction)
+		if ids := existing[key]; len(ids) > 0 {
+			rows[i].Status = ImportDuplicate
+			rows[i].DuplicateOf = ids[0]
+			existing[key] = ids[1:]
+		}
+	}
+}
+
+// AssignImportAccount переносит разобранные строки на счёт account. Строки,
+// валюта которых в файле отличается от валюты счёта, помечаются ошибкой.
+// currencyFromFile сообщает, что валюта взята из файла, а не задана по
+// умолчанию.
+func AssignImportAccount(rows []ImportRow, account models.Account, currencyFromFile bool) {
+	for i := range rows {
+		if rows[i].Status == ImportInvalid {
+			continue
+		}
+		t := &rows[i].Transaction
+		if currencyFromFile && t.Currency != account.Currency {
+			rows[i] = invalidImportRow(rows[i].Line, "валюта %s не совпадает с валютой счёта %s", t.Currency, account.Currency)
+			continue
+		}
+		t.Currency = account.Currency
+		t.AccountID = account.ID
+	}
+}
+
+// ApplyImport добавляет в данные разобранные строки. Уже импортированные
+// строки пропускаются всегда, возможные дубликаты — если не задан
+// includeDuplicates. Операции без счёта попадают на счёт по умолчанию своей
+// валюты. Вызывается внутри Update.
+func ApplyImport(data *models.FinanceData, rows []ImportRow, includeDuplicates bool) ImportResult {
+	result := ImportResult{}
+	nextID := NextTransactionID(data)
+	for i := range rows {
+		switch {
+		case rows[i].Status == ImportInvalid:
+			result.Invalid++
+			continue
+		case rows[i].Status == ImportExists:
+			result.Skipped++
+			continue
+		case rows[i].Status == ImportDuplicate && !includeDuplicates:
+			rows[i].Status = ImportSkipped
+			result.Skipped++
+			continue
+		}
+
+		t := rows[i].Transaction
+		t.ID = nextID
+		nextID++
+		if t.AccountID == 0 {
+			t.AccountID = DefaultAccountID(data, t.Currency)
+		}
+		data.Transactions = append(data.Transactions, t)
+		rows[i].Transaction = t
+		rows[i].Status = ImportImported
+		result.Imported++
+	}
+	return result
+}
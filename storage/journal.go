@@ -0,0 +1,385 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Защита JSON-файлов от сбоев.
+//
+// Файл данных — снимок, который записывается атомарно: во временный файл
+// рядом, fsync, переименование поверх старого и fsync каталога. Между снимками
+// каждое сохранение дописывает в журнал (<файл>.journal) только изменившиеся
+// записи пакетом, который заканчивается строкой commit, и сбрасывает журнал на
+// диск. Раз в journalCompactEvery пакетов и при штатной остановке журнал
+// сворачивается в новый снимок.
+//
+// Непустой журнал при запуске означает, что предыдущая работа завершилась
+// некорректно: полные пакеты применяются к снимку, оборванный последний пакет
+// отбрасывается. Если снимок повреждён, данные берутся из самой свежей целой
+// резервной копии (см. backup.go).
+
+const journalCompactEvery = 50
+
+// journalRecord — строка журнала
+type journalRecord struct {
+	Op   string          `json:"op"` // "put", "delete" или "commit"
+	Key  string          `json:"key,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+	Time string          `json:"time,omitempty"`
+}
+
+// record — часть данных, которая журналируется целиком: одна операция, одна
+// запись табеля или документ со справочниками
+type record struct {
+	Key  string
+	Data json.RawMessage
+}
+
+// recordCodec разбивает данные на записи и собирает их обратно
+type recordCodec struct {
+	split func() ([]record, error)
+	join  func([]record) error
+}
+
+// journaledFile — JSON-файл со снимком и журналом изменений
+type journaledFile struct {
+	filePath string
+	mutex    sync.Mutex
+	saved    map[string]string // последнее записанное состояние: ключ → JSON
+	batches  int               // пакетов в журнале после последнего снимка
+
+	// fallback читает в v самую свежую резервную копию, если снимок повреждён
+	fallback func(v interface{}) (BackupInfo, error)
+}
+
+func newJournaledFile(filePath string) *journaledFile {
+	return &journaledFile{filePath: filePath, saved: make(map[string]string)}
+}
+
+func (f *journaledFile) journalPath() string {
+	return f.filePath + ".journal"
+}
+
+// load читает снимок в v, при повреждении — самую свежую целую резервную
+// копию, и применяет журнал
+func (f *journaledFile) load(v interface{}, codec recordCodec) (bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	found, err := readJSONFile(f.filePath, v)
+	recovered := false
+	if err != nil {
+		warnLoudly(fmt.Sprintf("Файл %s повреждён: %v", f.filePath, err))
+		if f.fallback == nil {
+			return false, fmt.Errorf("файл %s повреждён, резервное копирование не настроено: %v", f.filePath, err)
+		}
+		backup, backupErr := f.fallback(v)
+		if backupErr != nil {
+			return false, fmt.Errorf("файл %s повреждён и не найдено целой резервной копии: %v", f.filePath, err)
+		}
+		// Повреждённый файл сохраняем для разбора
+		corrupt := fmt.Sprintf("%s.corrupt-%d", f.filePath, time.Now().Unix())
+		if err := os.Rename(f.filePath, corrupt); err == nil {
+			fmt.Println("Повреждённый файл сохранён как", corrupt)
+		}
+		warnLoudly(fmt.Sprintf("Данные восстановлены из резервной копии %s. Изменения после неё могли быть потеряны.", backup.Name))
+		found = true
+		recovered = true
+	}
+
+	batches, err := readJournal(f.journalPath())
+	if err != nil {
+		return false, err
+	}
+
+	records, err := codec.split()
+	if err != nil {
+		return false, err
+	}
+	if len(batches) > 0 {
+		warnLoudly(fmt.Sprintf("Журнал %s содержит несохранённые изменения (%d): предыдущая работа завершилась некорректно, изменения восстановлены",
+			f.journalPath(), len(batches)))
+		records = applyJournal(records, batches)
+		if err := codec.join(records); err != nil {
+			return false, err
+		}
+		found = true
+	}
+
+	f.saved = make(map[string]string, len(records))
+	for _, r := range records {
+		f.saved[r.Key] = string(r.Data)
+	}
+
+	// Непустой журнал сворачивается, даже если целых пакетов в нём нет:
+	// иначе следующий пакет дописался бы к оборванной строке
+	journalLeft := false
+	if info, err := os.Stat(f.journalPath()); err == nil && info.Size() > 0 {
+		journalLeft = true
+	}
+	if len(batches) > 0 || recovered || journalLeft {
+		if err := f.compact(v); err != nil {
+			return false, err
+		}
+	}
+	return found, nil
+}
+
+// save дописывает в журнал изменившиеся записи и при необходимости
+// сворачивает журнал в снимок
+func (f *journaledFile) save(v interface{}, codec recordCodec) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	records, err := codec.split()
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]string, len(records))
+	var batch []journalRecord
+	for _, r := range records {
+		current[r.Key] = string(r.Data)
+		if f.saved[r.Key] != string(r.Data) {
+			batch = append(batch, journalRecord{Op: "put", Key: r.Key, Data: r.Data})
+		}
+	}
+	deleted := []string{}
+	for key := range f.saved {
+		if _, ok := current[key]; !ok {
+			deleted = append(deleted, key)
+		}
+	}
+	sort.Strings(deleted)
+	for _, key := range deleted {
+		batch = append(batch, journalRecord{Op: "delete", Key: key})
+	}
+
+	// Снимка ещё нет: пишем его сразу, журнал не нужен
+	if _, err := os.Stat(f.filePath); os.IsNotExist(err) {
+		f.saved = current
+		return f.compact(v)
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := appendJournal(f.journalPath(), batch); err != nil {
+		return err
+	}
+	f.saved = current
+	f.batches++
+
+	if f.batches >= journalCompactEvery {
+		return f.compact(v)
+	}
+	return nil
+}
+
+// flush сворачивает журнал в снимок, если в нём есть изменения
+func (f *journaledFile) flush(v interface{}) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if info, err := os.Stat(f.journalPath()); err != nil || info.Size() == 0 {
+		return nil
+	}
+	return f.compact(v)
+}
+
+// compact записывает снимок и очищает журнал. Журнал очищается только после
+// успешной записи снимка, поэтому сбой посередине ничего не теряет.
+func (f *journaledFile) compact(v interface{}) error {
+	if err := writeJSONFile(f.filePath, v); err != nil {
+		return err
+	}
+	if err := os.Remove(f.journalPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ошибка при очистке журнала: %v", err)
+	}
+	syncDir(filepath.Dir(f.filePath))
+	f.batches = 0
+	return nil
+}
+
+// applyJournal применяет пакеты журнала к записям снимка. Порядок записей
+// сохраняется, новые добавляются в конец.
+func applyJournal(records []record, batches [][]journalRecord) []record {
+	index := make(map[string]int, len(records))
+	for i, r := range records {
+		index[r.Key] = i
+	}
+	removed := make(map[string]bool)
+	for _, batch := range batches {
+		for _, op := range batch {
+			switch op.Op {
+			case "put":
+				if i, ok := index[op.Key]; ok {
+					records[i].Data = op.Data
+				} else {
+					index[op.Key] = len(records)
+					records = append(records, record{Key: op.Key, Data: op.Data})
+				}
+				delete(removed, op.Key)
+			case "delete":
+				removed[op.Key] = true
+			}
+		}
+	}
+	result := records[:0]
+	for _, r := range records {
+		if !removed[r.Key] {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// readJournal возвращает полные пакеты журнала. Пакет без строки commit или
+// с повреждённой строкой означает сбой во время записи и отбрасывается,
+// целые пакеты после него сохраняются.
+func readJournal(path string) ([][]journalRecord, error) {
+	fileData, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении журнала: %v", err)
+	}
+
+	var batches [][]journalRecord
+	var batch []journalRecord
+	scanner := bufio.NewScanner(bytes.NewReader(fileData))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var r journalRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			fmt.Println("Повреждённая строка в журнале, неполный пакет отброшен")
+			batch = nil
+			continue
+		}
+		if r.Op == "commit" {
+			batches = append(batches, batch)
+			batch = nil
+			continue
+		}
+		batch = append(batch, r)
+	}
+	if len(batch) > 0 {
+		fmt.Println("Последний пакет журнала не завершён и отброшен")
+	}
+	return batches, nil
+}
+
+// appendJournal дописывает пакет в журнал и сбрасывает его на диск
+func appendJournal(path string, batch []journalRecord) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, r := range batch {
+		if err := encoder.Encode(r); err != nil {
+			return fmt.Errorf("ошибка при кодировании журнала: %v", err)
+		}
+	}
+	if err := encoder.Encode(journalRecord{Op: "commit", Time: time.Now().Format(time.RFC3339)}); err != nil {
+		return fmt.Errorf("ошибка при кодировании журнала: %v", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("ошибка при открытии журнала: %v", err)
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("ошибка при записи журнала: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("ошибка при сбросе журнала на диск: %v", err)
+	}
+	return file.Close()
+}
+
+// writeJSONFile атомарно заменяет файл: пишет во временный файл в том же
+// каталоге, сбрасывает его на диск и переименовывает поверх старого
+func writeJSONFile(filePath string, v interface{}) error {
+	fileData, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка при кодировании в JSON: %v", err)
+	}
+
+	dir := filepath.Dir(filePath)
+	tmp, err := os.CreateTemp(dir, filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("ошибка при создании временного файла: %v", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(fileData); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка при записи в файл: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка при сбросе файла на диск: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка при записи в файл: %v", err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return fmt.Errorf("ошибка при записи в файл: %v", err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("ошибка при замене файла: %v", err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir сбрасывает на диск каталог, чтобы переименование пережило сбой
+// питания. Не все системы это поддерживают, поэтому ошибки игнорируются.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// readJSONFile декодирует файл в v. Отсутствующий файл не считается ошибкой.
+func readJSONFile(filePath string, v interface{}) (bool, error) {
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		return false, fmt.Errorf("ошибка при чтении файла: %v", err)
+	}
+
+	if err := json.Unmarshal(fileData, v); err != nil {
+		return false, fmt.Errorf("ошибка при декодировании JSON: %v", err)
+	}
+	return true, nil
+}
+
+// warnLoudly выводит предупреждение, которое трудно не заметить в логе
+func warnLoudly(message string) {
+	line := strings.Repeat("!", 72)
+	fmt.Println(line)
+	fmt.Println("!!! ВНИМАНИЕ:", message)
+	fmt.Println(line)
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"finance-tracker/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// addTestTransaction добавляет расход на указанную сумму
+func addTestTransaction(t *testing.T, s *FinanceStorage, amount float64) {
+	t.Helper()
+	_, err := s.AddTransaction(Actor{User: "test"}, func(data *models.FinanceData) (models.Transaction, error) {
+		return models.Transaction{Amount: amount, Description: "тест", DateTime: time.Now(), Currency: NationalCurrency}, nil
+	})
+	if err != nil {
+		t.Fatalf("AddTransaction: %v", err)
+	}
+}
+
+// loadTestStorage открывает хранилище так, как при запуске приложения
+func loadTestStorage(t *testing.T, path string) *FinanceStorage {
+	t.Helper()
+	s := NewFinanceStorage(path)
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return s
+}
+
+// Сбой посреди записи оставляет в журнале оборванную строку. После
+// перезапуска и новых изменений повторный сбой не должен терять пакеты,
+// записанные после оборванной строки.
+func TestJournalTornLineSurvivesSecondCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "finance_data.json")
+
+	s := loadTestStorage(t, path)
+	addTestTransaction(t, s, 10)
+
+	// Первый сбой: от пакета на диск попала только часть строки
+	torn := []byte(`{"op":"put","key":"transaction/2","data":{"ID":2,"Amo`)
+	if err := os.WriteFile(path+".journal", torn, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s = loadTestStorage(t, path)
+	if got := len(s.Snapshot().Transactions); got != 1 {
+		t.Fatalf("после первого сбоя загружено операций: %d, ожидалась 1", got)
+	}
+	if _, err := os.Stat(path + ".journal"); !os.IsNotExist(err) {
+		t.Fatalf("журнал с оборванной строкой не свёрнут при загрузке: %v", err)
+	}
+	addTestTransaction(t, s, 20)
+	addTestTransaction(t, s, 30)
+
+	// Второй сбой: хранилище не закрывается, снимок не обновляется
+	s = loadTestStorage(t, path)
+	if got := len(s.Snapshot().Transactions); got != 3 {
+		t.Fatalf("после второго сбоя загружено операций: %d, ожидалось 3", got)
+	}
+}
+
+// Записи без строки commit перед повреждённой строкой не должны попасть в
+// следующий целый пакет
+func TestReadJournalDropsPendingBatchOnTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.journal")
+	journal := `{"op":"put","key":"a","data":1}
+{"op":"put","key":"b","da
+{"op":"put","key":"c","data":3}
+{"op":"commit"}
+`
+	if err := os.WriteFile(path, []byte(journal), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	batches, err := readJournal(path)
+	if err != nil {
+		t.Fatalf("readJournal: %v", err)
+	}
+	if len(batches) != 1 || len(batches[0]) != 1 || batches[0][0].Key != "c" {
+		t.Fatalf("ожидался один пакет с записью c, получено: %+v", batches)
+	}
+}
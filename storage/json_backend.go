@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"encoding/json"
+	"finance-tracker/models"
+	"fmt"
+)
+
+// JSONFinanceBackend хранит финансовые данные JSON-файлом с журналом
+// изменений (см. journal.go). Доступа к отдельным операциям у него нет:
+// формат не позволяет прочитать или изменить одну запись, не загрузив файл
+// целиком, а повторная загрузка сбила бы журнал работающего хранилища.
+type JSONFinanceBackend struct {
+	file *journaledFile
+}
+
+func NewJSONFinanceBackend(filePath string) *JSONFinanceBackend {
+	return &JSONFinanceBackend{file: newJournaledFile(filePath)}
+}
+
+func (b *JSONFinanceBackend) Describe() string {
+	return "файл " + b.file.filePath
+}
+
+func (b *JSONFinanceBackend) LoadFinance(data *models.FinanceData) (bool, error) {
+	return b.file.load(data, financeCodec(data))
+}
+
+func (b *JSONFinanceBackend) SaveFinance(data *models.FinanceData) error {
+	return b.file.save(data, financeCodec(data))
+}
+
+func (b *JSONFinanceBackend) FlushFinance(data *models.FinanceData) error {
+	return b.file.flush(data)
+}
+
+func (b *JSONFinanceBackend) setBackupFallback(fallback func(v interface{}) (BackupInfo, error)) {
+	b.file.fallback = fallback
+}
+
+// financeCodec журналирует каждую операцию отдельно, а справочники — одним
+// документом
+func financeCodec(data *models.FinanceData) recordCodec {
+	return recordCodec{
+		split: func() ([]record, error) {
+			meta := *data
+			meta.Transactions = nil
+			raw, err := json.Marshal(meta)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка при кодировании в JSON: %v", err)
+			}
+			records := []record{{Key: "meta", Data: raw}}
+			for _, t := range data.Transactions {
+				raw, err := json.Marshal(t)
+				if err != nil {
+					return nil, fmt.Errorf("ошибка при кодировании в JSON: %v", err)
+				}
+				records = append(records, record{Key: fmt.Sprintf("transaction/%d", t.ID), Data: raw})
+			}
+			return records, nil
+		},
+		join: func(records []record) error {
+			transactions := []models.Transaction{}
+			for _, r := range records {
+				if r.Key == "meta" {
+					if err := json.Unmarshal(r.Data, data); err != nil {
+						return fmt.Errorf("ошибка при декодировании журнала: %v", err)
+					}
+					continue
+				}
+				var t models.Transaction
+				if err := json.Unmarshal(r.Data, &t); err != nil {
+					return fmt.Errorf("ошибка при декодировании журнала: %v", err)
+				}
+				transactions = append(transactions, t)
+			}
+			data.Transactions = transactions
+			return nil
+		},
+	}
+}
+
+// JSONWorkLogBackend хранит табель JSON-файлом с журналом изменений
+type JSONWorkLogBackend struct {
+	file *journaledFile
+}
+
+func NewJSONWorkLogBackend(filePath string) *JSONWorkLogBackend {
+	return &JSONWorkLogBackend{file: newJournaledFile(filePath)}
+}
+
+func (b *JSONWorkLogBackend) Describe() string {
+	return "файл " + b.file.filePath
+}
+
+func (b *JSONWorkLogBackend) LoadWorkLog(data *models.WorkLogData) (bool, error) {
+	return b.file.load(data, workLogCodec(data))
+}
+
+func (b *JSONWorkLogBackend) SaveWorkLog(data *models.WorkLogData) error {
+	return b.file.save(data, workLogCodec(data))
+}
+
+func (b *JSONWorkLogBackend) FlushWorkLog(data *models.WorkLogData) error {
+	return b.file.flush(data)
+}
+
+func (b *JSONWorkLogBackend) setBackupFallback(fallback func(v interface{}) (BackupInfo, error)) {
+	b.file.fallback = fallback
+}
+
+// workLogCodec журналирует каждую запись табеля отдельно
+func workLogCodec(data *models.WorkLogData) recordCodec {
+	return recordCodec{
+		split: func() ([]record, error) {
+			meta := *data
+			meta.Entries = nil
+			raw, err := json.Marshal(meta)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка при кодировании в JSON: %v", err)
+			}
+			records := []record{{Key: "meta", Data: raw}}
+			for _, entry := range data.Entries {
+				raw, err := json.Marshal(entry)
+				if err != nil {
+					return nil, fmt.Errorf("ошибка при кодировании в JSON: %v", err)
+				}
+				records = append(records, record{Key: "entry/" + entry.Date, Data: raw})
+			}
+			return records, nil
+		},
+		join: func(records []record) error {
+			entries := []models.WorkEntry{}
+			for _, r := range records {
+				if r.Key == "meta" {
+					if err := json.Unmarshal(r.Data, data); err != nil {
+						return fmt.Errorf("ошибка при декодировании журнала: %v", err)
+					}
+					continue
+				}
+				var entry models.WorkEntry
+				if err := json.Unmarshal(r.Data, &entry); err != nil {
+					return fmt.Errorf("ошибка при декодировании журнала: %v", err)
+				}
+				entries = append(entries, entry)
+			}
+			data.Entries = entries
+			return nil
+		},
+	}
+}
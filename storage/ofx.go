@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"finance-tracker/models"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OFX 1.x — SGML, в котором у простых элементов нет закрывающих тегов, OFX
+// 2.x — XML. Разбор общий: значение элемента — текст после открывающего тега
+// до следующего тега, агрегаты ограничены открывающим и закрывающим тегами.
+
+// ofxTag — тег OFX со значением
+type ofxTag struct {
+	name    string // Имя в верхнем регистре, у закрывающего тега — без "/"
+	value   string
+	closing bool
+}
+
+// ofxTags разбивает тело OFX на теги, пропуская заголовок и инструкции
+func ofxTags(body string) []ofxTag {
+	if i := strings.Index(strings.ToUpper(body), "<OFX>"); i >= 0 {
+		body = body[i:]
+	}
+	tags := []ofxTag{}
+	for {
+		start := strings.IndexByte(body, '<')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(body[start:], '>')
+		if end < 0 {
+			break
+		}
+		end += start
+		name := strings.ToUpper(strings.TrimSpace(body[start+1 : end]))
+		body = body[end+1:]
+		if strings.HasPrefix(name, "?") || strings.HasPrefix(name, "!") {
+			continue
+		}
+		next := strings.IndexByte(body, '<')
+		if next < 0 {
+			next = len(body)
+		}
+		tag := ofxTag{name: name, value: html.UnescapeString(strings.TrimSpace(body[:next]))}
+		if strings.HasPrefix(name, "/") {
+			tag.name = name[1:]
+			tag.closing = true
+			tag.value = ""
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// parseOFXDate разбирает дату OFX: ГГГГММДД[ччмм[сс[.XXX]]][[смещение:пояс]].
+// Дата без пояса считается местной.
+func parseOFXDate(value string) (time.Time, error) {
+	loc := time.Local
+	if i := strings.IndexByte(value, '['); i >= 0 {
+		zone := strings.Trim(value[i:], "[]")
+		if j := strings.IndexByte(zone, ':'); j >= 0 {
+			zone = zone[:j]
+		}
+		if offset, err := strconv.ParseFloat(zone, 64); err == nil {
+			loc = time.FixedZone("", int(offset*3600))
+		}
+		value = value[:i]
+	}
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		value = value[:i]
+	}
+	layouts := map[int]string{8: "20060102", 12: "200601021504", 14: "20060102150405"}
+	layout, ok := layouts[len(value)]
+	if !ok {
+		return time.Time{}, fmt.Errorf("неверная дата %q", value)
+	}
+	t, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("неверная дата %q", value)
+	}
+	return t, nil
+}
+
+// parseOFX разбирает банковскую (STMTRS) или карточную (CCSTMTRS) выписку
+func parseOFX(body string) (Statement, error) {
+	tags := ofxTags(body)
+	if len(tags) == 0 {
+		return Statement{}, fmt.Errorf("в файле нет данных OFX")
+	}
+
+	statement := Statement{Rows: []ImportRow{}}
+	var path []string
+	var current map[string]string
+	number := 0
+	for _, tag := range tags {
+		if tag.closing {
+			// В SGML закрывающий тег может закрывать несколько незакрытых агрегатов
+			for i := len(path) - 1; i >= 0; i-- {
+				if path[i] == tag.name {
+					path = path[:i]
+					break
+				}
+			}
+			if tag.name == "STMTTRN" && current != nil {
+				number++
+				statement.Rows = append(statement.Rows, ofxRow(number, current))
+				current = nil
+			}
+			continue
+		}
+		if tag.value == "" {
+			path = append(path, tag.name)
+			if tag.name == "STMTTRN" {
+				current = map[string]string{}
+			}
+			continue
+		}
+
+		parent := ""
+		if len(path) > 0 {
+			parent = path[len(path)-1]
+		}
+		switch {
+		case current != nil:
+			// NAME внутри PAYEE и сам NAME — одно и то же поле
+			if _, ok := current[tag.name]; !ok {
+				current[tag.name] = tag.value
+			}
+		case tag.name == "CURDEF" && statement.Currency == "":
+			statement.Currency = strings.ToUpper(tag.value)
+		case tag.name == "ACCTID" && (parent == "BANKACCTFROM" || parent == "CCACCTFROM") && statement.Account == "":
+			statement.Account = tag.value
+		}
+	}
+	if number == 0 {
+		return Statement{}, fmt.Errorf("в выписке нет операций")
+	}
+	return statement, nil
+}
+
+// ofxRow превращает поля STMTTRN в строку импорта. Сумма в OFX всегда в
+// валюте счёта, даже если операция совершена в другой валюте.
+func ofxRow(number int, fields map[string]string) ImportRow {
+	amount, err := ParseAmount(fields["TRNAMT"])
+	if err != nil {
+		return invalidImportRow(number, "%v", err)
+	}
+	dateTime, err := parseOFXDate(fields["DTPOSTED"])
+	if err != nil {
+		return invalidImportRow(number, "%v", err)
+	}
+
+	description := fields["NAME"]
+	notes := fields["MEMO"]
+	if description == "" {
+		description, notes = notes, ""
+	}
+	return statementRow(number, models.Transaction{
+		Description: description,
+		DateTime:    dateTime,
+		Notes:       notes,
+		ExternalID:  fields["FITID"],
+	}, amount)
+}
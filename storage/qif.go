@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"finance-tracker/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QIF — построчный формат Quicken: первая буква строки задаёт поле, запись
+// заканчивается строкой "^". ID операций и валюты в QIF нет, поэтому
+// повторный импорт распознаётся только по похожим операциям.
+
+// qifDateLayouts — форматы дат QIF. Quicken пишет месяц первым; если так
+// дата не разбирается, пробуется день первым.
+var qifDateLayouts = []string{
+	"1/2/2006", "1/2/06", "2/1/2006", "2/1/06", "2.1.2006", "2.1.06", "2006-01-02",
+}
+
+// parseQIFDate разбирает дату QIF: 12/31/2024, 12/31'24, 31.12.2024 и т. п.
+func parseQIFDate(value string) (time.Time, error) {
+	normalized := strings.NewReplacer("'", "/", " ", "").Replace(strings.TrimSpace(value))
+	for _, layout := range qifDateLayouts {
+		if t, err := time.ParseInLocation(layout, normalized, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("неверная дата %q", value)
+}
+
+// parseQIF разбирает операции банковского, карточного или наличного счёта.
+// Имя счёта из блока !Account используется как номер счёта в банке.
+func parseQIF(body string) (Statement, error) {
+	statement := Statement{Rows: []ImportRow{}}
+	fields := map[byte]string{}
+	section := ""
+	number := 0
+
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			continue
+		}
+		if line[0] == '!' {
+			section = strings.ToUpper(line)
+			continue
+		}
+		if line == "^" {
+			switch {
+			case section == "!ACCOUNT":
+				if statement.Account == "" {
+					statement.Account = fields['N']
+				}
+			case strings.HasPrefix(section, "!TYPE:") && len(fields) > 0:
+				number++
+				statement.Rows = append(statement.Rows, qifRow(number, fields))
+			}
+			fields = map[byte]string{}
+			continue
+		}
+		// Повторяющиеся поля (строки разбивки S/E/$) не нужны: берётся первое
+		if _, ok := fields[line[0]]; !ok {
+			fields[line[0]] = strings.TrimSpace(line[1:])
+		}
+	}
+	if number == 0 {
+		return Statement{}, fmt.Errorf("в выписке нет операций")
+	}
+	return statement, nil
+}
+
+// qifRow превращает поля записи QIF в строку импорта
+func qifRow(number int, fields map[byte]string) ImportRow {
+	value := fields['T']
+	if value == "" {
+		value = fields['U']
+	}
+	amount, err := ParseAmount(value)
+	if err != nil {
+		return invalidImportRow(number, "%v", err)
+	}
+	dateTime, err := parseQIFDate(fields['D'])
+	if err != nil {
+		return invalidImportRow(number, "%v", err)
+	}
+
+	description := fields['P']
+	notes := fields['M']
+	if description == "" {
+		description, notes = notes, ""
+	}
+	return statementRow(number, models.Transaction{
+		Description: description,
+		DateTime:    dateTime,
+		Notes:       notes,
+	}, amount)
+}
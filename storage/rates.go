@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"encoding/json"
+	"finance-tracker/models"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NationalCurrency — валюта, в которой выражены курсы Нацбанка
+const NationalCurrency = "BYN"
+
+// nbrbRate — запись курса в формате API Национального банка Республики
+// Беларусь (api.nbrb.by/exrates/rates). Cur_Abbreviation отсутствует в
+// ответе метода dynamics, поэтому валюту можно передать отдельно.
+type nbrbRate struct {
+	Date            string  `json:"Date"`
+	CurAbbreviation string  `json:"Cur_Abbreviation"`
+	CurScale        float64 `json:"Cur_Scale"`
+	CurOfficialRate float64 `json:"Cur_OfficialRate"`
+}
+
+// ParseNBRBRates разбирает курсы в формате JSON Нацбанка: массив записей или
+// одну запись. defaultCurrency используется для записей без Cur_Abbreviation.
+func ParseNBRBRates(r io.Reader, defaultCurrency string) ([]models.ExchangeRate, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении файла: %v", err)
+	}
+
+	var records []nbrbRate
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "{") {
+		var single nbrbRate
+		if err := json.Unmarshal(body, &single); err != nil {
+			return nil, fmt.Errorf("ошибка при декодировании JSON: %v", err)
+		}
+		records = append(records, single)
+	} else if err := json.Unmarshal(body, &records); err != nil {
+		return nil, fmt.Errorf("ошибка при декодировании JSON: %v", err)
+	}
+
+	rates := []models.ExchangeRate{}
+	for i, rec := range records {
+		currency := strings.ToUpper(rec.CurAbbreviation)
+		if currency == "" {
+			currency = strings.ToUpper(defaultCurrency)
+		}
+		if currency == "" {
+			return nil, fmt.Errorf("запись %d: не указана валюта", i+1)
+		}
+		if len(rec.Date) < 10 {
+			return nil, fmt.Errorf("запись %d: неверная дата %q", i+1, rec.Date)
+		}
+		date, err := time.Parse("2006-01-02", rec.Date[:10])
+		if err != nil {
+			return nil, fmt.Errorf("запись %d: неверная дата %q", i+1, rec.Date)
+		}
+		if rec.CurOfficialRate <= 0 {
+			return nil, fmt.Errorf("запись %d: неверный курс", i+1)
+		}
+		scale := rec.CurScale
+		if scale <= 0 {
+			scale = 1
+		}
+		rates = append(rates, models.ExchangeRate{
+			Date:     date.Format("2006-01-02"),
+			Currency: currency,
+			Rate:     rec.CurOfficialRate / scale,
+		})
+	}
+	return rates, nil
+}
+
+// MergeRates добавляет курсы в данные, заменяя существующие записи с той же
+// датой и валютой. Возвращает число добавленных или обновлённых записей.
+func MergeRates(data *models.FinanceData, rates []models.ExchangeRate) int {
+	index := make(map[string]int, len(data.Rates))
+	for i, r := range data.Rates {
+		index[r.Date+"/"+r.Currency] = i
+	}
+	changed := 0
+	for _, r := range rates {
+		if r.Currency == NationalCurrency {
+			continue
+		}
+		key := r.Date + "/" + r.Currency
+		if i, ok := index[key]; ok {
+			if data.Rates[i].Rate != r.Rate {
+				data.Rates[i].Rate = r.Rate
+				changed++
+			}
+			continue
+		}
+		index[key] = len(data.Rates)
+		data.Rates = append(data.Rates, r)
+		changed++
+	}
+	sort.Slice(data.Rates, func(i, j int) bool {
+		if data.Rates[i].Date != data.Rates[j].Date {
+			return data.Rates[i].Date < data.Rates[j].Date
+		}
+		return data.Rates[i].Currency < data.Rates[j].Currency
+	})
+	return changed
+}
+
+// Converter пересчитывает суммы в базовую валюту по курсу, действовавшему на
+// дату операции: берётся последний известный курс не позже этой даты, а для
+// дат раньше первого курса — самый ранний курс.
+type Converter struct {
+	base    string
+	rates   map[string][]models.ExchangeRate
+	missing map[string]bool
+}
+
+// NewConverter строит конвертер по курсам и базовой валюте из данных
+func NewConverter(data *models.FinanceData) *Converter {
+	base := data.BaseCurrency
+	if base == "" {
+		base = NationalCurrency
+	}
+	conv := &Converter{
+		base:    base,
+		rates:   make(map[string][]models.ExchangeRate),
+		missing: make(map[string]bool),
+	}
+	for _, r := range data.Rates {
+		conv.rates[r.Currency] = append(conv.rates[r.Currency], r)
+	}
+	for currency := range conv.rates {
+		list := conv.rates[currency]
+		sort.Slice(list, func(i, j int) bool { return list[i].Date < list[j].Date })
+	}
+	return conv
+}
+
+// Base возвращает базовую валюту
+func (c *Converter) Base() string {
+	return c.base
+}
+
+// rateToBYN возвращает стоимость единицы валюты в BYN на дату
+func (c *Converter) rateToBYN(currency string, at time.Time) (float64, bool) {
+	if currency == NationalCurrency {
+		return 1, true
+	}
+	list := c.rates[currency]
+	if len(list) == 0 {
+		return 0, false
+	}
+	date := at.Format("2006-01-02")
+	i := sort.Search(len(list), func(i int) bool { return list[i].Date > date })
+	if i == 0 {
+		return list[0].Rate, true
+	}
+	return list[i-1].Rate, true
+}
+
+// Convert пересчитывает сумму из валюты currency в базовую на дату at. Если
+// курса нет, возвращает false и запоминает валюту в списке Missing.
+func (c *Converter) Convert(amount float64, currency string, at time.Time) (float64, bool) {
+	if currency == c.base {
+		return amount, true
+	}
+	from, ok := c.rateToBYN(currency, at)
+	if !ok {
+		c.missing[currency] = true
+		return 0, false
+	}
+	to, ok := c.rateToBYN(c.base, at)
+	if !ok {
+		c.missing[c.base] = true
+		return 0, false
+	}
+	return amount * from / to, true
+}
+
+// Amount пересчитывает сумму операции в базовую валюту
+func (c *Converter) Amount(t models.Transaction) (float64, bool) {
+	return c.Convert(t.Amount, t.Currency, t.DateTime)
+}
+
+// Missing возвращает отсортированный список валют, для которых не нашлось курса
+func (c *Converter) Missing() []string {
+	result := []string{}
+	for currency := range c.missing {
+		result = append(result, currency)
+	}
+	sort.Strings(result)
+	return result
+}
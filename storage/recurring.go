@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"finance-tracker/models"
+	"fmt"
+	"time"
+)
+
+// Допустимые периодичности регулярных операций
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+	FrequencyYearly  = "yearly"
+)
+
+// ValidFrequency сообщает, поддерживается ли периодичность
+func ValidFrequency(frequency string) bool {
+	switch frequency {
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly, FrequencyYearly:
+		return true
+	}
+	return false
+}
+
+// Occurrence возвращает дату n-го (с нуля) повторения шаблона. Дата всегда
+// считается от StartDate, поэтому 31-е число в коротких месяцах становится
+// последним днём месяца и не "уплывает" в следующие повторения.
+func Occurrence(r models.RecurringTransaction, n int) time.Time {
+	switch r.Frequency {
+	case FrequencyDaily:
+		return r.StartDate.AddDate(0, 0, n)
+	case FrequencyWeekly:
+		return r.StartDate.AddDate(0, 0, 7*n)
+	case FrequencyMonthly:
+		return addMonthsClamped(r.StartDate, n)
+	case FrequencyYearly:
+		return addMonthsClamped(r.StartDate, 12*n)
+	}
+	return r.StartDate
+}
+
+// Finished сообщает, исчерпан ли шаблон по количеству или дате окончания
+func Finished(r models.RecurringTransaction) bool {
+	if r.Count > 0 && r.Generated >= r.Count {
+		return true
+	}
+	if !r.EndDate.IsZero() && Occurrence(r, r.Generated).After(r.EndDate) {
+		return true
+	}
+	return false
+}
+
+func addMonthsClamped(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// ApplyRecurring создаёт операции по всем повторениям, срок которых наступил
+// к моменту now, включая пропущенные за время простоя. Уже созданное повторение
+// определяется по счётчику Generated и по паре (RecurringID, DateTime), поэтому
+// повторный запуск не создаёт дубликатов. Если операции созданы, баланс
+// пересчитывается и данные сохраняются под тем же мьютексом; при ошибке
+// сохранения изменения откатываются. Возвращает число новых операций.
+func (s *FinanceStorage) ApplyRecurring(now time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	backup := copyFinanceData(&s.data)
+	existing := make(map[string]bool)
+	for _, t := range s.data.Transactions {
+		if t.RecurringID != 0 {
+			existing[occurrenceKey(t.RecurringID, t.DateTime)] = true
+		}
+	}
+	maxID := NextTransactionID(&s.data) - 1
+
+	created := 0
+	for i := range s.data.Recurring {
+		r := &s.data.Recurring[i]
+		if r.Paused {
+			continue
+		}
+		for !Finished(*r) {
+			date := Occurrence(*r, r.Generated)
+			if date.After(now) {
+				break
+			}
+			if !existing[occurrenceKey(r.ID, date)] {
+				accountID := r.AccountID
+				if accountID == 0 {
+					accountID = DefaultAccountID(&s.data, r.Currency)
+				}
+				maxID++
+				s.data.Transactions = append(s.data.Transactions, models.Transaction{
+					ID:          maxID,
+					Amount:      r.Amount,
+					Description: r.Description,
+					DateTime:    date,
+					IsPositive:  r.IsPositive,
+					Currency:    r.Currency,
+					Notes:       r.Notes,
+					CategoryID:  r.CategoryID,
+					RecurringID: r.ID,
+					AccountID:   accountID,
+				})
+				existing[occurrenceKey(r.ID, date)] = true
+				created++
+			}
+			r.Generated++
+		}
+	}
+
+	if created == 0 {
+		return 0, nil
+	}
+
+	fmt.Printf("Создано регулярных операций: %d\n", created)
+	s.recalculateBalances()
+	if err := s.save(); err != nil {
+		s.data = backup
+		return 0, err
+	}
+	s.reindex()
+	s.audit.recordFinance(SchedulerActor, &backup, &s.data)
+	return created, nil
+}
+
+func occurrenceKey(recurringID int, date time.Time) string {
+	return fmt.Sprintf("%d/%d", recurringID, date.Unix())
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"errors"
+	"finance-tracker/models"
+	"time"
+)
+
+// ErrNotFound возвращается репозиториями, если запись не найдена
+var ErrNotFound = errors.New("запись не найдена")
+
+// ErrExists возвращается при добавлении записи, ключ которой уже занят
+var ErrExists = errors.New("запись уже существует")
+
+// Репозитории дают доступ к отдельным записям в постоянном хранилище без
+// загрузки всех данных, с выборками по индексу дат. Их реализует бэкенд
+// SQLite, и нужны они утилитам, работающим с базой напрямую (миграция,
+// импорт). Работающее приложение держит данные в памяти FinanceStorage и
+// WorkLogStorage, читает их оттуда и сохраняет через бэкенд, поэтому в обход
+// хранилищ записи не читаются и не меняются.
+
+// TransactionRepository — доступ к отдельным операциям
+type TransactionRepository interface {
+	Transaction(id int) (models.Transaction, error)
+	// TransactionsBetween возвращает операции с from <= DateTime <= to,
+	// отсортированные по дате
+	TransactionsBetween(from, to time.Time) ([]models.Transaction, error)
+	PutTransaction(t models.Transaction) error
+	DeleteTransaction(id int) error
+}
+
+// WorkEntryRepository — доступ к отдельным записям табеля
+type WorkEntryRepository interface {
+	WorkEntry(date string) (models.WorkEntry, error)
+	// WorkEntriesBetween возвращает записи с from <= Date <= to (YYYY-MM-DD),
+	// отсортированные по дате
+	WorkEntriesBetween(from, to string) ([]models.WorkEntry, error)
+	PutWorkEntry(entry models.WorkEntry) error
+	DeleteWorkEntry(date string) error
+}
+
+// FinanceBackend — постоянное хранилище финансовых данных. LoadFinance
+// заполняет data и сообщает, были ли данные сохранены ранее; SaveFinance
+// надёжно записывает текущее состояние; FlushFinance вызывается при штатной
+// остановке и дописывает отложенное (например, сворачивает журнал).
+type FinanceBackend interface {
+	LoadFinance(data *models.FinanceData) (bool, error)
+	SaveFinance(data *models.FinanceData) error
+	FlushFinance(data *models.FinanceData) error
+	Describe() string
+}
+
+// WorkLogBackend — постоянное хранилище табеля
+type WorkLogBackend interface {
+	LoadWorkLog(data *models.WorkLogData) (bool, error)
+	SaveWorkLog(data *models.WorkLogData) error
+	FlushWorkLog(data *models.WorkLogData) error
+	Describe() string
+}
@@ -0,0 +1,440 @@
+package storage
+
+import (
+	"finance-tracker/models"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Полнотекстовый поиск операций по описанию и заметкам. Запрос состоит из
+// условий через пробел, операция должна подходить под все условия:
+//
+//	кофе                 слово или начало слова
+//	"кофе с собой"       фраза целиком
+//	-такси, -"фраза"     отрицание любого условия
+//	amount:>50           сумма: >, >=, <, <=, точное значение или диапазон 10..50
+//	currency:USD         валюта
+//	type:income          income, expense, transfer или exchange
+//	category:кафе        категория по началу названия
+//	account:карта        счёт по началу названия
+//	before:2025-03-01    раньше дня (месяца ГГГГ-ММ, года ГГГГ)
+//	after:2025-03-01     позже дня, месяца или года
+//	on:2025-03           в течение дня, месяца или года
+//
+// Регистр и буква «ё» не различаются. Индекс слов строится при загрузке и
+// перестраивается после каждого изменения данных.
+
+// Виды условий поиска
+const (
+	searchWord     = "word"
+	searchPhrase   = "phrase"
+	searchAmount   = "amount"
+	searchCurrency = "currency"
+	searchType     = "type"
+	searchCategory = "category"
+	searchAccount  = "account"
+	searchBefore   = "before"
+	searchAfter    = "after"
+	searchOn       = "on"
+)
+
+// searchTerm — одно условие запроса
+type searchTerm struct {
+	kind   string
+	negate bool
+	text   string    // Слово, фраза или значение поля в нормализованном виде
+	op     string    // Сравнение суммы: "=", ">", ">=", "<", "<=" или ".."
+	amount float64   // Сумма или нижняя граница диапазона
+	max    float64   // Верхняя граница диапазона
+	from   time.Time // Начало дня, месяца или года
+	to     time.Time // Конец (не включая)
+}
+
+// SearchQuery — разобранный поисковый запрос. Пустой запрос подходит под
+// любую операцию.
+type SearchQuery struct {
+	terms []searchTerm
+}
+
+// IsEmpty сообщает, что в запросе нет условий
+func (q SearchQuery) IsEmpty() bool {
+	return len(q.terms) == 0
+}
+
+// normalizeSearchText приводит текст к виду для поиска: нижний регистр, «ё»
+// заменена на «е»
+func normalizeSearchText(text string) string {
+	return strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+}
+
+// searchTokens разбивает текст на нормализованные слова
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(normalizeSearchText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// splitSearchQuery разбивает запрос на условия с учётом кавычек: кавычки
+// могут окружать всё условие или значение поля (category:"Кафе и рестораны")
+func splitSearchQuery(query string) ([]string, error) {
+	parts := []string{}
+	var current strings.Builder
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				parts = append(parts, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("не закрыта кавычка")
+	}
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+	return parts, nil
+}
+
+// parseSearchPeriod разбирает день ГГГГ-ММ-ДД, месяц ГГГГ-ММ или год ГГГГ в
+// местном времени
+func parseSearchPeriod(value string) (time.Time, time.Time, bool) {
+	for _, p := range []struct {
+		layout           string
+		years, months, d int
+	}{
+		{"2006-01-02", 0, 0, 1},
+		{"2006-01", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	} {
+		if t, err := time.ParseInLocation(p.layout, value, time.Local); err == nil {
+			return t, t.AddDate(p.years, p.months, p.d), true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// parseSearchAmount разбирает условие на сумму
+func parseSearchAmount(value string) (searchTerm, bool) {
+	term := searchTerm{kind: searchAmount}
+	value = strings.ReplaceAll(value, ",", ".")
+	if i := strings.Index(value, ".."); i >= 0 {
+		low, errLow := strconv.ParseFloat(value[:i], 64)
+		high, errHigh := strconv.ParseFloat(value[i+2:], 64)
+		if errLow != nil || errHigh != nil || low > high {
+			return term, false
+		}
+		term.op, term.amount, term.max = "..", low, high
+		return term, true
+	}
+	term.op = "="
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, op) {
+			term.op = op
+			value = value[len(op):]
+			break
+		}
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return term, false
+	}
+	term.amount = amount
+	return term, true
+}
+
+// ParseSearchQuery разбирает поисковый запрос. Ошибка описывает первое
+// неверное условие.
+func ParseSearchQuery(query string) (SearchQuery, error) {
+	parts, err := splitSearchQuery(query)
+	if err != nil {
+		return SearchQuery{}, err
+	}
+
+	result := SearchQuery{}
+	for _, part := range parts {
+		negate := false
+		if len(part) > 1 && part[0] == '-' {
+			negate = true
+			part = part[1:]
+		}
+
+		// Фраза в кавычках
+		if strings.HasPrefix(part, `"`) {
+			phrase := strings.Join(searchTokens(strings.Trim(part, `"`)), " ")
+			if phrase != "" {
+				result.terms = append(result.terms, searchTerm{kind: searchPhrase, negate: negate, text: phrase})
+			}
+			continue
+		}
+
+		// Условие на поле
+		if i := strings.IndexByte(part, ':'); i > 0 && isSearchField(part[:i]) {
+			field := strings.ToLower(part[:i])
+			value := strings.Trim(part[i+1:], `"`)
+			if value == "" {
+				return SearchQuery{}, fmt.Errorf("не указано значение в %q", part)
+			}
+			term := searchTerm{kind: field, negate: negate, text: normalizeSearchText(value)}
+			switch field {
+			case searchAmount:
+				amountTerm, ok := parseSearchAmount(value)
+				if !ok {
+					return SearchQuery{}, fmt.Errorf("неверная сумма в %q: ожидается amount:50, amount:>50 или amount:10..50", part)
+				}
+				amountTerm.negate = negate
+				term = amountTerm
+			case searchCurrency:
+				term.text = strings.ToUpper(value)
+			case searchType:
+				if term.text != "income" && term.text != "expense" && term.text != models.KindTransfer && term.text != models.KindExchange {
+					return SearchQuery{}, fmt.Errorf("неверный тип в %q: допустимы income, expense, transfer, exchange", part)
+				}
+			case searchBefore, searchAfter, searchOn:
+				from, to, ok := parseSearchPeriod(value)
+				if !ok {
+					return SearchQuery{}, fmt.Errorf("неверная дата в %q: ожидается ГГГГ-ММ-ДД, ГГГГ-ММ или ГГГГ", part)
+				}
+				term.from, term.to = from, to
+			}
+			result.terms = append(result.terms, term)
+			continue
+		}
+
+		// Слово из нескольких частей (кофе-брейк) ищется как фраза
+		switch words := searchTokens(part); len(words) {
+		case 0:
+		case 1:
+			result.terms = append(result.terms, searchTerm{kind: searchWord, negate: negate, text: words[0]})
+		default:
+			result.terms = append(result.terms, searchTerm{kind: searchPhrase, negate: negate, text: strings.Join(words, " ")})
+		}
+	}
+	return result, nil
+}
+
+// isSearchField сообщает, что name — имя поля запроса
+func isSearchField(name string) bool {
+	switch strings.ToLower(name) {
+	case searchAmount, searchCurrency, searchType, searchCategory, searchAccount, searchBefore, searchAfter, searchOn:
+		return true
+	}
+	return false
+}
+
+// searchDocument — проиндексированный текст операции
+type searchDocument struct {
+	text   string   // Слова описания и заметок через пробел
+	tokens []string // Те же слова
+}
+
+// searchIndex — обратный индекс слов описаний и заметок. Документы
+// соответствуют операциям по позиции в data.Transactions.
+type searchIndex struct {
+	documents []searchDocument
+	words     []string         // Все слова по алфавиту для поиска по началу слова
+	postings  map[string][]int // Слово → позиции операций по возрастанию
+}
+
+// buildSearchIndex строит индекс по операциям
+func buildSearchIndex(transactions []models.Transaction) *searchIndex {
+	index := &searchIndex{
+		documents: make([]searchDocument, len(transactions)),
+		postings:  make(map[string][]int),
+	}
+	for i, t := range transactions {
+		tokens := searchTokens(t.Description + " " + t.Notes)
+		index.documents[i] = searchDocument{text: strings.Join(tokens, " "), tokens: tokens}
+		for _, token := range tokens {
+			list := index.postings[token]
+			if len(list) > 0 && list[len(list)-1] == i {
+				continue
+			}
+			if len(list) == 0 {
+				index.words = append(index.words, token)
+			}
+			index.postings[token] = append(list, i)
+		}
+	}
+	sort.Strings(index.words)
+	return index
+}
+
+// prefixPositions возвращает позиции операций, в тексте которых есть слово,
+// начинающееся с prefix
+func (index *searchIndex) prefixPositions(prefix string) map[int]bool {
+	result := make(map[int]bool)
+	for i := sort.SearchStrings(index.words, prefix); i < len(index.words) && strings.HasPrefix(index.words[i], prefix); i++ {
+		for _, position := range index.postings[index.words[i]] {
+			result[position] = true
+		}
+	}
+	return result
+}
+
+// candidates сужает поиск по словам запроса без отрицания. nil означает, что
+// слов нет и проверять нужно все операции.
+func (index *searchIndex) candidates(q SearchQuery) []int {
+	var set map[int]bool
+	for _, term := range q.terms {
+		if term.negate || term.kind != searchWord && term.kind != searchPhrase {
+			continue
+		}
+		for _, word := range strings.Fields(term.text) {
+			positions := index.prefixPositions(word)
+			if set == nil {
+				set = positions
+				continue
+			}
+			for position := range set {
+				if !positions[position] {
+					delete(set, position)
+				}
+			}
+		}
+		if len(set) == 0 {
+			return []int{}
+		}
+	}
+	if set == nil {
+		return nil
+	}
+	result := make([]int, 0, len(set))
+	for position := range set {
+		result = append(result, position)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// searchContext — справочники для условий на категорию и счёт
+type searchContext struct {
+	categories map[int]string
+	accounts   map[int]string
+}
+
+// matchTerm проверяет одно условие без учёта отрицания
+func (term searchTerm) matchTerm(t models.Transaction, document searchDocument, ctx searchContext) bool {
+	switch term.kind {
+	case searchWord:
+		for _, token := range document.tokens {
+			if strings.HasPrefix(token, term.text) {
+				return true
+			}
+		}
+		return false
+	case searchPhrase:
+		return strings.Contains(document.text, term.text)
+	case searchAmount:
+		const epsilon = 0.005
+		switch term.op {
+		case ">":
+			return t.Amount > term.amount
+		case ">=":
+			return t.Amount >= term.amount-epsilon
+		case "<":
+			return t.Amount < term.amount
+		case "<=":
+			return t.Amount <= term.amount+epsilon
+		case "..":
+			return t.Amount >= term.amount-epsilon && t.Amount <= term.max+epsilon
+		}
+		return math.Abs(t.Amount-term.amount) < epsilon
+	case searchCurrency:
+		return t.Currency == term.text
+	case searchType:
+		switch term.text {
+		case "income":
+			return t.IsPositive && !t.IsInternal()
+		case "expense":
+			return !t.IsPositive && !t.IsInternal()
+		}
+		return t.Kind == term.text
+	case searchCategory:
+		return strings.HasPrefix(ctx.categories[t.CategoryID], term.text)
+	case searchAccount:
+		return strings.HasPrefix(ctx.accounts[t.AccountID], term.text)
+	case searchBefore:
+		return t.DateTime.Before(term.from)
+	case searchAfter:
+		return !t.DateTime.Before(term.to)
+	case searchOn:
+		return !t.DateTime.Before(term.from) && t.DateTime.Before(term.to)
+	}
+	return false
+}
+
+// reindex перестраивает индекс поиска. Вызывается под мьютексом после
+// загрузки и каждого сохранённого изменения.
+func (s *FinanceStorage) reindex() {
+	s.search = buildSearchIndex(s.data.Transactions)
+}
+
+// SearchTransactions возвращает копии операций, подходящих под запрос и, если
+// match не nil, под условие match
+func (s *FinanceStorage) SearchTransactions(q SearchQuery, match func(t models.Transaction) bool) []models.Transaction {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.searchTransactions(q, match)
+}
+
+// SearchSnapshot — то же, что SearchTransactions, но вместе с найденными
+// операциями возвращает копию данных, снятую под тем же мьютексом. Названия
+// категорий и счетов в копии соответствуют найденным операциям, даже если
+// данные изменились сразу после вызова.
+func (s *FinanceStorage) SearchSnapshot(q SearchQuery, match func(t models.Transaction) bool) (*models.FinanceData, []models.Transaction) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data := copyFinanceData(&s.data)
+	return &data, s.searchTransactions(q, match)
+}
+
+// searchTransactions — то же, что SearchTransactions, для вызова под мьютексом
+func (s *FinanceStorage) searchTransactions(q SearchQuery, match func(t models.Transaction) bool) []models.Transaction {
+	if s.search == nil {
+		s.reindex()
+	}
+	ctx := searchContext{categories: make(map[int]string), accounts: make(map[int]string)}
+	for _, c := range s.data.Categories {
+		ctx.categories[c.ID] = normalizeSearchText(c.Name)
+	}
+	for _, a := range s.data.Accounts {
+		ctx.accounts[a.ID] = normalizeSearchText(a.Name)
+	}
+
+	positions := s.search.candidates(q)
+	if positions == nil {
+		positions = make([]int, len(s.data.Transactions))
+		for i := range positions {
+			positions[i] = i
+		}
+	}
+
+	result := []models.Transaction{}
+	for _, position := range positions {
+		t := s.data.Transactions[position]
+		matched := true
+		for _, term := range q.terms {
+			if term.matchTerm(t, s.search.documents[position], ctx) == term.negate {
+				matched = false
+				break
+			}
+		}
+		if matched && (match == nil || match(t)) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
@@ -0,0 +1,513 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"finance-tracker/models"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteBackend хранит финансы и табель во встроенной базе SQLite. Поля, по
+// которым ведётся поиск, вынесены в индексированные столбцы, а сама запись
+// хранится в JSON, поэтому новые поля моделей не требуют миграций. Справочники
+// (счета, категории, бюджеты, шаблоны, курсы) небольшие и хранятся одним
+// документом.
+//
+// При сохранении записываются только изменившиеся операции и записи табеля.
+type SQLiteBackend struct {
+	db   *sql.DB
+	path string
+
+	// Последнее сохранённое состояние записей для поиска изменений
+	mutex             sync.Mutex
+	savedTransactions map[int]string
+	savedEntries      map[string]string
+}
+
+// Кроме бэкенда, база даёт утилитам доступ к отдельным записям
+var (
+	_ FinanceBackend        = (*SQLiteBackend)(nil)
+	_ WorkLogBackend        = (*SQLiteBackend)(nil)
+	_ TransactionRepository = (*SQLiteBackend)(nil)
+	_ WorkEntryRepository   = (*SQLiteBackend)(nil)
+)
+
+// sqliteMigrations применяются по порядку; номер версии — индекс + 1.
+// Уже применённые миграции менять нельзя, только добавлять новые.
+var sqliteMigrations = []string{
+	`CREATE TABLE transactions (
+		id          INTEGER PRIMARY KEY,
+		date_ns     INTEGER NOT NULL,
+		currency    TEXT    NOT NULL,
+		account_id  INTEGER NOT NULL DEFAULT 0,
+		category_id INTEGER NOT NULL DEFAULT 0,
+		is_positive INTEGER NOT NULL,
+		data        TEXT    NOT NULL
+	);
+	CREATE INDEX idx_transactions_date ON transactions(date_ns);
+	CREATE INDEX idx_transactions_account ON transactions(account_id, date_ns);
+	CREATE TABLE work_entries (
+		date TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	);
+	CREATE TABLE documents (
+		name TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	);`,
+}
+
+const (
+	financeDocument = "finance"
+	workLogDocument = "worklog"
+)
+
+// OpenSQLite открывает (или создаёт) базу и применяет недостающие миграции
+func OpenSQLite(path string) (*SQLiteBackend, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при открытии базы: %v", err)
+	}
+	// Один писатель: SQLite не выигрывает от параллельных соединений на запись
+	db.SetMaxOpenConns(1)
+
+	b := &SQLiteBackend{
+		db:                db,
+		path:              path,
+		savedTransactions: make(map[int]string),
+		savedEntries:      make(map[string]string),
+	}
+	if err := b.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *SQLiteBackend) migrate() error {
+	if _, err := b.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("ошибка при создании таблицы миграций: %v", err)
+	}
+
+	var current int
+	if err := b.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("ошибка при чтении версии схемы: %v", err)
+	}
+
+	for i := current; i < len(sqliteMigrations); i++ {
+		version := i + 1
+		tx, err := b.db.Begin()
+		if err != nil {
+			return fmt.Errorf("ошибка миграции %d: %v", version, err)
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("ошибка миграции %d: %v", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			version, time.Now().Format(time.RFC3339)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("ошибка миграции %d: %v", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("ошибка миграции %d: %v", version, err)
+		}
+		fmt.Printf("Применена миграция базы: %d\n", version)
+	}
+	return nil
+}
+
+func (b *SQLiteBackend) Close() error {
+	return b.db.Close()
+}
+
+// Counts возвращает число операций и записей табеля в базе
+func (b *SQLiteBackend) Counts() (transactions, entries int, err error) {
+	if err := b.db.QueryRow(`SELECT COUNT(*) FROM transactions`).Scan(&transactions); err != nil {
+		return 0, 0, fmt.Errorf("ошибка при подсчёте операций: %v", err)
+	}
+	if err := b.db.QueryRow(`SELECT COUNT(*) FROM work_entries`).Scan(&entries); err != nil {
+		return 0, 0, fmt.Errorf("ошибка при подсчёте записей табеля: %v", err)
+	}
+	return transactions, entries, nil
+}
+
+func (b *SQLiteBackend) Describe() string {
+	return "база SQLite " + b.path
+}
+
+// FlushFinance и FlushWorkLog ничего не делают: каждое сохранение уже
+// зафиксировано транзакцией базы
+func (b *SQLiteBackend) FlushFinance(data *models.FinanceData) error { return nil }
+func (b *SQLiteBackend) FlushWorkLog(data *models.WorkLogData) error { return nil }
+
+// sqlExecer — общее у *sql.DB и *sql.Tx
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func putTransaction(db sqlExecer, t models.Transaction, data string) error {
+	_, err := db.Exec(`INSERT INTO transactions (id, date_ns, currency, account_id, category_id, is_positive, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET date_ns = excluded.date_ns, currency = excluded.currency,
+			account_id = excluded.account_id, category_id = excluded.category_id,
+			is_positive = excluded.is_positive, data = excluded.data`,
+		t.ID, t.DateTime.UnixNano(), t.Currency, t.AccountID, t.CategoryID, t.IsPositive, data)
+	if err != nil {
+		return fmt.Errorf("ошибка при записи операции %d: %v", t.ID, err)
+	}
+	return nil
+}
+
+func putWorkEntry(db sqlExecer, entry models.WorkEntry, data string) error {
+	_, err := db.Exec(`INSERT INTO work_entries (date, data) VALUES (?, ?)
+		ON CONFLICT(date) DO UPDATE SET data = excluded.data`, entry.Date, data)
+	if err != nil {
+		return fmt.Errorf("ошибка при записи табеля за %s: %v", entry.Date, err)
+	}
+	return nil
+}
+
+func putDocument(db sqlExecer, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("ошибка при кодировании в JSON: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO documents (name, data) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET data = excluded.data`, name, string(data))
+	if err != nil {
+		return fmt.Errorf("ошибка при записи документа %s: %v", name, err)
+	}
+	return nil
+}
+
+// loadDocument декодирует документ в v и сообщает, существует ли он
+func (b *SQLiteBackend) loadDocument(name string, v interface{}) (bool, error) {
+	var data string
+	err := b.db.QueryRow(`SELECT data FROM documents WHERE name = ?`, name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("ошибка при чтении документа %s: %v", name, err)
+	}
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return false, fmt.Errorf("ошибка при декодировании документа %s: %v", name, err)
+	}
+	return true, nil
+}
+
+func (b *SQLiteBackend) LoadFinance(data *models.FinanceData) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	found, err := b.loadDocument(financeDocument, data)
+	if err != nil || !found {
+		return found, err
+	}
+
+	rows, err := b.db.Query(`SELECT id, data FROM transactions ORDER BY date_ns`)
+	if err != nil {
+		return false, fmt.Errorf("ошибка при чтении операций: %v", err)
+	}
+	defer rows.Close()
+
+	data.Transactions = []models.Transaction{}
+	b.savedTransactions = make(map[int]string)
+	for rows.Next() {
+		var id int
+		var raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			return false, fmt.Errorf("ошибка при чтении операций: %v", err)
+		}
+		var t models.Transaction
+		if err := json.Unmarshal([]byte(raw), &t); err != nil {
+			return false, fmt.Errorf("ошибка при декодировании операции %d: %v", id, err)
+		}
+		data.Transactions = append(data.Transactions, t)
+		b.savedTransactions[id] = raw
+	}
+	return true, rows.Err()
+}
+
+// SaveFinance записывает в одной транзакции справочники и только те операции,
+// которые изменились с прошлого сохранения
+func (b *SQLiteBackend) SaveFinance(data *models.FinanceData) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	current := make(map[int]string, len(data.Transactions))
+	byID := make(map[int]models.Transaction, len(data.Transactions))
+	for _, t := range data.Transactions {
+		raw, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Errorf("ошибка при кодировании в JSON: %v", err)
+		}
+		current[t.ID] = string(raw)
+		byID[t.ID] = t
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Справочники без операций
+	meta := *data
+	meta.Transactions = nil
+	if err := putDocument(tx, financeDocument, meta); err != nil {
+		return err
+	}
+
+	for id, raw := range current {
+		if b.savedTransactions[id] == raw {
+			continue
+		}
+		if err := putTransaction(tx, byID[id], raw); err != nil {
+			return err
+		}
+	}
+	for id := range b.savedTransactions {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		if _, err := tx.Exec(`DELETE FROM transactions WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("ошибка при удалении операции %d: %v", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка при сохранении в базу: %v", err)
+	}
+	b.savedTransactions = current
+	return nil
+}
+
+func (b *SQLiteBackend) Transaction(id int) (models.Transaction, error) {
+	var raw string
+	err := b.db.QueryRow(`SELECT data FROM transactions WHERE id = ?`, id).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Transaction{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Transaction{}, fmt.Errorf("ошибка при чтении операции %d: %v", id, err)
+	}
+	var t models.Transaction
+	if err := json.Unmarshal([]byte(raw), &t); err != nil {
+		return models.Transaction{}, fmt.Errorf("ошибка при декодировании операции %d: %v", id, err)
+	}
+	return t, nil
+}
+
+func (b *SQLiteBackend) TransactionsBetween(from, to time.Time) ([]models.Transaction, error) {
+	rows, err := b.db.Query(`SELECT data FROM transactions WHERE date_ns BETWEEN ? AND ? ORDER BY date_ns`,
+		from.UnixNano(), to.UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении операций: %v", err)
+	}
+	defer rows.Close()
+
+	result := []models.Transaction{}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении операций: %v", err)
+		}
+		var t models.Transaction
+		if err := json.Unmarshal([]byte(raw), &t); err != nil {
+			return nil, fmt.Errorf("ошибка при декодировании операции: %v", err)
+		}
+		result = append(result, t)
+	}
+	return result, rows.Err()
+}
+
+func (b *SQLiteBackend) PutTransaction(t models.Transaction) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("ошибка при кодировании в JSON: %v", err)
+	}
+	if err := putTransaction(b.db, t, string(raw)); err != nil {
+		return err
+	}
+	b.savedTransactions[t.ID] = string(raw)
+	return nil
+}
+
+func (b *SQLiteBackend) DeleteTransaction(id int) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	result, err := b.db.Exec(`DELETE FROM transactions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении операции %d: %v", id, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	delete(b.savedTransactions, id)
+	return nil
+}
+
+func (b *SQLiteBackend) LoadWorkLog(data *models.WorkLogData) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	found, err := b.loadDocument(workLogDocument, data)
+	if err != nil || !found {
+		return found, err
+	}
+
+	rows, err := b.db.Query(`SELECT date, data FROM work_entries ORDER BY date`)
+	if err != nil {
+		return false, fmt.Errorf("ошибка при чтении табеля: %v", err)
+	}
+	defer rows.Close()
+
+	data.Entries = []models.WorkEntry{}
+	b.savedEntries = make(map[string]string)
+	for rows.Next() {
+		var date, raw string
+		if err := rows.Scan(&date, &raw); err != nil {
+			return false, fmt.Errorf("ошибка при чтении табеля: %v", err)
+		}
+		var entry models.WorkEntry
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			return false, fmt.Errorf("ошибка при декодировании табеля за %s: %v", date, err)
+		}
+		data.Entries = append(data.Entries, entry)
+		b.savedEntries[date] = raw
+	}
+	return true, rows.Err()
+}
+
+// SaveWorkLog записывает только изменившиеся записи табеля
+func (b *SQLiteBackend) SaveWorkLog(data *models.WorkLogData) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	current := make(map[string]string, len(data.Entries))
+	byDate := make(map[string]models.WorkEntry, len(data.Entries))
+	for _, entry := range data.Entries {
+		raw, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("ошибка при кодировании в JSON: %v", err)
+		}
+		current[entry.Date] = string(raw)
+		byDate[entry.Date] = entry
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	meta := *data
+	meta.Entries = nil
+	if err := putDocument(tx, workLogDocument, meta); err != nil {
+		return err
+	}
+
+	for date, raw := range current {
+		if b.savedEntries[date] == raw {
+			continue
+		}
+		if err := putWorkEntry(tx, byDate[date], raw); err != nil {
+			return err
+		}
+	}
+	for date := range b.savedEntries {
+		if _, ok := current[date]; ok {
+			continue
+		}
+		if _, err := tx.Exec(`DELETE FROM work_entries WHERE date = ?`, date); err != nil {
+			return fmt.Errorf("ошибка при удалении табеля за %s: %v", date, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка при сохранении в базу: %v", err)
+	}
+	b.savedEntries = current
+	return nil
+}
+
+func (b *SQLiteBackend) WorkEntry(date string) (models.WorkEntry, error) {
+	var raw string
+	err := b.db.QueryRow(`SELECT data FROM work_entries WHERE date = ?`, date).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.WorkEntry{}, ErrNotFound
+	}
+	if err != nil {
+		return models.WorkEntry{}, fmt.Errorf("ошибка при чтении табеля за %s: %v", date, err)
+	}
+	var entry models.WorkEntry
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		return models.WorkEntry{}, fmt.Errorf("ошибка при декодировании табеля за %s: %v", date, err)
+	}
+	return entry, nil
+}
+
+func (b *SQLiteBackend) WorkEntriesBetween(from, to string) ([]models.WorkEntry, error) {
+	rows, err := b.db.Query(`SELECT data FROM work_entries WHERE date BETWEEN ? AND ? ORDER BY date`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении табеля: %v", err)
+	}
+	defer rows.Close()
+
+	result := []models.WorkEntry{}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении табеля: %v", err)
+		}
+		var entry models.WorkEntry
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			return nil, fmt.Errorf("ошибка при декодировании табеля: %v", err)
+		}
+		result = append(result, entry)
+	}
+	return result, rows.Err()
+}
+
+func (b *SQLiteBackend) PutWorkEntry(entry models.WorkEntry) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("ошибка при кодировании в JSON: %v", err)
+	}
+	if err := putWorkEntry(b.db, entry, string(raw)); err != nil {
+		return err
+	}
+	b.savedEntries[entry.Date] = string(raw)
+	return nil
+}
+
+func (b *SQLiteBackend) DeleteWorkEntry(date string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	result, err := b.db.Exec(`DELETE FROM work_entries WHERE date = ?`, date)
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении табеля за %s: %v", date, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	delete(b.savedEntries, date)
+	return nil
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"bytes"
+	"finance-tracker/models"
+	"fmt"
+	"strings"
+)
+
+// Форматы банковских выписок
+const (
+	StatementOFX  = "ofx"
+	StatementQIF  = "qif"
+	StatementCAMT = "camt053"
+)
+
+// StatementTitles — названия форматов выписок
+var StatementTitles = map[string]string{
+	StatementOFX:  "OFX",
+	StatementQIF:  "QIF",
+	StatementCAMT: "ISO 20022 CAMT.053",
+}
+
+// Statement — разобранная банковская выписка. Строки выписки уже содержат
+// операции; ID операции в банке сохраняется в ExternalID вместе с номером
+// счёта, чтобы одна и та же строка не импортировалась дважды.
+type Statement struct {
+	Format   string
+	Account  string // Номер счёта в банке или IBAN; пусто, если в выписке его нет
+	Currency string // Валюта счёта; пусто, если в выписке её нет
+	Rows     []ImportRow
+}
+
+// DetectStatementFormat определяет формат выписки по содержимому. Пустая
+// строка означает, что файл не похож ни на один формат выписки.
+func DetectStatementFormat(body []byte) string {
+	head := body
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	text := strings.TrimSpace(string(head))
+	upper := strings.ToUpper(text)
+	switch {
+	case strings.HasPrefix(upper, "!TYPE:") || strings.HasPrefix(upper, "!ACCOUNT") || strings.HasPrefix(upper, "!OPTION:"):
+		return StatementQIF
+	case strings.HasPrefix(upper, "OFXHEADER") || strings.Contains(upper, "<OFX>") || strings.Contains(upper, "<?OFX"):
+		return StatementOFX
+	case strings.Contains(text, "camt.053") || strings.Contains(text, "BkToCstmrStmt"):
+		return StatementCAMT
+	}
+	return ""
+}
+
+// ParseStatement разбирает выписку в формате format. defaultCurrency задаёт
+// валюту операций, если выписка её не содержит (QIF).
+func ParseStatement(body []byte, format, defaultCurrency string) (Statement, error) {
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	var statement Statement
+	var err error
+	switch format {
+	case StatementOFX:
+		statement, err = parseOFX(string(body))
+	case StatementQIF:
+		statement, err = parseQIF(string(body))
+	case StatementCAMT:
+		statement, err = parseCAMT(body)
+	default:
+		return Statement{}, fmt.Errorf("неизвестный формат выписки")
+	}
+	if err != nil {
+		return Statement{}, err
+	}
+	statement.Format = format
+
+	for i := range statement.Rows {
+		t := &statement.Rows[i].Transaction
+		if t.Currency == "" {
+			t.Currency = statement.Currency
+		}
+		if t.Currency == "" {
+			t.Currency = strings.ToUpper(defaultCurrency)
+		}
+		if t.ExternalID != "" && statement.Account != "" {
+			t.ExternalID = statement.Account + ":" + t.ExternalID
+		}
+	}
+	return statement, nil
+}
+
+// statementRow возвращает строку выписки. Сумма со знаком: отрицательная —
+// расход.
+func statementRow(number int, t models.Transaction, amount float64) ImportRow {
+	if amount == 0 {
+		return invalidImportRow(number, "нулевая сумма")
+	}
+	t.IsPositive = amount > 0
+	if amount < 0 {
+		amount = -amount
+	}
+	t.Amount = amount
+	if t.Description == "" {
+		t.Description = "Операция по счёту"
+	}
+	return ImportRow{Line: number, Status: ImportReady, Transaction: t}
+}
+
+// FindBankAccount возвращает счёт, сопоставленный с номером счёта в банке
+func FindBankAccount(data *models.FinanceData, bankAccount string) (models.Account, bool) {
+	if bankAccount == "" {
+		return models.Account{}, false
+	}
+	for _, a := range data.Accounts {
+		if a.BankAccount == bankAccount && !a.Archived {
+			return a, true
+		}
+	}
+	return models.Account{}, false
+}
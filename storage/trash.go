@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"errors"
+	"finance-tracker/models"
+	"time"
+)
+
+// Удалённые операции и записи табеля не стираются сразу, а переносятся в
+// корзину внутри тех же данных: так они сохраняются любым бэкендом и попадают
+// в резервные копии. Из корзины записи возвращаются на место или удаляются
+// окончательно; записи старше срока хранения удаляет планировщик.
+
+// DefaultTrashDays — сколько дней записи хранятся в корзине по умолчанию
+const DefaultTrashDays = 30
+
+// errNoChanges прерывает update, когда менять нечего, чтобы не сохранять
+// данные впустую
+var errNoChanges = errors.New("нет изменений")
+
+// RemoveTransactions переносит в корзину операции с указанными ID вместе с
+// парными операциями переводов и обменов и возвращает ID удалённых операций.
+// Вызывается внутри Update.
+func RemoveTransactions(data *models.FinanceData, ids []int) []int {
+	remove := linkedTransactionIDs(data.Transactions, ids)
+
+	now := time.Now()
+	removed := []int{}
+	kept := make([]models.Transaction, 0, len(data.Transactions))
+	for _, t := range data.Transactions {
+		if remove[t.ID] {
+			removed = append(removed, t.ID)
+			data.Trash = append(data.Trash, models.DeletedTransaction{Transaction: t, DeletedAt: now})
+			continue
+		}
+		kept = append(kept, t)
+	}
+	data.Transactions = kept
+	return removed
+}
+
+// RestoreTransactions возвращает из корзины операции с указанными ID вместе
+// с парными операциями и возвращает ID восстановленных операций. Если счёт
+// операции удалён, она переносится на счёт по умолчанию для её валюты, если
+// удалена категория — остаётся без категории.
+func RestoreTransactions(data *models.FinanceData, ids []int) []int {
+	trashed := make([]models.Transaction, len(data.Trash))
+	for i, d := range data.Trash {
+		trashed[i] = d.Transaction
+	}
+	restore := linkedTransactionIDs(trashed, ids)
+
+	accounts := make(map[int]bool, len(data.Accounts))
+	for _, a := range data.Accounts {
+		accounts[a.ID] = true
+	}
+	categories := make(map[int]bool, len(data.Categories))
+	for _, c := range data.Categories {
+		categories[c.ID] = true
+	}
+
+	restored := []int{}
+	kept := make([]models.DeletedTransaction, 0, len(data.Trash))
+	for _, d := range data.Trash {
+		if !restore[d.ID] {
+			kept = append(kept, d)
+			continue
+		}
+		t := d.Transaction
+		if !accounts[t.AccountID] {
+			t.AccountID = DefaultAccountID(data, t.Currency)
+		}
+		if !categories[t.CategoryID] {
+			t.CategoryID = 0
+		}
+		data.Transactions = append(data.Transactions, t)
+		restored = append(restored, t.ID)
+	}
+	data.Trash = kept
+	return restored
+}
+
+// PurgeTransactions окончательно удаляет операции из корзины. Пустой ids
+// очищает корзину целиком. Возвращает число удалённых операций.
+func PurgeTransactions(data *models.FinanceData, ids []int) int {
+	purge := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		purge[id] = true
+	}
+	return purgeTransactions(data, func(d models.DeletedTransaction) bool {
+		return len(ids) == 0 || purge[d.ID] || purge[d.LinkedID]
+	})
+}
+
+func purgeTransactions(data *models.FinanceData, match func(d models.DeletedTransaction) bool) int {
+	kept := make([]models.DeletedTransaction, 0, len(data.Trash))
+	for _, d := range data.Trash {
+		if !match(d) {
+			kept = append(kept, d)
+		}
+	}
+	purged := len(data.Trash) - len(kept)
+	data.Trash = kept
+	return purged
+}
+
+// linkedTransactionIDs дополняет ids парными операциями переводов и обменов
+func linkedTransactionIDs(transactions []models.Transaction, ids []int) map[int]bool {
+	result := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		result[id] = true
+	}
+	for _, t := range transactions {
+		if result[t.ID] && t.LinkedID != 0 {
+			result[t.LinkedID] = true
+		}
+	}
+	return result
+}
+
+// RemoveWorkEntries переносит в корзину записи табеля за указанные даты и
+// возвращает даты удалённых записей. Вызывается внутри Update.
+func RemoveWorkEntries(data *models.WorkLogData, dates []string) []string {
+	remove := make(map[string]bool, len(dates))
+	for _, date := range dates {
+		remove[date] = true
+	}
+
+	now := time.Now()
+	removed := []string{}
+	kept := make([]models.WorkEntry, 0, len(data.Entries))
+	for _, entry := range data.Entries {
+		if remove[entry.Date] {
+			removed = append(removed, entry.Date)
+			data.Trash = append(data.Trash, models.DeletedWorkEntry{WorkEntry: entry, DeletedAt: now})
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	data.Entries = kept
+	return removed
+}
+
+// RestoreWorkEntry возвращает из корзины запись табеля за дату. Если записи
+// в корзине нет, возвращается ErrNotFound, если за эту дату уже заведена
+// новая запись — ErrExists. Удалённых записей за одну дату может быть
+// несколько, возвращается последняя.
+func RestoreWorkEntry(data *models.WorkLogData, date string) error {
+	found := -1
+	for i, d := range data.Trash {
+		if d.Date == date && (found < 0 || !d.DeletedAt.Before(data.Trash[found].DeletedAt)) {
+			found = i
+		}
+	}
+	if found < 0 {
+		return ErrNotFound
+	}
+	for _, entry := range data.Entries {
+		if entry.Date == date {
+			return ErrExists
+		}
+	}
+	data.Entries = append(data.Entries, data.Trash[found].WorkEntry)
+	data.Trash = append(data.Trash[:found], data.Trash[found+1:]...)
+	return nil
+}
+
+// PurgeWorkEntries окончательно удаляет из корзины записи за указанные даты.
+// Пустой dates очищает корзину целиком.
+func PurgeWorkEntries(data *models.WorkLogData, dates []string) int {
+	purge := make(map[string]bool, len(dates))
+	for _, date := range dates {
+		purge[date] = true
+	}
+	return purgeWorkEntries(data, func(d models.DeletedWorkEntry) bool {
+		return len(dates) == 0 || purge[d.Date]
+	})
+}
+
+func purgeWorkEntries(data *models.WorkLogData, match func(d models.DeletedWorkEntry) bool) int {
+	kept := make([]models.DeletedWorkEntry, 0, len(data.Trash))
+	for _, d := range data.Trash {
+		if !match(d) {
+			kept = append(kept, d)
+		}
+	}
+	purged := len(data.Trash) - len(kept)
+	data.Trash = kept
+	return purged
+}
+
+// PurgeExpiredTrash окончательно удаляет операции, лежащие в корзине дольше
+// days дней, и сохраняет данные, если что-то удалено
+func (s *FinanceStorage) PurgeExpiredTrash(days int, now time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	before := now.AddDate(0, 0, -days)
+	purged := 0
+	err := s.update(SchedulerActor, copyFinanceData(&s.data), func(data *models.FinanceData) error {
+		purged = purgeTransactions(data, func(d models.DeletedTransaction) bool {
+			return d.DeletedAt.Before(before)
+		})
+		if purged == 0 {
+			return errNoChanges
+		}
+		return nil
+	})
+	if errors.Is(err, errNoChanges) {
+		return 0, nil
+	}
+	return purged, err
+}
+
+// PurgeExpiredTrash окончательно удаляет записи табеля, лежащие в корзине
+// дольше days дней
+func (s *WorkLogStorage) PurgeExpiredTrash(days int, now time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	before := now.AddDate(0, 0, -days)
+	purged := 0
+	err := s.update(SchedulerActor, copyWorkLogData(&s.data), func(data *models.WorkLogData) error {
+		purged = purgeWorkEntries(data, func(d models.DeletedWorkEntry) bool {
+			return d.DeletedAt.Before(before)
+		})
+		if purged == 0 {
+			return errNoChanges
+		}
+		return nil
+	})
+	if errors.Is(err, errNoChanges) {
+		return 0, nil
+	}
+	return purged, err
+}
@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"errors"
+	"finance-tracker/models"
+	"time"
+)
+
+// Отмена действий. Изменения, выполненные через Change, запоминаются в стеке
+// хранилища: какие записи добавлены, прежние версии изменённых и какие
+// перенесены в корзину. Undo отменяет последнее из них. Стек хранится только
+// в памяти и очищается при перезапуске и восстановлении из резервной копии.
+
+// Виды отменяемых действий
+const (
+	UndoAdd    = "add"
+	UndoEdit   = "edit"
+	UndoDelete = "delete"
+	UndoBulk   = "bulk" // Пакетное изменение: добавление, изменение и удаление сразу
+)
+
+const undoLimit = 50
+
+// ErrNothingToUndo возвращается, если отменять нечего
+var ErrNothingToUndo = errors.New("нет действий для отмены")
+
+// ErrUndoConflict возвращается, если записи, затронутые действием, позже
+// изменены так, что отменить его нельзя (например, удалены из корзины)
+var ErrUndoConflict = errors.New("действие нельзя отменить: записи изменены позже")
+
+// UndoAction описывает отменяемое действие
+type UndoAction struct {
+	Kind    string
+	At      time.Time
+	Records int // Число затронутых записей
+}
+
+type transactionUndo struct {
+	UndoAction
+	added   []int
+	edited  []models.Transaction // Версии до изменения
+	deleted []int
+}
+
+type workEntryUndo struct {
+	UndoAction
+	added   []string
+	edited  []models.WorkEntry
+	deleted []string
+}
+
+// Change выполняет изменение операций как Update и запоминает его для Undo.
+// kind — один из видов Undo*.
+func (s *FinanceStorage) Change(actor Actor, kind string, fn func(data *models.FinanceData) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	before := copyFinanceData(&s.data)
+	if err := s.update(actor, before, fn); err != nil {
+		return err
+	}
+	if action, ok := transactionChanges(kind, before.Transactions, s.data.Transactions); ok {
+		s.undo = append(s.undo, action)
+		if len(s.undo) > undoLimit {
+			s.undo = s.undo[len(s.undo)-undoLimit:]
+		}
+	}
+	return nil
+}
+
+// LastAction возвращает действие, которое отменит Undo
+func (s *FinanceStorage) LastAction() (UndoAction, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.undo) == 0 {
+		return UndoAction{}, false
+	}
+	return s.undo[len(s.undo)-1].UndoAction, true
+}
+
+// Undo отменяет последнее действие: добавленные операции удаляются
+// окончательно, изменённые возвращаются к прежним версиям, удалённые
+// возвращаются из корзины. Действие снимается со стека, даже если отменить
+// его не удалось из-за ErrUndoConflict.
+func (s *FinanceStorage) Undo(actor Actor) (UndoAction, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.undo) == 0 {
+		return UndoAction{}, ErrNothingToUndo
+	}
+	action := s.undo[len(s.undo)-1]
+	s.undo = s.undo[:len(s.undo)-1]
+
+	backup := copyFinanceData(&s.data)
+	err := s.update(actor, backup, func(data *models.FinanceData) error {
+		if len(RestoreTransactions(data, action.deleted)) != len(action.deleted) {
+			return ErrUndoConflict
+		}
+		index := make(map[int]int, len(data.Transactions))
+		for i, t := range data.Transactions {
+			index[t.ID] = i
+		}
+		for _, t := range action.edited {
+			i, ok := index[t.ID]
+			if !ok {
+				return ErrUndoConflict
+			}
+			data.Transactions[i] = t
+		}
+		added := make(map[int]bool, len(action.added))
+		for _, id := range action.added {
+			added[id] = true
+		}
+		kept := make([]models.Transaction, 0, len(data.Transactions))
+		for _, t := range data.Transactions {
+			if !added[t.ID] {
+				kept = append(kept, t)
+			}
+		}
+		data.Transactions = kept
+		purgeTransactions(data, func(d models.DeletedTransaction) bool { return added[d.ID] })
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrUndoConflict) {
+		s.undo = append(s.undo, action)
+	}
+	return action.UndoAction, err
+}
+
+// transactionChanges сравнивает операции до и после изменения
+func transactionChanges(kind string, before, after []models.Transaction) (transactionUndo, bool) {
+	action := transactionUndo{UndoAction: UndoAction{Kind: kind, At: time.Now()}}
+
+	old := make(map[int]models.Transaction, len(before))
+	for _, t := range before {
+		old[t.ID] = t
+	}
+	seen := make(map[int]bool, len(after))
+	for _, t := range after {
+		seen[t.ID] = true
+		prev, ok := old[t.ID]
+		switch {
+		case !ok:
+			action.added = append(action.added, t.ID)
+		case prev != t:
+			action.edited = append(action.edited, prev)
+		}
+	}
+	for _, t := range before {
+		if !seen[t.ID] {
+			action.deleted = append(action.deleted, t.ID)
+		}
+	}
+
+	action.Records = len(action.added) + len(action.edited) + len(action.deleted)
+	return action, action.Records > 0
+}
+
+// Change выполняет изменение табеля как Update и запоминает его для Undo
+func (s *WorkLogStorage) Change(actor Actor, kind string, fn func(data *models.WorkLogData) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.change(actor, kind, fn)
+}
+
+// change — то же, что Change, для вызова под мьютексом
+func (s *WorkLogStorage) change(actor Actor, kind string, fn func(data *models.WorkLogData) error) error {
+	before := copyWorkLogData(&s.data)
+	if err := s.update(actor, before, fn); err != nil {
+		return err
+	}
+	if action, ok := workEntryChanges(kind, before.Entries, s.data.Entries); ok {
+		s.undo = append(s.undo, action)
+		if len(s.undo) > undoLimit {
+			s.undo = s.undo[len(s.undo)-undoLimit:]
+		}
+	}
+	return nil
+}
+
+// LastAction возвращает действие с табелем, которое отменит Undo
+func (s *WorkLogStorage) LastAction() (UndoAction, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.undo) == 0 {
+		return UndoAction{}, false
+	}
+	return s.undo[len(s.undo)-1].UndoAction, true
+}
+
+// Undo отменяет последнее действие с табелем
+func (s *WorkLogStorage) Undo(actor Actor) (UndoAction, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.undo) == 0 {
+		return UndoAction{}, ErrNothingToUndo
+	}
+	action := s.undo[len(s.undo)-1]
+	s.undo = s.undo[:len(s.undo)-1]
+
+	backup := copyWorkLogData(&s.data)
+	err := s.update(actor, backup, func(data *models.WorkLogData) error {
+		added := make(map[string]bool, len(action.added))
+		for _, date := range action.added {
+			added[date] = true
+		}
+		kept := make([]models.WorkEntry, 0, len(data.Entries))
+		for _, entry := range data.Entries {
+			if !added[entry.Date] {
+				kept = append(kept, entry)
+			}
+		}
+		data.Entries = kept
+
+		for _, date := range action.deleted {
+			if err := RestoreWorkEntry(data, date); err != nil {
+				return ErrUndoConflict
+			}
+		}
+		for _, prev := range action.edited {
+			found := false
+			for i := range data.Entries {
+				if data.Entries[i].Date == prev.Date {
+					data.Entries[i] = prev
+					found = true
+					break
+				}
+			}
+			if !found {
+				return ErrUndoConflict
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrUndoConflict) {
+		s.undo = append(s.undo, action)
+	}
+	return action.UndoAction, err
+}
+
+// workEntryChanges сравнивает записи табеля до и после изменения
+func workEntryChanges(kind string, before, after []models.WorkEntry) (workEntryUndo, bool) {
+	action := workEntryUndo{UndoAction: UndoAction{Kind: kind, At: time.Now()}}
+
+	old := make(map[string]models.WorkEntry, len(before))
+	for _, entry := range before {
+		old[entry.Date] = entry
+	}
+	seen := make(map[string]bool, len(after))
+	for _, entry := range after {
+		seen[entry.Date] = true
+		prev, ok := old[entry.Date]
+		switch {
+		case !ok:
+			action.added = append(action.added, entry.Date)
+		case !sameJSON(prev, entry):
+			action.edited = append(action.edited, prev)
+		}
+	}
+	for _, entry := range before {
+		if !seen[entry.Date] {
+			action.deleted = append(action.deleted, entry.Date)
+		}
+	}
+
+	action.Records = len(action.added) + len(action.edited) + len(action.deleted)
+	return action, action.Records > 0
+}
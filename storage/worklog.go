@@ -2,22 +2,31 @@
 package storage
 
 import (
-	"encoding/json"
+	"errors"
 	"finance-tracker/models"
 	"fmt"
-	"os"
+	"sort"
 	"sync"
 )
 
 type WorkLogStorage struct {
-	data     models.WorkLogData
-	filePath string
-	mutex    sync.Mutex
+	data    models.WorkLogData
+	backend WorkLogBackend
+	backups *Backups
+	undo    []workEntryUndo
+	audit   *AuditLog
+	mutex   sync.Mutex
 }
 
+// NewWorkLogStorage создаёт хранилище табеля поверх JSON-файла
 func NewWorkLogStorage(filePath string) *WorkLogStorage {
+	return NewWorkLogStorageWithBackend(NewJSONWorkLogBackend(filePath))
+}
+
+// NewWorkLogStorageWithBackend создаёт хранилище табеля поверх произвольного бэкенда
+func NewWorkLogStorageWithBackend(backend WorkLogBackend) *WorkLogStorage {
 	return &WorkLogStorage{
-		filePath: filePath,
+		backend: backend,
 		data: models.WorkLogData{
 			Entries: []models.WorkEntry{},
 		},
@@ -28,47 +37,445 @@ func (s *WorkLogStorage) Load() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	fmt.Println("Загрузка данных табеля из файла:", s.filePath)
+	fmt.Println("Загрузка данных табеля:", s.backend.Describe())
 
-	if _, err := os.Stat(s.filePath); os.IsNotExist(err) {
-		fmt.Println("Файл табеля не существует, создаём новый")
+	found, err := s.backend.LoadWorkLog(&s.data)
+	if err != nil {
+		return err
+	}
+	if !found {
+		fmt.Println("Данные табеля не найдены, создаём новые")
 		return nil
 	}
 
-	fileData, err := os.ReadFile(s.filePath)
+	s.backups.createIfDue(BackupWorkLog, &s.data, len(s.data.Entries))
+
+	fmt.Printf("Загруженные записи табеля: %d\n", len(s.data.Entries))
+	return nil
+}
+
+func (s *WorkLogStorage) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.save()
+}
+
+// save — то же, что Save, для вызова под мьютексом
+func (s *WorkLogStorage) save() error {
+	fmt.Println("Сохранение данных табеля:", s.backend.Describe())
+
+	if err := s.backend.SaveWorkLog(&s.data); err != nil {
+		return err
+	}
+
+	fmt.Println("Данные табеля успешно сохранены")
+	s.backups.createIfDue(BackupWorkLog, &s.data, len(s.data.Entries))
+	return nil
+}
+
+// SetBackups включает резервное копирование табеля. Вызывается до Load.
+func (s *WorkLogStorage) SetBackups(backups *Backups) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.backups = backups
+	if backend, ok := s.backend.(backupFallback); ok {
+		backend.setBackupFallback(func(v interface{}) (BackupInfo, error) {
+			return backups.ReadNewest(BackupWorkLog, v)
+		})
+	}
+}
+
+// ListBackups возвращает резервные копии табеля от новых к старым
+func (s *WorkLogStorage) ListBackups() ([]BackupInfo, error) {
+	if s.backups == nil {
+		return nil, ErrBackupsDisabled
+	}
+	return s.backups.List(BackupWorkLog)
+}
+
+// CreateBackup создаёт резервную копию табеля по запросу пользователя
+func (s *WorkLogStorage) CreateBackup() (BackupInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.backups == nil {
+		return BackupInfo{}, ErrBackupsDisabled
+	}
+	return s.backups.Create(BackupWorkLog, &s.data, len(s.data.Entries), BackupManual)
+}
+
+// DiffBackup сравнивает резервную копию табеля name с текущими данными
+func (s *WorkLogStorage) DiffBackup(name string) (BackupInfo, WorkLogDiff, error) {
+	if s.backups == nil {
+		return BackupInfo{}, WorkLogDiff{}, ErrBackupsDisabled
+	}
+	var backup models.WorkLogData
+	info, err := s.backups.Read(BackupWorkLog, name, &backup)
 	if err != nil {
-		return fmt.Errorf("ошибка при чтении файла: %v", err)
+		return BackupInfo{}, WorkLogDiff{}, err
 	}
+	return info, DiffWorkLog(&backup, s.Snapshot()), nil
+}
 
-	if err := json.Unmarshal(fileData, &s.data); err != nil {
-		return fmt.Errorf("ошибка при декодировании JSON: %v", err)
+// RestoreBackup заменяет табель резервной копией name, предварительно
+// сохранив текущие данные в страховочную копию, которую и возвращает
+func (s *WorkLogStorage) RestoreBackup(actor Actor, name string) (BackupInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.backups == nil {
+		return BackupInfo{}, ErrBackupsDisabled
+	}
+	restored := models.WorkLogData{Entries: []models.WorkEntry{}}
+	if _, err := s.backups.Read(BackupWorkLog, name, &restored); err != nil {
+		return BackupInfo{}, err
+	}
+	safety, err := s.backups.Create(BackupWorkLog, &s.data, len(s.data.Entries), BackupPreRestore)
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("не удалось создать страховочную копию: %v", err)
 	}
 
-	fmt.Printf("Загруженные записи табеля: %d\n", len(s.data.Entries))
+	previous := s.data
+	s.data = restored
+	s.undo = nil
+	if err := s.save(); err != nil {
+		s.data = previous
+		return BackupInfo{}, err
+	}
+	s.audit.recordWorkLog(actor, &previous, &s.data)
+	fmt.Println("Данные табеля восстановлены из резервной копии", name)
+	return safety, nil
+}
+
+// Close вызывается при штатной остановке и дописывает отложенные изменения
+func (s *WorkLogStorage) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.backend.FlushWorkLog(&s.data)
+}
+
+// Snapshot возвращает копию данных табеля для чтения
+func (s *WorkLogStorage) Snapshot() *models.WorkLogData {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data := copyWorkLogData(&s.data)
+	return &data
+}
+
+// Update выполняет изменение табеля как одну транзакцию: мьютекс удерживается
+// на всё время чтения, изменения и сохранения. Если fn или сохранение вернули
+// ошибку, данные возвращаются к исходному состоянию. actor записывается в
+// журнал изменений как автор изменения.
+func (s *WorkLogStorage) Update(actor Actor, fn func(data *models.WorkLogData) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.update(actor, copyWorkLogData(&s.data), fn)
+}
+
+// update — то же, что Update, для вызова под мьютексом
+func (s *WorkLogStorage) update(actor Actor, backup models.WorkLogData, fn func(data *models.WorkLogData) error) error {
+	if err := fn(&s.data); err != nil {
+		s.data = backup
+		return err
+	}
+	if err := s.save(); err != nil {
+		s.data = backup
+		return err
+	}
+	s.audit.recordWorkLog(actor, &backup, &s.data)
 	return nil
 }
 
-func (s *WorkLogStorage) Save() error {
+// SetAuditLog включает журнал изменений табеля
+func (s *WorkLogStorage) SetAuditLog(audit *AuditLog) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	s.audit = audit
+}
 
-	fmt.Println("Сохранение данных табеля в файл:", s.filePath)
+// WorkTime возвращает расчёт рабочего времени по правилам табеля
+func (s *WorkLogStorage) WorkTime() WorkTime {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	fileData, err := json.MarshalIndent(s.data, "", "  ")
-	if err != nil {
-		return fmt.Errorf("ошибка при кодировании в JSON: %v", err)
+	if s.data.Rules == nil {
+		return NewWorkTime(DefaultWorkRules)
 	}
+	return NewWorkTime(*s.data.Rules)
+}
 
-	if err := os.WriteFile(s.filePath, fileData, 0644); err != nil {
-		return fmt.Errorf("ошибка при записи в файл: %v", err)
+// SetRules сохраняет правила учёта рабочего времени. Правила проверяются
+// ValidateWorkRules.
+func (s *WorkLogStorage) SetRules(actor Actor, rules models.WorkRules) error {
+	if err := ValidateWorkRules(rules); err != nil {
+		return err
 	}
+	return s.Update(actor, func(data *models.WorkLogData) error {
+		data.Rules = &rules
+		return nil
+	})
+}
 
-	fmt.Println("Данные табеля успешно сохранены")
+// QueryEntries возвращает копии записей, для которых match возвращает true.
+// match == nil выбирает все записи.
+func (s *WorkLogStorage) QueryEntries(match func(entry models.WorkEntry) bool) []models.WorkEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := []models.WorkEntry{}
+	for _, entry := range s.data.Entries {
+		if match == nil || match(entry) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// AddEntry добавляет запись. Если запись за эту дату уже есть, возвращается
+// ErrExists.
+func (s *WorkLogStorage) AddEntry(actor Actor, entry models.WorkEntry) error {
+	return s.Change(actor, UndoAdd, func(data *models.WorkLogData) error {
+		for _, existing := range data.Entries {
+			if existing.Date == entry.Date {
+				return ErrExists
+			}
+		}
+		data.Entries = append(data.Entries, entry)
+		return nil
+	})
+}
+
+// AddEntries добавляет записи одним действием, отменяемым целиком. Записи за
+// даты, которые уже есть в табеле, заменяются при replace, иначе остаются
+// прежними, а их даты возвращаются в skipped. Если добавлять нечего,
+// возвращается ErrExists.
+func (s *WorkLogStorage) AddEntries(actor Actor, entries []models.WorkEntry, replace bool) (skipped []string, err error) {
+	err = s.Change(actor, UndoBulk, func(data *models.WorkLogData) error {
+		skipped = nil
+		index := make(map[string]int, len(data.Entries))
+		for i, existing := range data.Entries {
+			index[existing.Date] = i
+		}
+		changed := 0
+		for _, entry := range entries {
+			i, ok := index[entry.Date]
+			switch {
+			case !ok:
+				index[entry.Date] = len(data.Entries)
+				data.Entries = append(data.Entries, entry)
+			case replace:
+				data.Entries[i] = entry
+			default:
+				skipped = append(skipped, entry.Date)
+				continue
+			}
+			changed++
+		}
+		if changed == 0 {
+			return ErrExists
+		}
+		return nil
+	})
+	return skipped, err
+}
+
+// ErrOverlap возвращается, если отрезки работы за день пересекаются по времени
+var ErrOverlap = errors.New("отрезки работы пересекаются")
+
+// CheckWorkSessions проверяет, что отрезки работы за день не пересекаются.
+// Отрезки, которые только касаются друг друга (12:00–13:00 и 13:00–17:00),
+// допустимы. Отрезок после полуночи сравнивается и с ночной частью смены,
+// начатой накануне вечером: 22:00–02:00 и 01:00–03:00 пересекаются.
+func CheckWorkSessions(sessions []models.WorkSession) error {
+	ordered := models.OrderSessions(sessions)
+	for i := range ordered {
+		for j := i + 1; j < len(ordered); j++ {
+			prev, cur := ordered[i], ordered[j]
+			prevStart, prevEnd := prev.Minutes()
+			curStart, curEnd := cur.Minutes()
+			for _, offset := range []int{-24 * 60, 0, 24 * 60} {
+				if max(prevStart, curStart+offset) < min(prevEnd, curEnd+offset) {
+					return fmt.Errorf("%w: %s–%s и %s–%s", ErrOverlap, prev.StartTime, prev.EndTime, cur.StartTime, cur.EndTime)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ErrInvalidBreak возвращается, если перерыв не укладывается во время работы
+var ErrInvalidBreak = errors.New("неверный перерыв")
+
+// CheckWorkBreaks проверяет перерывы записи: перерыв со временем должен
+// целиком приходиться на один из отрезков работы, а все перерывы вместе —
+// быть короче рабочего дня.
+func CheckWorkBreaks(entry models.WorkEntry) error {
+	sessions := entry.WorkSessions()
+	worked := 0
+	for _, session := range sessions {
+		start, end := session.Minutes()
+		worked += end - start
+	}
+	for _, b := range entry.Breaks {
+		if b.Minutes <= 0 {
+			return fmt.Errorf("%w: длительность должна быть больше нуля", ErrInvalidBreak)
+		}
+		if !b.HasTime() {
+			continue
+		}
+		breakStart, breakEnd := models.WorkSession{StartTime: b.StartTime, EndTime: b.EndTime}.Minutes()
+		inside := false
+		for _, session := range sessions {
+			start, end := session.Minutes()
+			// Перерыв после полуночи сравнивается с ночной частью смены
+			for _, offset := range []int{0, 24 * 60} {
+				if breakStart+offset >= start && breakEnd+offset <= end {
+					inside = true
+				}
+			}
+		}
+		if !inside {
+			return fmt.Errorf("%w: %s–%s вне времени работы", ErrInvalidBreak, b.StartTime, b.EndTime)
+		}
+	}
+	if total := entry.BreakMinutes(); len(entry.Breaks) > 0 && total >= worked {
+		return fmt.Errorf("%w: перерывы (%d мин) не короче рабочего дня", ErrInvalidBreak, total)
+	}
 	return nil
 }
 
-func (s *WorkLogStorage) GetData() *models.WorkLogData {
+// CheckWorkEntry проверяет отрезки работы и перерывы записи
+func CheckWorkEntry(entry models.WorkEntry) error {
+	if entry.IsDayOff {
+		return nil
+	}
+	if err := CheckWorkSessions(entry.WorkSessions()); err != nil {
+		return err
+	}
+	return CheckWorkBreaks(entry)
+}
+
+// AddSessions добавляет отрезки работы и перерывы за день. Если записи за
+// эту дату нет, она создаётся. Если день отмечен выходным, возвращается
+// ErrExists, если отрезки пересекаются между собой или с уже записанными —
+// ошибка ErrOverlap, если перерыв не укладывается в отрезки — ErrInvalidBreak.
+func (s *WorkLogStorage) AddSessions(actor Actor, date string, sessions []models.WorkSession, breaks []models.WorkBreak) (models.WorkEntry, error) {
+	var updated models.WorkEntry
+	err := s.Change(actor, UndoAdd, func(data *models.WorkLogData) error {
+		for i := range data.Entries {
+			entry := &data.Entries[i]
+			if entry.Date != date {
+				continue
+			}
+			if entry.IsDayOff {
+				return ErrExists
+			}
+			merged := *entry
+			merged.SetSessions(append(entry.WorkSessions(), sessions...))
+			merged.Breaks = append(append([]models.WorkBreak(nil), entry.Breaks...), breaks...)
+			if err := CheckWorkEntry(merged); err != nil {
+				return err
+			}
+			*entry = merged
+			updated = merged
+			return nil
+		}
+		updated = models.WorkEntry{Date: date, Breaks: breaks}
+		updated.SetSessions(sessions)
+		if err := CheckWorkEntry(updated); err != nil {
+			return err
+		}
+		data.Entries = append(data.Entries, updated)
+		return nil
+	})
+	return updated, err
+}
+
+// UpdateEntry изменяет запись за указанную дату. Дата записи не меняется.
+// Если записи нет, возвращается ErrNotFound.
+func (s *WorkLogStorage) UpdateEntry(actor Actor, date string, fn func(entry *models.WorkEntry) error) (models.WorkEntry, error) {
+	var updated models.WorkEntry
+	err := s.Change(actor, UndoEdit, func(data *models.WorkLogData) error {
+		for i := range data.Entries {
+			if data.Entries[i].Date != date {
+				continue
+			}
+			if err := fn(&data.Entries[i]); err != nil {
+				return err
+			}
+			data.Entries[i].Date = date
+			updated = data.Entries[i]
+			return nil
+		}
+		return ErrNotFound
+	})
+	return updated, err
+}
+
+// DeleteEntry переносит в корзину запись за указанную дату. Если записи нет,
+// возвращается ErrNotFound.
+func (s *WorkLogStorage) DeleteEntry(actor Actor, date string) error {
+	_, _, err := s.DeleteEntries(actor, date, date)
+	return err
+}
+
+// DeleteEntries переносит в корзину записи за даты с from по to включительно
+// одним отменяемым действием. Если резервное копирование включено, перед
+// удалением создаётся страховочная копия табеля; если создать её не удалось,
+// записи не удаляются. Возвращает даты удалённых записей и копию. Если за
+// период записей нет, возвращается ErrNotFound.
+func (s *WorkLogStorage) DeleteEntries(actor Actor, from, to string) ([]string, BackupInfo, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	return &s.data
+
+	// Даты в формате YYYY-MM-DD сравниваются как строки
+	dates := []string{}
+	for _, entry := range s.data.Entries {
+		if entry.Date >= from && entry.Date <= to {
+			dates = append(dates, entry.Date)
+		}
+	}
+	if len(dates) == 0 {
+		return nil, BackupInfo{}, ErrNotFound
+	}
+	sort.Strings(dates)
+
+	var safety BackupInfo
+	if s.backups != nil {
+		var err error
+		safety, err = s.backups.Create(BackupWorkLog, &s.data, len(s.data.Entries), BackupPreDelete)
+		if err != nil {
+			return nil, BackupInfo{}, fmt.Errorf("не удалось создать страховочную копию: %v", err)
+		}
+	}
+
+	err := s.change(actor, UndoDelete, func(data *models.WorkLogData) error {
+		RemoveWorkEntries(data, dates)
+		return nil
+	})
+	if err != nil {
+		return nil, BackupInfo{}, err
+	}
+	return dates, safety, nil
+}
+
+// copyWorkLogData делает копию данных табеля, не разделяющую срез записей
+func copyWorkLogData(src *models.WorkLogData) models.WorkLogData {
+	dst := *src
+	dst.Entries = append([]models.WorkEntry{}, src.Entries...)
+	for i := range dst.Entries {
+		if len(dst.Entries[i].Sessions) > 0 {
+			dst.Entries[i].Sessions = append([]models.WorkSession(nil), dst.Entries[i].Sessions...)
+		}
+		if len(dst.Entries[i].Breaks) > 0 {
+			dst.Entries[i].Breaks = append([]models.WorkBreak(nil), dst.Entries[i].Breaks...)
+		}
+	}
+	dst.Trash = append([]models.DeletedWorkEntry(nil), src.Trash...)
+	if src.Rules != nil {
+		rules := *src.Rules
+		dst.Rules = &rules
+	}
+	return dst
 }
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"errors"
+	"finance-tracker/models"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Одновременное добавление записей и отрезков, удаление за период и чтение не
+// должны мешать друг другу: запускайте с go test -race. Дата — ключ записи
+// табеля, поэтому каждая дата должна встречаться один раз, а отрезки за день —
+// не пересекаться.
+func TestWorkLogStorageConcurrentAccess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worklog_data.json")
+	s := NewWorkLogStorage(path)
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	first := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := func(n int) string {
+		return first.AddDate(0, 0, n).Format("2006-01-02")
+	}
+
+	const workers, rounds = 8, 20
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			actor := Actor{User: fmt.Sprintf("user%d", w)}
+			// У каждого работника свой час, поэтому отрезки разных работников
+			// за один день не пересекаются
+			session := models.WorkSession{
+				Place:     fmt.Sprintf("Объект %d", w),
+				StartTime: fmt.Sprintf("%02d:00", w*2),
+				EndTime:   fmt.Sprintf("%02d:00", w*2+1),
+			}
+			for i := 0; i < rounds; i++ {
+				_, err := s.AddSessions(actor, day(i), []models.WorkSession{session}, nil)
+				if err != nil && !errors.Is(err, ErrExists) {
+					t.Errorf("AddSessions: %v", err)
+					return
+				}
+				// Выходные за общие даты: все работники, кроме первого, получают ErrExists
+				err = s.AddEntry(actor, models.WorkEntry{Date: day(rounds + i), StartTime: "08:00", EndTime: "17:00", IsDayOff: true})
+				if err != nil && !errors.Is(err, ErrExists) {
+					t.Errorf("AddEntry: %v", err)
+				}
+				if i%5 == 4 {
+					_, _, err := s.DeleteEntries(actor, day(i-2), day(i))
+					if err != nil && !errors.Is(err, ErrNotFound) {
+						t.Errorf("DeleteEntries: %v", err)
+					}
+				}
+				s.QueryEntries(nil)
+				s.Snapshot()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	data := s.Snapshot()
+	seen := make(map[string]bool)
+	for _, entry := range data.Entries {
+		if seen[entry.Date] {
+			t.Errorf("запись за %s встречается дважды", entry.Date)
+		}
+		seen[entry.Date] = true
+		if err := CheckWorkSessions(entry.WorkSessions()); err != nil {
+			t.Errorf("запись за %s: %v", entry.Date, err)
+		}
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	reloaded := NewWorkLogStorage(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, want := len(reloaded.Snapshot().Entries), len(data.Entries); got != want {
+		t.Fatalf("после перезагрузки записей: %d, ожидалось %d", got, want)
+	}
+}
+
+func TestCheckWorkSessionsAcrossMidnight(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessions []models.WorkSession
+		overlap  bool
+	}{
+		{"обычный день", []models.WorkSession{{StartTime: "08:00", EndTime: "12:00"}, {StartTime: "13:00", EndTime: "17:00"}}, false},
+		{"касаются", []models.WorkSession{{StartTime: "08:00", EndTime: "12:00"}, {StartTime: "12:00", EndTime: "17:00"}}, false},
+		{"днём", []models.WorkSession{{StartTime: "08:00", EndTime: "12:00"}, {StartTime: "11:00", EndTime: "17:00"}}, true},
+		{"после полуночи внутри смены", []models.WorkSession{{StartTime: "22:00", EndTime: "02:00"}, {StartTime: "01:00", EndTime: "03:00"}}, true},
+		{"после полуночи вслед за сменой", []models.WorkSession{{StartTime: "22:00", EndTime: "00:00"}, {StartTime: "00:30", EndTime: "02:00"}}, false},
+	}
+	for _, tt := range tests {
+		err := CheckWorkSessions(tt.sessions)
+		if got := errors.Is(err, ErrOverlap); got != tt.overlap {
+			t.Errorf("%s: пересечение %v, ожидалось %v (%v)", tt.name, got, tt.overlap, err)
+		}
+	}
+}
+
+func TestSetSessionsKeepsNightShiftOrder(t *testing.T) {
+	var entry models.WorkEntry
+	entry.SetSessions([]models.WorkSession{
+		{Place: "Склад", StartTime: "00:30", EndTime: "02:00"},
+		{Place: "Офис", StartTime: "22:00", EndTime: "00:00"},
+	})
+	if entry.StartTime != "22:00" || entry.EndTime != "02:00" {
+		t.Errorf("день %s–%s, ожидалось 22:00–02:00", entry.StartTime, entry.EndTime)
+	}
+	if entry.Place != "Офис, Склад" {
+		t.Errorf("места %q, ожидалось «Офис, Склад»", entry.Place)
+	}
+
+	entry.SetSessions([]models.WorkSession{
+		{Place: "Склад", StartTime: "13:00", EndTime: "17:00"},
+		{Place: "Офис", StartTime: "08:00", EndTime: "12:00"},
+	})
+	if entry.StartTime != "08:00" || entry.EndTime != "17:00" {
+		t.Errorf("день %s–%s, ожидалось 08:00–17:00", entry.StartTime, entry.EndTime)
+	}
+}
@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"finance-tracker/models"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Расчёт рабочего времени по правилам табеля. Все страницы, API и отчёты
+// считают часы только через WorkTime, чтобы итоги везде совпадали.
+//
+// За день складываются все отрезки работы, из суммы вычитается обед, если она
+// больше порога. Часы сверх дневной нормы — дневная переработка. Остальные
+// часы копятся за неделю (с понедельника), и часы сверх недельной нормы —
+// недельная переработка того дня, в который норма превышена. Ночные часы и
+// часы в субботу и воскресенье оплачиваются с надбавкой; надбавки и
+// переработка складываются.
+
+// DefaultWorkRules — правила, по которым табель считался всегда: час обеда
+// при работе больше 7 часов, переработка сверх 8 часов в день оплачивается
+// вдвойне, надбавок за ночь и выходные нет
+var DefaultWorkRules = models.WorkRules{
+	LunchMinutes:       60,
+	LunchAfterHours:    7,
+	DailyNormHours:     8,
+	WeeklyNormHours:    0,
+	OvertimeMultiplier: 2,
+	NightStart:         "22:00",
+	NightEnd:           "06:00",
+	NightMultiplier:    1,
+	WeekendMultiplier:  1,
+}
+
+// WorkRuleError — ошибка в правилах учёта времени. Field — имя поля
+// models.WorkRules.
+type WorkRuleError struct {
+	Field   string
+	Message string
+}
+
+func (e *WorkRuleError) Error() string {
+	return e.Message
+}
+
+// ValidateWorkRules проверяет правила и возвращает первую ошибку как
+// *WorkRuleError
+func ValidateWorkRules(rules models.WorkRules) error {
+	fail := func(field, message string) error {
+		return &WorkRuleError{Field: field, Message: message}
+	}
+	switch {
+	case rules.LunchMinutes < 0 || rules.LunchMinutes > 240:
+		return fail("LunchMinutes", "длительность обеда должна быть от 0 до 240 минут")
+	case rules.LunchAfterHours < 0 || rules.LunchAfterHours > 24:
+		return fail("LunchAfterHours", "порог обеда должен быть от 0 до 24 часов")
+	case rules.DailyNormHours < 0 || rules.DailyNormHours > 24:
+		return fail("DailyNormHours", "дневная норма должна быть от 0 до 24 часов")
+	case rules.WeeklyNormHours < 0 || rules.WeeklyNormHours > 168:
+		return fail("WeeklyNormHours", "недельная норма должна быть от 0 до 168 часов")
+	case rules.OvertimeMultiplier < 1:
+		return fail("OvertimeMultiplier", "коэффициент переработки не может быть меньше 1")
+	case rules.NightMultiplier < 1:
+		return fail("NightMultiplier", "коэффициент ночных часов не может быть меньше 1")
+	case rules.WeekendMultiplier < 1:
+		return fail("WeekendMultiplier", "коэффициент выходных не может быть меньше 1")
+	}
+	if _, err := time.Parse("15:04", rules.NightStart); err != nil {
+		return fail("NightStart", "неверное начало ночных часов, ожидается HH:MM")
+	}
+	if _, err := time.Parse("15:04", rules.NightEnd); err != nil {
+		return fail("NightEnd", "неверное окончание ночных часов, ожидается HH:MM")
+	}
+	return nil
+}
+
+// WorkDay — рабочий день, рассчитанный по правилам. Все величины в часах.
+type WorkDay struct {
+	Date           string
+	IsDayOff       bool
+	Sessions       float64 // Сумма отрезков работы без вычета обеда
+	Lunch          float64 // Вычтено на обед или записанные перерывы
+	BreaksRecorded bool    // Вычтены записанные перерывы, а не обед по правилу
+	Hours          float64 // Отработано: Sessions - Lunch
+	Overtime       float64 // Сверх дневной нормы
+	WeeklyOvertime float64 // Сверх недельной нормы, без дневной переработки
+	Night          float64 // Ночные часы
+	Weekend        float64 // Часы в субботу или воскресенье
+	Paid           float64 // Часы к оплате с учётом коэффициентов
+}
+
+// WorkTimeSummary — итоги рабочего времени за период
+type WorkTimeSummary struct {
+	WorkDays            int     `json:"work_days"`
+	TotalHours          float64 `json:"total_hours"`
+	OvertimeHours       float64 `json:"overtime_hours"` // Дневная и недельная переработка
+	WeeklyOvertimeHours float64 `json:"weekly_overtime_hours"`
+	NightHours          float64 `json:"night_hours"`
+	WeekendHours        float64 `json:"weekend_hours"`
+	// Часы к оплате: отработанные часы с коэффициентами за переработку,
+	// ночь и выходные
+	TotalWithOvertime float64 `json:"total_with_overtime"`
+}
+
+// WorkTime считает рабочее время по правилам
+type WorkTime struct {
+	rules models.WorkRules
+}
+
+func NewWorkTime(rules models.WorkRules) WorkTime {
+	return WorkTime{rules: rules}
+}
+
+// Rules возвращает правила расчёта
+func (w WorkTime) Rules() models.WorkRules {
+	return w.rules
+}
+
+// Day рассчитывает один день без учёта недельной нормы
+func (w WorkTime) Day(entry models.WorkEntry) WorkDay {
+	day := WorkDay{Date: entry.Date, IsDayOff: entry.IsDayOff}
+	if entry.IsDayOff {
+		return day
+	}
+
+	var night float64
+	for _, session := range entry.WorkSessions() {
+		day.Sessions += session.Hours()
+		night += w.nightHours(session)
+	}
+	switch {
+	case len(entry.Breaks) > 0:
+		// Записанные перерывы заменяют обед по правилу, перерывы со временем
+		// не считаются ночными часами
+		day.BreaksRecorded = true
+		day.Lunch = min(float64(entry.BreakMinutes())/60, day.Sessions)
+		for _, b := range entry.Breaks {
+			if b.HasTime() {
+				night -= w.nightHours(models.WorkSession{StartTime: b.StartTime, EndTime: b.EndTime})
+			}
+		}
+		night = max(night, 0)
+	case day.Sessions > w.rules.LunchAfterHours:
+		day.Lunch = min(float64(w.rules.LunchMinutes)/60, day.Sessions)
+	}
+	day.Hours = day.Sessions - day.Lunch
+	if w.rules.DailyNormHours > 0 && day.Hours > w.rules.DailyNormHours {
+		day.Overtime = day.Hours - w.rules.DailyNormHours
+	}
+	// Обед и перерывы без времени считаются дневным временем: ночных часов не
+	// больше отработанных
+	day.Night = min(night, day.Hours)
+	if date, err := time.Parse("2006-01-02", entry.Date); err == nil {
+		if weekday := date.Weekday(); weekday == time.Saturday || weekday == time.Sunday {
+			day.Weekend = day.Hours
+		}
+	}
+	w.pay(&day)
+	return day
+}
+
+// pay считает часы к оплате
+func (w WorkTime) pay(day *WorkDay) {
+	day.Paid = day.Hours +
+		(day.Overtime+day.WeeklyOvertime)*(w.rules.OvertimeMultiplier-1) +
+		day.Night*(w.rules.NightMultiplier-1) +
+		day.Weekend*(w.rules.WeekendMultiplier-1)
+}
+
+// nightHours возвращает часы отрезка, попадающие в ночное время. Отрезок
+// через полночь сравнивается с ночами этого и следующего дня.
+func (w WorkTime) nightHours(session models.WorkSession) float64 {
+	nightStart, nightEnd := models.WorkSession{StartTime: w.rules.NightStart, EndTime: w.rules.NightEnd}.Minutes()
+	if nightStart == nightEnd {
+		return 0
+	}
+	start, end := session.Minutes()
+	minutes := 0
+	for _, offset := range []int{-24 * 60, 0, 24 * 60} {
+		from, to := max(start, nightStart+offset), min(end, nightEnd+offset)
+		if to > from {
+			minutes += to - from
+		}
+	}
+	return float64(minutes) / 60
+}
+
+// Days рассчитывает дни по записям табеля в порядке дат. Недельная норма
+// считается по записям той же недели, поэтому для точного расчёта периода
+// передавайте записи с запасом в неделю с каждой стороны.
+func (w WorkTime) Days(entries []models.WorkEntry) []WorkDay {
+	sorted := append([]models.WorkEntry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date < sorted[j].Date })
+
+	days := make([]WorkDay, 0, len(sorted))
+	week, weekHours := "", 0.0
+	for _, entry := range sorted {
+		day := w.Day(entry)
+		if w.rules.WeeklyNormHours > 0 && !entry.IsDayOff {
+			if date, err := time.Parse("2006-01-02", entry.Date); err == nil {
+				year, number := date.ISOWeek()
+				if key := fmt.Sprintf("%d-W%02d", year, number); key != week {
+					week, weekHours = key, 0
+				}
+			}
+			regular := day.Hours - day.Overtime
+			if over := weekHours + regular - w.rules.WeeklyNormHours; over > 0 {
+				day.WeeklyOvertime = min(over, regular)
+				w.pay(&day)
+			}
+			weekHours += regular
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// Summarize складывает рассчитанные дни
+func (w WorkTime) Summarize(days []WorkDay) WorkTimeSummary {
+	summary := WorkTimeSummary{}
+	for _, day := range days {
+		if day.IsDayOff {
+			continue
+		}
+		summary.WorkDays++
+		summary.TotalHours += day.Hours
+		summary.OvertimeHours += day.Overtime + day.WeeklyOvertime
+		summary.WeeklyOvertimeHours += day.WeeklyOvertime
+		summary.NightHours += day.Night
+		summary.WeekendHours += day.Weekend
+		summary.TotalWithOvertime += day.Paid
+	}
+	return summary
+}